@@ -1,36 +1,285 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+	// Embed the IANA time zone database so metrics tz alignment works on minimal images.
+	_ "time/tzdata"
 
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/cache"
+	"github.com/PratikDhanave/event-analytics-service/internal/catalog"
 	"github.com/PratikDhanave/event-analytics-service/internal/config"
 	"github.com/PratikDhanave/event-analytics-service/internal/httpserver"
+	"github.com/PratikDhanave/event-analytics-service/internal/live"
+	"github.com/PratikDhanave/event-analytics-service/internal/logging"
+	"github.com/PratikDhanave/event-analytics-service/internal/partition"
+	"github.com/PratikDhanave/event-analytics-service/internal/privacy"
+	"github.com/PratikDhanave/event-analytics-service/internal/purge"
+	"github.com/PratikDhanave/event-analytics-service/internal/rollup"
 	"github.com/PratikDhanave/event-analytics-service/internal/store"
+	"github.com/PratikDhanave/event-analytics-service/internal/tracing"
+	"github.com/PratikDhanave/event-analytics-service/internal/worker"
 )
 
-// main boots the service: config → DB → schema → HTTP server.
+// main boots the service: config → DB → migrations → bootstrap keys →
+// metrics cache, live hub → (partition maintainer, purge job, privacy runner, rollup compactor, catalog compactor, worker) →
+// HTTP server, and drains it on SIGTERM/SIGINT:
+// /ready 503 → SHUTDOWN_DELAY → in-flight requests finish → background jobs stop → worker flush → DB pool closed.
+//
+// `app migrate up|down [steps]|status` manages the schema and exits instead.
 func main() {
-	// Load runtime config from environment (DB_URL, API_KEYS).
+	// Load runtime config from environment (DB_URL, API_KEYS, INGEST_MODE, ...).
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("loading config failed", err)
 	}
+	logging.Setup(cfg.LogLevel)
+
+	// Export spans of requests, store operations and queries (TRACING_EXPORTER).
+	shutdownTracing, err := tracing.Init(tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		File:        cfg.TracingFile,
+		ServiceName: cfg.TracingServiceName,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		logging.Fatal("tracing setup failed", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Connect to durable storage (Postgres) using a connection pool.
 	db, err := store.NewPostgresStore(cfg.DBURL)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("connecting to postgres failed", err)
 	}
 	defer db.Close()
 
-	// Ensure required tables/indexes exist so `docker compose up --build` is enough.
-	if err := db.EnsureSchema(); err != nil {
-		log.Fatal(err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), db, os.Args[2:]); err != nil {
+			logging.Fatal("migrate failed", err)
+		}
+		return
+	}
+
+	// Apply pending migrations so `docker compose up --build` is enough.
+	// Disable with AUTO_MIGRATE=false to run `app migrate up` as a deploy step instead.
+	if cfg.AutoMigrate {
+		applied, err := db.MigrateUp(context.Background())
+		if err != nil {
+			logging.Fatal("applying migrations failed", err)
+		}
+		for _, m := range applied {
+			slog.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+	}
+
+	// Sync API_KEYS into api_keys as hashed keys: listed keys are added or moved
+	// to their tenant, keys dropped from the list are revoked and reinstated if
+	// listed again. A key revoked through the admin API stays revoked across
+	// restarts.
+	envKeys := make([]store.APIKey, 0, len(cfg.APIKeys))
+	for key, tenantID := range cfg.APIKeys {
+		k, err := auth.LegacyAPIKey(key, tenantID)
+		if err != nil {
+			logging.Fatal("importing API_KEYS failed", err)
+		}
+		envKeys = append(envKeys, k)
+	}
+	revoked, err := db.SyncEnvAPIKeys(context.Background(), envKeys, time.Now())
+	if err != nil {
+		logging.Fatal("importing API_KEYS failed", err)
+	}
+	if revoked > 0 {
+		slog.Info("revoked API_KEYS entries no longer configured", "keys", revoked)
+	}
+
+	// Background jobs run until shutdown has drained the HTTP server.
+	jobs := newBackground()
+
+	// Cache GET /metrics results. Registered before anything writes events, so
+	// every late event, erasure and purge invalidates the windows it touches,
+	// here and (Postgres LISTEN/NOTIFY) on every replica.
+	metricsCache := newMetricsCache(cfg, db)
+	if metricsCache != nil {
+		db.OnChange(metricsCache.Changed)
+		jobs.Go(metricsCache.Run)
+	}
+
+	// Feed GET /metrics/stream: publish what this process ingests and deliver
+	// what every process ingests (Postgres LISTEN/NOTIFY).
+	var hub *live.Hub
+	if cfg.LiveStreams {
+		hub = live.New(db, live.Options{
+			FlushInterval: cfg.LiveFlushInterval,
+			MaxStreams:    cfg.LiveMaxStreams,
+		})
+		db.OnChange(hub.Changed)
+		jobs.Go(hub.Run)
+	}
+
+	// Keep events partitions created ahead of time and expire old ones.
+	if cfg.PartitionMaintainer {
+		m := partition.New(db, partition.Options{
+			Interval:      store.PartitionInterval(cfg.PartitionInterval),
+			Ahead:         cfg.PartitionAhead,
+			Retention:     cfg.EventRetention,
+			Detach:        cfg.PartitionDetach,
+			CheckInterval: time.Hour,
+		})
+		jobs.Go(m.Run)
+	}
+
+	// Enforce per-tenant retention. The purger also serves /admin/purge, so it is
+	// created even when the scheduled job runs elsewhere (PURGE_JOB=false).
+	purger := purge.New(db, purge.Options{
+		Default:   cfg.DefaultRetention,
+		Tenants:   cfg.TenantRetention,
+		BatchSize: cfg.PurgeBatchSize,
+		DryRun:    cfg.PurgeDryRun,
+		Interval:  cfg.PurgeInterval,
+	})
+	if cfg.PurgeJob {
+		jobs.Go(purger.Run)
+	}
+
+	// Operational metrics for Prometheus, on their own port (TELEMETRY_ADDR).
+	var telemetrySrv *http.Server
+	if cfg.TelemetryAddr != "" {
+		telemetrySrv = serveTelemetry(cfg.TelemetryAddr, db, purger)
+	}
+
+	// Process GDPR erasure/export jobs queued through /admin/privacy.
+	if cfg.PrivacyRunner {
+		pr := privacy.New(db, privacy.Options{
+			BatchSize:    1000,
+			PollInterval: 2 * time.Second,
+			ExportTTL:    cfg.PrivacyExportTTL,
+			StaleAfter:   5 * time.Minute,
+		})
+		jobs.Go(pr.Run)
+	}
+
+	// Fold new events into the hourly rollups that serve GET /metrics counts.
+	if cfg.RollupCompactor {
+		rc := rollup.New(db, rollup.Options{
+			Interval: cfg.RollupInterval,
+		})
+		jobs.Go(rc.Run)
+	}
+
+	// Fold the catalog entries appended by ingestion into GET /events/catalog.
+	if cfg.CatalogCompactor {
+		cc := catalog.New(db, catalog.Options{
+			Interval: cfg.CatalogInterval,
+		})
+		jobs.Go(cc.Run)
+	}
+
+	// In async mode the queue can be drained in-process, or by cmd/worker replicas
+	// when INGEST_WORKER=false. The worker runs apart from the other jobs: on
+	// shutdown it is stopped first, so the final Flush never races its Run.
+	var (
+		ingest     *worker.Worker
+		ingestJobs *background
+	)
+	if cfg.IngestMode == config.IngestModeAsync && cfg.IngestWorker {
+		ingest = worker.New(db, worker.Options{
+			BatchSize:    cfg.WorkerBatchSize,
+			PollInterval: cfg.WorkerPollInterval,
+			MaxAttempts:  cfg.WorkerMaxAttempts,
+		})
+		ingestJobs = newBackground()
+		ingestJobs.Go(ingest.Run)
+		slog.Info("ingestion worker started")
 	}
 
 	// Build HTTP router (public health + authenticated APIs).
-	router := httpserver.NewRouter(cfg, db)
+	var draining atomic.Bool
+	router, err := httpserver.NewRouter(cfg, db, purger, metricsCache, hub, draining.Load)
+	if err != nil {
+		logging.Fatal("building router failed", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	srv := httpserver.NewServer(cfg, router)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+	slog.Info("server started", "addr", cfg.HTTPAddr, "ingest_mode", cfg.IngestMode)
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("server stopped", err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process right away
+
+	// Fail readiness first and keep serving while load balancers notice.
+	draining.Store(true)
+	slog.Info("shutting down", "delay", cfg.ShutdownDelay.String(), "timeout", cfg.ShutdownTimeout.String())
+	time.Sleep(cfg.ShutdownDelay)
 
-	log.Println("server started on :8080")
-	log.Fatal(router.Run(":8080"))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests. Live streams
+	// never finish on their own, so they are ended first.
+	if hub != nil {
+		hub.Drain()
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("draining requests failed", "err", err)
+		_ = srv.Close()
+	}
+
+	// Stop the worker (a batch in flight commits or rolls back) and persist what
+	// the last requests queued, then stop the other background jobs (the hub
+	// publishes its last counts, including the flushed events).
+	if ingest != nil {
+		if err := ingestJobs.Stop(shutdownCtx); err != nil {
+			slog.Error("stopping the ingestion worker failed", "err", err)
+		} else if err := ingest.Flush(shutdownCtx); err != nil {
+			slog.Error("flushing the ingestion queue failed", "err", err)
+		}
+	}
+	if err := jobs.Stop(shutdownCtx); err != nil {
+		slog.Error("stopping background jobs failed", "err", err)
+	}
+
+	// Metrics stay scrapeable until everything else has stopped.
+	if telemetrySrv != nil {
+		if err := telemetrySrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("stopping the telemetry server failed", "err", err)
+			_ = telemetrySrv.Close()
+		}
+	}
+	slog.Info("server stopped")
+	// Deferred: the pgx pool closes, then pending spans are exported.
+}
+
+// newMetricsCache returns the metrics cache selected by METRICS_CACHE, or nil
+// when caching is off.
+func newMetricsCache(cfg config.Config, db *store.PostgresStore) *cache.MetricsCache {
+	opts := cache.Options{
+		Horizon:   cfg.MetricsCacheHorizon,
+		PastTTL:   cfg.MetricsCachePastTTL,
+		RecentTTL: cfg.MetricsCacheRecentTTL,
+	}
+	switch cfg.MetricsCache {
+	case config.MetricsCacheMemory:
+		return cache.NewMetrics(cache.NewLRU(cfg.MetricsCacheSize), db, opts)
+	case config.MetricsCacheRedis:
+		return cache.NewMetrics(cache.NewRedis(cfg.MetricsCacheRedisAddr, "eas:", 16), db, opts)
+	default:
+		return nil
+	}
 }
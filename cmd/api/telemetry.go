@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/purge"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+)
+
+// serveTelemetry registers the process-wide collectors and serves
+// GET /internal/metrics on addr in the background until the returned server
+// is shut down.
+func serveTelemetry(addr string, db *store.PostgresStore, purger *purge.Purger) *http.Server {
+	telemetry.RegisterPool(db.PoolStat)
+	telemetry.RegisterQueueLag(db.QueueLag)
+	telemetry.NewCollector(func() []telemetry.Sample {
+		s := purger.Stats()
+		return []telemetry.Sample{
+			{Name: "purge_runs_total", Help: "Retention purge passes.", Type: prometheus.CounterValue, Value: float64(s.Runs)},
+			{Name: "purge_failures_total", Help: "Retention purge passes that failed.", Type: prometheus.CounterValue, Value: float64(s.Failures)},
+			{Name: "purge_events_total", Help: "Events deleted by retention purges.", Type: prometheus.CounterValue, Value: float64(s.EventsPurged)},
+			{Name: "purge_partitions_dropped_total", Help: "Partitions dropped by retention purges.", Type: prometheus.CounterValue, Value: float64(s.PartitionsDropped)},
+		}
+	})
+
+	srv := telemetry.NewServer(addr)
+	go func() {
+		slog.Info("telemetry server started", "addr", addr, "path", "/internal/metrics")
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("telemetry server stopped", "err", err)
+		}
+	}()
+	return srv
+}
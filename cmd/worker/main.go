@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/cache"
+	"github.com/PratikDhanave/event-analytics-service/internal/config"
+	"github.com/PratikDhanave/event-analytics-service/internal/live"
+	"github.com/PratikDhanave/event-analytics-service/internal/logging"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+	"github.com/PratikDhanave/event-analytics-service/internal/tracing"
+	"github.com/PratikDhanave/event-analytics-service/internal/worker"
+)
+
+// main runs a standalone ingestion worker that drains event_queue into events.
+// Use it with INGEST_MODE=async and INGEST_WORKER=false on the API to scale
+// ingestion and persistence independently.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		logging.Fatal("loading config failed", err)
+	}
+	logging.Setup(cfg.LogLevel)
+
+	// Export spans of requests, store operations and queries (TRACING_EXPORTER).
+	shutdownTracing, err := tracing.Init(tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		File:        cfg.TracingFile,
+		ServiceName: cfg.TracingServiceName,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		logging.Fatal("tracing setup failed", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	db, err := store.NewPostgresStore(cfg.DBURL)
+	if err != nil {
+		logging.Fatal("connecting to postgres failed", err)
+	}
+	defer db.Close()
+
+	if cfg.AutoMigrate {
+		if _, err := db.MigrateUp(context.Background()); err != nil {
+			logging.Fatal("applying migrations failed", err)
+		}
+	}
+
+	// The metrics caches of the API replicas must hear about late events
+	// drained here too.
+	if cfg.MetricsCache != config.MetricsCacheOff {
+		b := cache.NewBroadcaster(db, cache.Options{Horizon: cfg.MetricsCacheHorizon})
+		db.OnChange(b.Changed)
+	}
+
+	// Stop claiming new batches on SIGINT/SIGTERM; an in-flight batch either
+	// commits or rolls back, so queued events are never lost.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Publish drained counts for the live streams served by the API replicas.
+	if cfg.LiveStreams {
+		hub := live.New(db, live.Options{FlushInterval: cfg.LiveFlushInterval})
+		db.OnChange(hub.Changed)
+		go hub.Publish(ctx)
+	}
+
+	var telemetrySrv *http.Server
+	if cfg.TelemetryAddr != "" {
+		telemetry.RegisterPool(db.PoolStat)
+		telemetry.RegisterQueueLag(db.QueueLag)
+		telemetrySrv = telemetry.NewServer(cfg.TelemetryAddr)
+		go func() {
+			if err := telemetrySrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("telemetry server stopped", "err", err)
+			}
+		}()
+	}
+
+	slog.Info("worker started", "batch_size", cfg.WorkerBatchSize, "poll_interval", cfg.WorkerPollInterval.String())
+	worker.New(db, worker.Options{
+		BatchSize:    cfg.WorkerBatchSize,
+		PollInterval: cfg.WorkerPollInterval,
+		MaxAttempts:  cfg.WorkerMaxAttempts,
+	}).Run(ctx)
+
+	if telemetrySrv != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := telemetrySrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("stopping the telemetry server failed", "err", err)
+			_ = telemetrySrv.Close()
+		}
+	}
+	slog.Info("worker stopped")
+}
@@ -1,30 +1,255 @@
 package auth
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/logging"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
 )
 
 // tenantCtxKey is the Gin context key used to store the authenticated tenant ID.
 const tenantCtxKey = "tenant_id"
 
+// actorCtxKey stores who authenticated the request, for audit trails (see Actor).
+const actorCtxKey = "actor"
+
+// OperatorActor is the Actor of requests authenticated with ADMIN_API_KEY.
+const OperatorActor = "operator"
+
+// maxCacheEntries bounds the validation cache. Once full, expired entries are
+// swept and unknown keys are no longer cached, so random keys cannot grow it.
+const maxCacheEntries = 10000
+
+// KeyStore looks up stored API keys; implemented by store.PostgresStore.
+type KeyStore interface {
+	GetAPIKey(ctx context.Context, id string) (store.APIKey, error)
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+// cacheEntry is the validation result for one presented key.
+type cacheEntry struct {
+	key     store.APIKey
+	valid   bool
+	expires time.Time
+}
+
+// KeyValidator validates presented API keys against the KeyStore.
+//
+// Results, including rejections, are cached for ttl and indexed by a digest of
+// the presented key, so plaintext keys are never held in memory. A revoked key
+// therefore keeps working for up to ttl on other replicas; Invalidate drops it
+// from this replica's cache immediately.
+type KeyValidator struct {
+	store  KeyStore
+	ttl    time.Duration
+	static map[string]store.APIKey // id -> key, see AddStaticKey
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cacheEntry
+	now   func() time.Time
+}
+
+// NewKeyValidator creates a validator caching results for ttl (0 disables caching).
+func NewKeyValidator(st KeyStore, ttl time.Duration) *KeyValidator {
+	return &KeyValidator{
+		store:  st,
+		ttl:    ttl,
+		cache:  map[[sha256.Size]byte]cacheEntry{},
+		static: map[string]store.APIKey{},
+		now:    time.Now,
+	}
+}
+
+// Validate returns the stored key matching the presented one. ok is false when
+// the key is unknown, wrong, revoked or expired; err is only set when the store
+// could not be reached.
+func (v *KeyValidator) Validate(ctx context.Context, presented string) (store.APIKey, bool, error) {
+	now := v.now()
+	digest := sha256.Sum256([]byte(presented))
+
+	v.mu.Lock()
+	e, hit := v.cache[digest]
+	v.mu.Unlock()
+	if hit && now.Before(e.expires) {
+		return e.key, e.valid && e.key.Active(now), nil
+	}
+
+	id, secret := parseAPIKey(presented)
+	k, static := v.static[id]
+	var err error
+	if !static {
+		k, err = v.store.GetAPIKey(ctx, id)
+	}
+	switch {
+	case errors.Is(err, store.ErrAPIKeyNotFound):
+		v.put(digest, cacheEntry{}, now)
+		return store.APIKey{}, false, nil
+	case err != nil:
+		return store.APIKey{}, false, err
+	}
+
+	valid := verifySecret(k, secret)
+	if !valid {
+		k = store.APIKey{}
+	}
+	v.put(digest, cacheEntry{key: k, valid: valid}, now)
+
+	if !valid || !k.Active(now) {
+		return store.APIKey{}, false, nil
+	}
+	if static {
+		return k, true, nil
+	}
+
+	// last_used_at is refreshed at most once per cache period per replica.
+	if err := v.store.TouchAPIKey(ctx, k.ID, now); err != nil {
+		slog.Warn("auth: recording api key use failed", "key_id", k.ID, "err", err)
+	}
+	return k, true, nil
+}
+
+// AddStaticKey makes v accept k without storing it, e.g. the local dev
+// fallback key. It must be called before v is used.
+func (v *KeyValidator) AddStaticKey(k store.APIKey) {
+	v.static[k.ID] = k
+}
+
+// Invalidate drops cached results for the key with the given id.
+func (v *KeyValidator) Invalidate(id string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for d, e := range v.cache {
+		if e.key.ID == id {
+			delete(v.cache, d)
+		}
+	}
+}
+
+func (v *KeyValidator) put(digest [sha256.Size]byte, e cacheEntry, now time.Time) {
+	if v.ttl <= 0 {
+		return
+	}
+	e.expires = now.Add(v.ttl)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.cache) >= maxCacheEntries {
+		for d, old := range v.cache {
+			if !now.Before(old.expires) {
+				delete(v.cache, d)
+			}
+		}
+		if len(v.cache) >= maxCacheEntries && !e.valid {
+			return
+		}
+	}
+	v.cache[digest] = e
+}
+
 // APIKeyMiddleware enforces multi-tenancy by mapping X-API-Key → tenantID.
-// In production this mapping would typically come from IAM/JWT/Secret Manager.
-func APIKeyMiddleware(keys map[string]string) gin.HandlerFunc {
+// Keys are validated against the api_keys table through v's cache; the key's
+// scopes are recorded for RequireScope.
+func APIKeyMiddleware(v *KeyValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authenticateKey(c, v) {
+			c.Next()
+		}
+	}
+}
+
+// Middleware authenticates tenant requests with either credential:
+// "Authorization: Bearer <JWT>" when jwt is configured, otherwise X-API-Key.
+// Both record the tenant (see TenantID) and scopes (see RequireScope).
+func Middleware(keys *KeyValidator, jwt *JWTVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, ok := bearerToken(c); ok && jwt != nil {
+			if authenticateBearer(c, jwt, token) {
+				c.Next()
+			}
+			return
+		}
+		if authenticateKey(c, keys) {
+			c.Next()
+		}
+	}
+}
+
+// authenticateKey validates X-API-Key and stores the tenant and scopes in c.
+// On failure it aborts c with 401 (or 503 if the key store is down) and returns false.
+func authenticateKey(c *gin.Context, v *KeyValidator) bool {
+	apiKey := strings.TrimSpace(c.GetHeader("X-API-Key"))
+	if apiKey == "" {
+		telemetry.AuthFailure("missing_key")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return false
+	}
+
+	k, ok, err := v.Validate(c.Request.Context(), apiKey)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("auth: api key lookup failed", "err", err)
+		telemetry.AuthFailure("lookup_failed")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication unavailable"})
+		return false
+	}
+	if !ok {
+		telemetry.AuthFailure("invalid_key")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return false
+	}
+	c.Set(tenantCtxKey, k.TenantID)
+	c.Set(scopesCtxKey, effectiveScopes(k.Scopes))
+	c.Set(actorCtxKey, "api_key:"+k.ID)
+	return true
+}
+
+// AdminMiddleware authenticates the /admin API.
+//
+// An operator presents ADMIN_API_KEY as X-Admin-Key and may manage every tenant
+// (adminKey empty disables this). Otherwise the request needs an X-API-Key with
+// the admin scope, and TenantID is set so handlers restrict it to that tenant.
+func AdminMiddleware(adminKey string, v *KeyValidator) gin.HandlerFunc {
+	want := sha256.Sum256([]byte(adminKey))
 	return func(c *gin.Context) {
-		apiKey := strings.TrimSpace(c.GetHeader("X-API-Key"))
-		tenantID, ok := keys[apiKey]
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		if presented := strings.TrimSpace(c.GetHeader("X-Admin-Key")); presented != "" {
+			got := sha256.Sum256([]byte(presented))
+			if adminKey == "" || subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+				telemetry.AuthFailure("invalid_admin_key")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			c.Set(actorCtxKey, OperatorActor)
+			c.Next()
+			return
+		}
+
+		if !authenticateKey(c, v) {
 			return
 		}
-		c.Set(tenantCtxKey, tenantID)
-		c.Next()
+		RequireScope(ScopeAdmin)(c)
 	}
 }
 
+// Actor identifies the credential that authenticated the request:
+// "api_key:<id>", "jwt:<sub>", or OperatorActor.
+func Actor(c *gin.Context) string {
+	v, _ := c.Get(actorCtxKey)
+	s, _ := v.(string)
+	return s
+}
+
 // TenantID returns the authenticated tenant ID from the request context.
 func TenantID(c *gin.Context) string {
 	v, _ := c.Get(tenantCtxKey)
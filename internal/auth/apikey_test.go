@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// memKeys is an in-memory KeyStore that counts lookups.
+type memKeys struct {
+	keys    map[string]store.APIKey
+	lookups int
+}
+
+func (m *memKeys) GetAPIKey(_ context.Context, id string) (store.APIKey, error) {
+	m.lookups++
+	k, ok := m.keys[id]
+	if !ok {
+		return store.APIKey{}, store.ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+func (m *memKeys) TouchAPIKey(context.Context, string, time.Time) error { return nil }
+
+func TestNewAPIKey_RoundTrip(t *testing.T) {
+	plaintext, k, err := NewAPIKey("tenant1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, secret := parseAPIKey(plaintext)
+	if id != k.ID {
+		t.Fatalf("parsed id %q, want %q", id, k.ID)
+	}
+	if !verifySecret(k, secret) {
+		t.Fatal("secret does not verify against its own hash")
+	}
+	if verifySecret(k, secret+"x") {
+		t.Fatal("modified secret verified")
+	}
+}
+
+func TestLegacyAPIKey_RoundTrip(t *testing.T) {
+	k, err := LegacyAPIKey("tenant-key-123", "tenant1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, secret := parseAPIKey("tenant-key-123")
+	if id != k.ID || !verifySecret(k, secret) {
+		t.Fatalf("legacy key did not round-trip: id %q vs %q", id, k.ID)
+	}
+}
+
+func TestKeyValidator_StaticKeysAreNeverLookedUp(t *testing.T) {
+	k, err := LegacyAPIKey("tenant-key-123", "tenant1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &memKeys{keys: map[string]store.APIKey{}}
+	v := NewKeyValidator(st, 0)
+	v.AddStaticKey(k)
+
+	if got, ok, err := v.Validate(context.Background(), "tenant-key-123"); err != nil || !ok || got.TenantID != "tenant1" {
+		t.Fatalf("static key: got %+v ok=%v err=%v", got, ok, err)
+	}
+	if _, ok, _ := v.Validate(context.Background(), "tenant-key-124"); ok {
+		t.Fatal("unknown key accepted")
+	}
+	if st.lookups != 1 {
+		t.Fatalf("lookups = %d, want 1 (the unknown key only)", st.lookups)
+	}
+}
+
+func TestKeyValidator_CachesAndHonoursRevocation(t *testing.T) {
+	plaintext, k, err := NewAPIKey("tenant1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &memKeys{keys: map[string]store.APIKey{k.ID: k}}
+
+	now := time.Unix(1_700_000_000, 0)
+	v := NewKeyValidator(st, time.Minute)
+	v.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		got, ok, err := v.Validate(context.Background(), plaintext)
+		if err != nil || !ok || got.TenantID != "tenant1" {
+			t.Fatalf("validate: ok=%v err=%v tenant=%q", ok, err, got.TenantID)
+		}
+	}
+	if st.lookups != 1 {
+		t.Fatalf("lookups = %d, want 1 (cached)", st.lookups)
+	}
+
+	if _, ok, _ := v.Validate(context.Background(), plaintext+"x"); ok {
+		t.Fatal("wrong secret accepted")
+	}
+
+	revoked := now
+	k.RevokedAt = &revoked
+	st.keys[k.ID] = k
+
+	// Still cached until invalidated or the TTL passes.
+	if _, ok, _ := v.Validate(context.Background(), plaintext); !ok {
+		t.Fatal("expected cached result before invalidation")
+	}
+	v.Invalidate(k.ID)
+	if _, ok, _ := v.Validate(context.Background(), plaintext); ok {
+		t.Fatal("revoked key accepted after invalidation")
+	}
+}
+
+func TestKeyValidator_ExpiryAppliesToCachedKeys(t *testing.T) {
+	plaintext, k, err := NewAPIKey("tenant1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	expires := now.Add(10 * time.Second)
+	k.ExpiresAt = &expires
+
+	v := NewKeyValidator(&memKeys{keys: map[string]store.APIKey{k.ID: k}}, time.Minute)
+	v.now = func() time.Time { return now }
+
+	if _, ok, _ := v.Validate(context.Background(), plaintext); !ok {
+		t.Fatal("expected key to be valid before expiry")
+	}
+	now = now.Add(20 * time.Second)
+	if _, ok, _ := v.Validate(context.Background(), plaintext); ok {
+		t.Fatal("expired key accepted from cache")
+	}
+}
+
+func TestRequireScope_ForbidsMissingScope(t *testing.T) {
+	plaintext, k, err := NewAPIKey("tenant1", []string{ScopeEventsWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := LegacyAPIKey("legacy-key", "tenant1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewKeyValidator(&memKeys{keys: map[string]store.APIKey{k.ID: k, legacy.ID: legacy}}, time.Minute)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/metrics", APIKeyMiddleware(v), RequireScope(ScopeMetricsRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	cases := []struct {
+		key  string
+		want int
+	}{
+		{plaintext, http.StatusForbidden},
+		{"legacy-key", http.StatusOK}, // no stored scopes: DefaultScopes
+		{"", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("X-API-Key", tc.key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("key %q: expected %d got %d: %s", tc.key, tc.want, w.Code, w.Body)
+		}
+	}
+}
+
+func TestAdminMiddleware_RecordsActor(t *testing.T) {
+	plaintext, k, err := NewAPIKey("tenant1", []string{ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewKeyValidator(&memKeys{keys: map[string]store.APIKey{k.ID: k}}, time.Minute)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin", AdminMiddleware("admin-secret", v), func(c *gin.Context) {
+		c.String(http.StatusOK, Actor(c)+"|"+TenantID(c))
+	})
+
+	cases := []struct {
+		header, value, want string
+	}{
+		{"X-Admin-Key", "admin-secret", OperatorActor + "|"},
+		{"X-API-Key", plaintext, "api_key:" + k.ID + "|tenant1"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set(tc.header, tc.value)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != tc.want {
+			t.Fatalf("%s: expected %q got %d %q", tc.header, tc.want, w.Code, w.Body)
+		}
+	}
+}
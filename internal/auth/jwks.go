@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"golang.org/x/sync/singleflight"
+)
+
+// maxJWKSSize bounds a fetched key set.
+const maxJWKSSize = 1 << 20
+
+// errReloadThrottled is returned by keySet.fetch within minRefresh of the
+// previous attempt.
+var errReloadThrottled = errors.New("jwks: reloaded too recently")
+
+// publicKey is a parsed JWK together with the algorithm it is pinned to, if any.
+type publicKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// loadJWKS reads a key set from an http(s) URL or a local file.
+func loadJWKS(ctx context.Context, client *http.Client, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+		return os.ReadFile(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: GET %s: %s", source, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// parseJWKS parses a JWK set, keyed by kid. Keys that are not signature keys or
+// are neither RSA nor EC are skipped; an empty result is an error.
+func parseJWKS(data []byte) (map[string]publicKey, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := map[string]publicKey{}
+	for _, raw := range set.Keys {
+		var head struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+		}
+		if err := json.Unmarshal(raw, &head); err != nil {
+			return nil, fmt.Errorf("jwks: %w", err)
+		}
+		if (head.Use != "" && head.Use != "sig") || (head.Kty != "RSA" && head.Kty != "EC") {
+			continue
+		}
+
+		var k jose.JSONWebKey
+		if err := k.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", head.Kid, err)
+		}
+		pub := k.Public()
+		switch pub.Key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys[k.KeyID] = publicKey{key: pub.Key, alg: k.Algorithm}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks: no usable signature keys")
+	}
+	return keys, nil
+}
+
+// keySet caches a JWKS source and reloads it periodically, or early when a token
+// names an unknown kid (key rotation), at most once per minRefresh.
+//
+// Reloads run in the background, one at a time (singleflight), and swap in the
+// new keys atomically, so verification keeps using the cached keys meanwhile.
+type keySet struct {
+	source     string
+	client     *http.Client
+	refresh    time.Duration
+	minRefresh time.Duration
+
+	cached atomic.Pointer[cachedKeys]
+	group  singleflight.Group
+
+	mu      sync.Mutex
+	triedAt time.Time
+}
+
+// cachedKeys is one successfully loaded version of the set.
+type cachedKeys struct {
+	keys      map[string]publicKey
+	fetchedAt time.Time
+}
+
+// get returns the key for kid. A missing kid matches the only key of a
+// single-key set.
+func (s *keySet) get(kid string) (publicKey, bool) {
+	c := s.cached.Load()
+	if c == nil {
+		return publicKey{}, false
+	}
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k, true
+		}
+	}
+	k, ok := c.keys[kid]
+	return k, ok
+}
+
+// stale reports whether the periodic refresh is due.
+func (s *keySet) stale(now time.Time) bool {
+	c := s.cached.Load()
+	return s.refresh > 0 && (c == nil || now.Sub(c.fetchedAt) >= s.refresh)
+}
+
+// reload starts a fetch, or joins the one in flight, and returns a channel
+// that receives its outcome. The fetch does not depend on any request, so a
+// caller may stop waiting at any time.
+func (s *keySet) reload(now time.Time) <-chan singleflight.Result {
+	return s.group.DoChan("jwks", func() (any, error) {
+		err := s.fetch(now)
+		if err != nil && !errors.Is(err, errReloadThrottled) {
+			slog.Warn("auth: jwks refresh failed, keeping cached keys", "err", err)
+		}
+		return nil, err
+	})
+}
+
+// fetch loads the set and swaps it in, keeping the current keys on failure.
+func (s *keySet) fetch(now time.Time) error {
+	s.mu.Lock()
+	if !s.triedAt.IsZero() && now.Sub(s.triedAt) < s.minRefresh {
+		s.mu.Unlock()
+		return errReloadThrottled
+	}
+	s.triedAt = now
+	s.mu.Unlock()
+
+	data, err := loadJWKS(context.Background(), s.client, s.source)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	s.cached.Store(&cachedKeys{keys: keys, fetchedAt: now})
+	return nil
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+)
+
+// clockSkew is tolerated when checking exp and nbf.
+const clockSkew = time.Minute
+
+// JWTConfig configures bearer token authentication.
+type JWTConfig struct {
+	JWKS        string        // http(s) URL or local file path of the issuer's JWK set
+	Issuer      string        // required iss
+	Audience    string        // required entry of aud
+	TenantClaim string        // claim holding the tenant ID, e.g. "tenant_id"
+	ScopeClaim  string        // claim holding space-separated scopes, e.g. "scope"
+	Refresh     time.Duration // how often the JWK set is reloaded
+}
+
+// signingMethods are the JWS algorithms accepted. Only asymmetric algorithms
+// are listed, which rules out "none" and HMAC confusion with a public key.
+var signingMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+}
+
+// JWTVerifier verifies RS*/PS*/ES*-signed JWTs against a cached JWK set.
+type JWTVerifier struct {
+	cfg  JWTConfig
+	keys *keySet
+	now  func() time.Time
+}
+
+// NewJWTVerifier loads the JWK set once so misconfiguration fails at startup.
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		cfg: cfg,
+		keys: &keySet{
+			source:     cfg.JWKS,
+			client:     &http.Client{Timeout: 5 * time.Second},
+			refresh:    cfg.Refresh,
+			minRefresh: 30 * time.Second,
+		},
+		now: time.Now,
+	}
+	if err := v.keys.fetch(v.now()); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// TokenClaims is what a verified token contributes to the request context.
+type TokenClaims struct {
+	TenantID string
+	Subject  string
+	Scopes   []string
+}
+
+// Verify checks the token's signature, issuer, audience and validity window and
+// returns its tenant and scopes. Errors are safe to show to the client.
+//
+// Tokens without recognised scopes get metrics:read only, since bearer tokens
+// are meant for dashboards rather than ingestion.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (TokenClaims, error) {
+	now := v.now()
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithAudience(v.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithJSONNumber(),
+	)
+
+	var keyErr error
+	parsed, err := parser.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.key(ctx, kid, now)
+		if err == nil && key.alg != "" && key.alg != t.Method.Alg() {
+			err = errors.New("token algorithm does not match key")
+		}
+		keyErr = err
+		return key.key, err
+	})
+	if err != nil {
+		return TokenClaims{}, tokenError(err, keyErr)
+	}
+	return v.checkClaims(parsed.Claims.(jwt.MapClaims))
+}
+
+// tokenError maps a jwt parse error to a message safe to show to the client.
+// keyErr is the error of the key lookup, if it failed.
+func tokenError(err, keyErr error) error {
+	switch {
+	case keyErr != nil:
+		return keyErr
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return errors.New("malformed token")
+	case errors.Is(err, jwt.ErrInvalidType):
+		return errors.New("malformed token claims")
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return errors.New("invalid token signature")
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return errors.New("invalid token issuer")
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return errors.New("invalid token audience")
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return errors.New("token expired")
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return errors.New("token not yet valid")
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return errors.New("token lacks a required claim (exp, iss or aud)")
+	}
+	return errors.New("invalid token")
+}
+
+// key returns the verification key for kid. A due periodic refresh runs in the
+// background while the cached keys keep serving. An unknown kid (the issuer
+// may have rotated keys) waits for a reload, at most one per minRefresh,
+// unless ctx ends first.
+func (v *JWTVerifier) key(ctx context.Context, kid string, now time.Time) (publicKey, error) {
+	if v.keys.stale(now) {
+		v.keys.reload(now)
+	}
+	if k, ok := v.keys.get(kid); ok {
+		return k, nil
+	}
+
+	select {
+	case <-v.keys.reload(now):
+	case <-ctx.Done():
+		return publicKey{}, errors.New("unknown signing key")
+	}
+	if k, ok := v.keys.get(kid); ok {
+		return k, nil
+	}
+	return publicKey{}, errors.New("unknown signing key")
+}
+
+func (v *JWTVerifier) checkClaims(claims jwt.MapClaims) (TokenClaims, error) {
+	tenantID, _ := claims[v.cfg.TenantClaim].(string)
+	if tenantID == "" {
+		return TokenClaims{}, fmt.Errorf("token has no %s claim", v.cfg.TenantClaim)
+	}
+
+	var scopes []string
+	for _, s := range claimStrings(claims[v.cfg.ScopeClaim]) {
+		if ValidScope(s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = []string{ScopeMetricsRead}
+	}
+
+	sub, _ := claims["sub"].(string)
+	return TokenClaims{TenantID: tenantID, Subject: sub, Scopes: scopes}, nil
+}
+
+// claimStrings reads a claim that is a space-separated string or a string array.
+func claimStrings(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return strings.Fields(t)
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, x := range t {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// authenticateBearer verifies an "Authorization: Bearer" token and stores the
+// tenant and scopes in c. On failure it aborts c with 401 and returns false.
+func authenticateBearer(c *gin.Context, v *JWTVerifier, token string) bool {
+	claims, err := v.Verify(c.Request.Context(), token)
+	if err != nil {
+		telemetry.AuthFailure("invalid_token")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid bearer token: " + err.Error()})
+		return false
+	}
+	c.Set(tenantCtxKey, claims.TenantID)
+	c.Set(scopesCtxKey, claims.Scopes)
+	c.Set(actorCtxKey, "jwt:"+claims.Subject)
+	return true
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header, if any.
+func bearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(c.GetHeader("Authorization")), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var b64 = base64.RawURLEncoding
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+		"n": b64.EncodeToString(pub.N.Bytes()),
+		"e": b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, pub *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": b64.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+		"y": b64.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	t.Helper()
+	b, _ := json.Marshal(map[string]any{"keys": keys})
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	input := jwtSigningInput(map[string]any{"alg": "RS256", "kid": kid}, claims)
+	sum := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	input := jwtSigningInput(map[string]any{"alg": "ES256", "kid": kid}, claims)
+	sum := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func jwtSigningInput(header, claims map[string]any) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	return b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+}
+
+func TestJWTVerifier_Claims(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK("rsa1", &rsaKey.PublicKey), ecJWK("ec1", &ecKey.PublicKey))
+
+	v, err := NewJWTVerifier(JWTConfig{
+		JWKS: path, Issuer: "https://idp.example", Audience: "analytics",
+		TenantClaim: "tenant_id", ScopeClaim: "scope", Refresh: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	v.now = func() time.Time { return now }
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss": "https://idp.example", "aud": []string{"other", "analytics"},
+			"exp": now.Add(time.Hour).Unix(), "sub": "alice", "tenant_id": "tenant1",
+		}
+	}
+
+	claims, err := v.Verify(context.Background(), signRS256(t, rsaKey, "rsa1", valid()))
+	if err != nil {
+		t.Fatalf("valid RS256 token rejected: %v", err)
+	}
+	if claims.TenantID != "tenant1" || claims.Subject != "alice" ||
+		len(claims.Scopes) != 1 || claims.Scopes[0] != ScopeMetricsRead {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	withScope := valid()
+	withScope["scope"] = "openid events:write metrics:read"
+	claims, err = v.Verify(context.Background(), signES256(t, ecKey, "ec1", withScope))
+	if err != nil {
+		t.Fatalf("valid ES256 token rejected: %v", err)
+	}
+	if strings.Join(claims.Scopes, " ") != "events:write metrics:read" {
+		t.Fatalf("scopes = %v", claims.Scopes)
+	}
+
+	cases := []struct {
+		want   string
+		mutate func(map[string]any)
+	}{
+		{"token expired", func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() }},
+		{"token lacks a required claim", func(c map[string]any) { delete(c, "exp") }},
+		{"token lacks a required claim", func(c map[string]any) { delete(c, "iss") }},
+		{"invalid token issuer", func(c map[string]any) { c["iss"] = "https://evil.example" }},
+		{"invalid token audience", func(c map[string]any) { c["aud"] = "other" }},
+		{"token not yet valid", func(c map[string]any) { c["nbf"] = now.Add(time.Hour).Unix() }},
+		{"no tenant_id claim", func(c map[string]any) { delete(c, "tenant_id") }},
+		{"malformed token", func(c map[string]any) { c["exp"] = "tomorrow" }},
+	}
+	for _, tc := range cases {
+		c := valid()
+		tc.mutate(c)
+		if _, err := v.Verify(context.Background(), signRS256(t, rsaKey, "rsa1", c)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: got err %v", tc.want, err)
+		}
+	}
+
+	// A token whose payload was altered after signing.
+	tok := signRS256(t, rsaKey, "rsa1", valid())
+	parts := strings.Split(tok, ".")
+	forged := valid()
+	forged["tenant_id"] = "tenant2"
+	body, _ := json.Marshal(forged)
+	parts[1] = b64.EncodeToString(body)
+	if _, err := v.Verify(context.Background(), strings.Join(parts, ".")); err == nil {
+		t.Fatal("tampered token accepted")
+	}
+
+	// alg=none must never verify.
+	none := jwtSigningInput(map[string]any{"alg": "none", "kid": "rsa1"}, valid()) + "."
+	if _, err := v.Verify(context.Background(), none); err == nil {
+		t.Fatal("unsigned token accepted")
+	}
+}
+
+func TestJWTVerifier_PicksUpRotatedKeys(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK("k1", &oldKey.PublicKey))
+
+	v, err := NewJWTVerifier(JWTConfig{
+		JWKS: path, Issuer: "iss", Audience: "aud", TenantClaim: "tenant_id", Refresh: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	claims := map[string]any{"iss": "iss", "aud": "aud", "exp": now.Add(time.Hour).Unix(), "tenant_id": "t1"}
+
+	// The issuer rotates to k2; an unknown kid triggers a reload.
+	writeJWKS(t, path, rsaJWK("k1", &oldKey.PublicKey), rsaJWK("k2", &newKey.PublicKey))
+	now = now.Add(time.Minute)
+	if _, err := v.Verify(context.Background(), signRS256(t, newKey, "k2", claims)); err != nil {
+		t.Fatalf("rotated key rejected: %v", err)
+	}
+	if _, err := v.Verify(context.Background(), signRS256(t, oldKey, "k1", claims)); err != nil {
+		t.Fatalf("old key rejected while still published: %v", err)
+	}
+}
+
+func TestJWTVerifier_Algorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	unpinned := rsaJWK("rsa-any", &rsaKey.PublicKey)
+	delete(unpinned, "alg")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK("rsa1", &rsaKey.PublicKey), unpinned, ecJWK("ec1", &ecKey.PublicKey),
+		map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"})
+
+	v, err := NewJWTVerifier(JWTConfig{
+		JWKS: path, Issuer: "iss", Audience: "aud", TenantClaim: "tenant_id", Refresh: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{"iss": "iss", "aud": "aud", "exp": time.Now().Add(time.Hour).Unix(), "tenant_id": "t1"}
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		tok := jwt.NewWithClaims(method, claims)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if _, err := v.Verify(context.Background(), sign(jwt.SigningMethodPS256, "rsa-any", rsaKey)); err != nil {
+		t.Fatalf("PS256 rejected: %v", err)
+	}
+	cases := []struct {
+		name, token, want string
+	}{
+		{"alg pinned by the key", sign(jwt.SigningMethodPS256, "rsa1", rsaKey), "token algorithm does not match key"},
+		{"curve of the key", sign(jwt.SigningMethodES384, "ec1", mustP384(t)), "invalid token signature"},
+		{"symmetric keys are skipped", sign(jwt.SigningMethodHS256, "hmac", []byte("secret")), "invalid token signature"},
+		{"public key as HMAC secret", sign(jwt.SigningMethodHS256, "rsa-any", b64.AppendEncode(nil, rsaKey.N.Bytes())), "invalid token signature"},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "nope", rsaKey), "unknown signing key"},
+	}
+	for _, tc := range cases {
+		if _, err := v.Verify(context.Background(), tc.token); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got err %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func mustP384(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// A slow JWKS fetch for an unknown kid neither holds up tokens signed with
+// cached keys nor is abandoned when the waiting request gives up.
+func TestJWTVerifier_ReloadDoesNotBlock(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var (
+		mu      sync.Mutex
+		keys    = []map[string]string{rsaJWK("k1", &oldKey.PublicKey)}
+		release = make(chan struct{})
+		blocked bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		wait, body := blocked, keys
+		mu.Unlock()
+		if wait {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": body})
+	}))
+	defer srv.Close()
+
+	v, err := NewJWTVerifier(JWTConfig{
+		JWKS: srv.URL, Issuer: "iss", Audience: "aud", TenantClaim: "tenant_id", Refresh: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v.now = func() time.Time { return now.Add(time.Minute) }
+	claims := map[string]any{"iss": "iss", "aud": "aud", "exp": now.Add(time.Hour).Unix(), "tenant_id": "t1"}
+
+	mu.Lock()
+	keys = append(keys, rsaJWK("k2", &newKey.PublicKey))
+	blocked = true
+	mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := v.Verify(ctx, signRS256(t, newKey, "k2", claims)); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("expected unknown signing key once the request gave up, got %v", err)
+	}
+	if _, err := v.Verify(context.Background(), signRS256(t, oldKey, "k1", claims)); err != nil {
+		t.Fatalf("cached key rejected during reload: %v", err)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := v.keys.get("k2"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned reload never completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := v.Verify(context.Background(), signRS256(t, newKey, "k2", claims)); err != nil {
+		t.Fatalf("rotated key rejected: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// Generated keys look like "eak_<id>_<secret>": id (16 hex chars) is the public
+// lookup handle stored in api_keys.id, secret (32 random bytes, base64url) is
+// only ever stored as a salted hash.
+const (
+	keyPrefix    = "eak_"
+	keyIDBytes   = 8
+	secretBytes  = 32
+	saltBytes    = 16
+	legacyPrefix = "env_"
+)
+
+// NewAPIKey generates a key for tenantID with the given scopes. It returns the
+// plaintext key, which must be shown to the caller once and then discarded, and
+// the row to store.
+func NewAPIKey(tenantID string, scopes []string) (string, store.APIKey, error) {
+	id, err := randomBytes(keyIDBytes)
+	if err != nil {
+		return "", store.APIKey{}, err
+	}
+	secret, err := randomBytes(secretBytes)
+	if err != nil {
+		return "", store.APIKey{}, err
+	}
+	salt, err := randomBytes(saltBytes)
+	if err != nil {
+		return "", store.APIKey{}, err
+	}
+
+	idHex := hex.EncodeToString(id)
+	secretText := base64.RawURLEncoding.EncodeToString(secret)
+
+	return keyPrefix + idHex + "_" + secretText, store.APIKey{
+		ID:       idHex,
+		TenantID: tenantID,
+		Salt:     salt,
+		Hash:     hashSecret(salt, secretText),
+		Scopes:   scopes,
+	}, nil
+}
+
+// LegacyAPIKey converts a plaintext API_KEYS entry into a storable key. Its id is
+// derived from the key itself, since such keys carry no id of their own.
+func LegacyAPIKey(key, tenantID string) (store.APIKey, error) {
+	salt, err := randomBytes(saltBytes)
+	if err != nil {
+		return store.APIKey{}, err
+	}
+	return store.APIKey{
+		ID:       legacyKeyID(key),
+		TenantID: tenantID,
+		Salt:     salt,
+		Hash:     hashSecret(salt, key),
+	}, nil
+}
+
+// parseAPIKey splits a presented key into its lookup id and the secret to verify.
+// Keys not in the generated format are treated as legacy API_KEYS entries.
+func parseAPIKey(key string) (id, secret string) {
+	if rest, ok := strings.CutPrefix(key, keyPrefix); ok {
+		if id, secret, ok := strings.Cut(rest, "_"); ok && id != "" && secret != "" {
+			return id, secret
+		}
+	}
+	return legacyKeyID(key), key
+}
+
+func legacyKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return legacyPrefix + hex.EncodeToString(sum[:keyIDBytes])
+}
+
+// hashSecret is SHA-256(salt || secret). Generated secrets carry 256 bits of
+// entropy, so a slow password hash would only add latency to every cache miss.
+func hashSecret(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
+
+// verifySecret reports whether secret matches the stored key, in constant time.
+func verifySecret(k store.APIKey, secret string) bool {
+	return subtle.ConstantTimeCompare(hashSecret(k.Salt, secret), k.Hash) == 1
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
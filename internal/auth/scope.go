@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+)
+
+// Scopes that can be granted to an API key.
+const (
+	ScopeEventsWrite = "events:write" // POST /events, POST /events/batch
+	ScopeMetricsRead = "metrics:read" // GET /metrics, POST /funnels, GET /retention
+	ScopeAdmin       = "admin"        // manage the tenant's own keys via /admin/keys
+)
+
+// DefaultScopes are granted to keys created without explicit scopes and to keys
+// stored with none (e.g. imported from API_KEYS). admin is never implied.
+var DefaultScopes = []string{ScopeEventsWrite, ScopeMetricsRead}
+
+// scopesCtxKey is the Gin context key holding the scopes of the authenticated key.
+const scopesCtxKey = "scopes"
+
+// ValidScope reports whether s is a known scope.
+func ValidScope(s string) bool {
+	switch s {
+	case ScopeEventsWrite, ScopeMetricsRead, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// effectiveScopes returns the scopes a stored key grants.
+func effectiveScopes(scopes []string) []string {
+	if len(scopes) == 0 {
+		return DefaultScopes
+	}
+	return scopes
+}
+
+// HasScope reports whether the authenticated key grants scope.
+func HasScope(c *gin.Context, scope string) bool {
+	v, _ := c.Get(scopesCtxKey)
+	scopes, _ := v.([]string)
+	return slices.Contains(scopes, scope)
+}
+
+// RequireScope rejects requests whose key lacks scope with 403.
+// It must run after Middleware or APIKeyMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScope(c, scope) {
+			telemetry.AuthFailure("missing_scope")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":          "api key is missing the " + scope + " scope",
+				"required_scope": scope,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,218 @@
+// Package cache caches GET /metrics results.
+//
+// Entries live in a Backend (an in-process LRU, or Redis shared by replicas)
+// and carry tags, so every entry of a tenant or of one of its event names can
+// be dropped at once when the underlying events change. Invalidations are
+// published on a Postgres notification channel, so every process writing or
+// deleting events (API replicas, cmd/worker) reaches the cache of every API
+// replica.
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// channel is the Postgres notification channel carrying invalidations.
+const channel = "metrics_cache"
+
+// Backend stores cache entries.
+type Backend interface {
+	// Get returns the value of key, or ok=false when it is missing or expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Generation returns a version of tags that changes whenever one of them
+	// is invalidated.
+	Generation(ctx context.Context, tags ...string) (int64, error)
+	// Set stores value under key for ttl and attaches it to tags, unless their
+	// Generation is no longer gen: the value was computed before an
+	// invalidation and may be stale, so it is dropped.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, gen int64, tags ...string) error
+	// InvalidateTag deletes every entry attached to tag.
+	InvalidateTag(ctx context.Context, tag string) error
+}
+
+// Options tunes a MetricsCache.
+type Options struct {
+	Horizon   time.Duration // windows ending before now-Horizon are "past" and rarely change
+	PastTTL   time.Duration // TTL of past windows; late events invalidate them
+	RecentTTL time.Duration // TTL of windows reaching into the last Horizon
+}
+
+// Tags attached to metrics entries.
+const allTag = "*"
+
+func tenantTag(tenantID string) string          { return "t:" + tenantID }
+func nameTag(tenantID, eventName string) string { return "n:" + tenantID + "\x00" + eventName }
+
+// tags returns the tags of the entries of a tenant's event name.
+func tags(tenantID, eventName string) []string {
+	return []string{allTag, tenantTag(tenantID), nameTag(tenantID, eventName)}
+}
+
+// invalidation is the wire format of a published invalidation. An empty
+// EventName covers the whole tenant, an empty TenantID every entry.
+type invalidation struct {
+	TenantID  string `json:"t,omitempty"`
+	EventName string `json:"n,omitempty"`
+}
+
+func (i invalidation) tag() string {
+	switch {
+	case i.TenantID == "":
+		return allTag
+	case i.EventName == "":
+		return tenantTag(i.TenantID)
+	default:
+		return nameTag(i.TenantID, i.EventName)
+	}
+}
+
+// invalidationFor returns the invalidation a change of stored events calls for.
+// Writes only invalidate entries of their event name when older than horizon,
+// as windows reaching into the horizon are only cached briefly.
+func invalidationFor(c store.Change, horizon time.Time) (invalidation, bool) {
+	if c.TenantID != "" && c.EventName != "" && !c.OldestTS.Before(horizon) {
+		return invalidation{}, false
+	}
+	return invalidation{TenantID: c.TenantID, EventName: c.EventName}, true
+}
+
+// publish sends inv to the caches of every replica.
+func publish(ctx context.Context, st *store.PostgresStore, inv invalidation) {
+	payload, err := json.Marshal(inv)
+	if err == nil {
+		err = st.Notify(context.WithoutCancel(ctx), channel, string(payload))
+	}
+	if err != nil {
+		slog.Error("cache: publishing invalidation failed", "tenant_id", inv.TenantID, "err", err)
+	}
+}
+
+// Broadcaster publishes the invalidations caused by the changes of a process
+// that writes or deletes events but serves no cached metrics (cmd/worker).
+type Broadcaster struct {
+	st      *store.PostgresStore
+	horizon time.Duration
+	now     func() time.Time
+}
+
+// NewBroadcaster creates a broadcaster for caches using opts.Horizon.
+func NewBroadcaster(st *store.PostgresStore, opts Options) *Broadcaster {
+	return &Broadcaster{st: st, horizon: opts.Horizon, now: time.Now}
+}
+
+// Changed is a store.ChangeHook.
+func (b *Broadcaster) Changed(ctx context.Context, c store.Change) {
+	if inv, ok := invalidationFor(c, b.now().Add(-b.horizon)); ok {
+		publish(ctx, b.st, inv)
+	}
+}
+
+// MetricsCache caches serialized metrics responses per tenant and query.
+type MetricsCache struct {
+	b    Backend
+	st   *store.PostgresStore // publishes and receives invalidations; nil keeps them local
+	opts Options
+	now  func() time.Time
+}
+
+// NewMetrics creates a metrics cache over b. With a non-nil st, invalidations
+// are published to, and (see Run) received from, every replica.
+func NewMetrics(b Backend, st *store.PostgresStore, opts Options) *MetricsCache {
+	return &MetricsCache{b: b, st: st, opts: opts, now: time.Now}
+}
+
+// TTL returns how long the result of a window ending at to may be cached.
+func (m *MetricsCache) TTL(to time.Time) time.Duration {
+	if to.Before(m.now().Add(-m.opts.Horizon)) {
+		return m.opts.PastTTL
+	}
+	return m.opts.RecentTTL
+}
+
+// Get returns a cached body and its remaining lifetime.
+func (m *MetricsCache) Get(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	v, ok, err := m.b.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, 0, false, err
+	}
+	if len(v) < 8 {
+		return nil, 0, false, errors.New("cache: malformed entry")
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+	left := expires.Sub(m.now())
+	if left <= 0 {
+		return nil, 0, false, nil
+	}
+	return v[8:], left, true, nil
+}
+
+// Generation returns the version of the entries of a tenant's event name. Read
+// it before computing a result and pass it to Set.
+func (m *MetricsCache) Generation(ctx context.Context, tenantID, eventName string) (int64, error) {
+	return m.b.Generation(ctx, tags(tenantID, eventName)...)
+}
+
+// Set caches body for a query of tenantID over eventName for ttl, unless the
+// entries of that name were invalidated since gen was read.
+func (m *MetricsCache) Set(ctx context.Context, key, tenantID, eventName string, gen int64, body []byte, ttl time.Duration) error {
+	v := make([]byte, 8+len(body))
+	binary.BigEndian.PutUint64(v, uint64(m.now().Add(ttl).UnixNano()))
+	copy(v[8:], body)
+	return m.b.Set(ctx, key, v, ttl, gen, tags(tenantID, eventName)...)
+}
+
+// Changed invalidates the entries a change of stored events may have affected,
+// here and on every replica; it is a store.ChangeHook. Failures are logged,
+// entries then live until their TTL.
+func (m *MetricsCache) Changed(ctx context.Context, c store.Change) {
+	inv, ok := invalidationFor(c, m.now().Add(-m.opts.Horizon))
+	if !ok {
+		return
+	}
+	m.invalidate(context.WithoutCancel(ctx), inv.tag())
+	if m.st != nil {
+		publish(ctx, m.st, inv)
+	}
+}
+
+// Run applies the invalidations published by every process until ctx is
+// cancelled. Invalidations sent while the listening connection is down are
+// missed, so everything is invalidated whenever it is (re-)established.
+func (m *MetricsCache) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		m.invalidate(ctx, allTag)
+		err := m.st.Listen(ctx, channel, m.deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("cache: listening for invalidations failed", "err", err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// deliver applies one published invalidation.
+func (m *MetricsCache) deliver(payload string) {
+	var inv invalidation
+	if err := json.Unmarshal([]byte(payload), &inv); err != nil {
+		slog.Error("cache: malformed invalidation", "err", err)
+		return
+	}
+	m.invalidate(context.Background(), inv.tag())
+}
+
+func (m *MetricsCache) invalidate(ctx context.Context, tag string) {
+	if err := m.b.InvalidateTag(ctx, tag); err != nil {
+		slog.Error("cache: invalidation failed", "tag", tag, "err", err)
+	}
+}
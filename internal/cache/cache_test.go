@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+
+	_ = l.Set(ctx, "a", []byte("1"), time.Minute, 0, "x")
+	_ = l.Set(ctx, "b", []byte("2"), time.Minute, 0, "x")
+	if _, ok, _ := l.Get(ctx, "a"); !ok {
+		t.Fatal("a missing")
+	}
+	_ = l.Set(ctx, "c", []byte("3"), time.Minute, 0)
+
+	if _, ok, _ := l.Get(ctx, "b"); ok {
+		t.Fatal("b should have been evicted")
+	}
+	if l.Len() != 2 {
+		t.Fatalf("len %d", l.Len())
+	}
+
+	// Evicted keys leave the tag index too.
+	_ = l.InvalidateTag(ctx, "x")
+	if _, ok, _ := l.Get(ctx, "a"); ok {
+		t.Fatal("a should have been invalidated")
+	}
+	if _, ok, _ := l.Get(ctx, "c"); !ok {
+		t.Fatal("untagged c should survive")
+	}
+	if len(l.tags) != 0 {
+		t.Fatalf("tag index not cleaned up: %v", l.tags)
+	}
+}
+
+func TestLRU_Expiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	l := NewLRU(10)
+	l.now = func() time.Time { return now }
+
+	_ = l.Set(ctx, "a", []byte("1"), time.Second, 0)
+	now = now.Add(time.Second)
+	if _, ok, _ := l.Get(ctx, "a"); ok || l.Len() != 0 {
+		t.Fatal("expired entry returned")
+	}
+}
+
+func TestMetricsCache_TTLAndInvalidation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	m := NewMetrics(NewLRU(100), nil, Options{Horizon: time.Hour, PastTTL: 24 * time.Hour, RecentTTL: 10 * time.Second})
+	m.now = func() time.Time { return now }
+
+	if ttl := m.TTL(now.Add(-2 * time.Hour)); ttl != 24*time.Hour {
+		t.Fatalf("past window ttl %s", ttl)
+	}
+	if ttl := m.TTL(now.Add(-30 * time.Minute)); ttl != 10*time.Second {
+		t.Fatalf("recent window ttl %s", ttl)
+	}
+
+	_ = m.Set(ctx, "k1", "t1", "signup", 0, []byte(`{"count":1}`), time.Hour)
+	_ = m.Set(ctx, "k2", "t1", "login", 0, []byte(`{"count":2}`), time.Hour)
+	_ = m.Set(ctx, "k3", "t2", "signup", 0, []byte(`{"count":3}`), time.Hour)
+
+	body, left, ok, err := m.Get(ctx, "k1")
+	if err != nil || !ok || string(body) != `{"count":1}` || left != time.Hour {
+		t.Fatalf("get: %q %s %v %v", body, left, ok, err)
+	}
+
+	present := func(key string) bool {
+		_, _, ok, _ := m.Get(ctx, key)
+		return ok
+	}
+
+	// An on-time event leaves cached windows alone; they expire on their own.
+	m.Changed(ctx, store.Change{TenantID: "t1", EventName: "signup", OldestTS: now.Add(-time.Minute), Written: 1})
+	if !present("k1") {
+		t.Fatal("on-time event invalidated k1")
+	}
+
+	// A late event drops the entries of its tenant and name only.
+	m.Changed(ctx, store.Change{TenantID: "t1", EventName: "signup", OldestTS: now.Add(-3 * time.Hour), Written: 1})
+	if present("k1") || !present("k2") || !present("k3") {
+		t.Fatal("late event invalidated the wrong entries")
+	}
+
+	// Deletions drop a whole tenant, or everything.
+	m.Changed(ctx, store.Change{TenantID: "t1"})
+	if present("k2") || !present("k3") {
+		t.Fatal("tenant deletion invalidated the wrong entries")
+	}
+	m.Changed(ctx, store.Change{})
+	if present("k3") {
+		t.Fatal("global deletion kept k3")
+	}
+}
+
+func TestMetricsCache_DropsFillsRacingInvalidation(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics(NewLRU(100), nil, Options{Horizon: time.Hour})
+
+	// A result computed before a late event arrived must not be cached after it.
+	gen, err := m.Generation(ctx, "t1", "signup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Changed(ctx, store.Change{TenantID: "t1", EventName: "signup", OldestTS: time.Now().Add(-3 * time.Hour), Written: 1})
+	_ = m.Set(ctx, "k1", "t1", "signup", gen, []byte(`{"count":1}`), time.Hour)
+	if _, _, ok, _ := m.Get(ctx, "k1"); ok {
+		t.Fatal("stale fill was cached")
+	}
+
+	// Other names and tenants keep their generation.
+	gen, _ = m.Generation(ctx, "t1", "login")
+	other, _ := m.Generation(ctx, "t2", "signup")
+	m.Changed(ctx, store.Change{TenantID: "t1", EventName: "signup", OldestTS: time.Now().Add(-3 * time.Hour), Written: 1})
+	_ = m.Set(ctx, "k2", "t1", "login", gen, []byte(`{"count":2}`), time.Hour)
+	_ = m.Set(ctx, "k3", "t2", "signup", other, []byte(`{"count":3}`), time.Hour)
+	if _, _, ok, _ := m.Get(ctx, "k2"); !ok {
+		t.Fatal("fill of an unaffected name dropped")
+	}
+	if _, _, ok, _ := m.Get(ctx, "k3"); !ok {
+		t.Fatal("fill of an unaffected tenant dropped")
+	}
+
+	// A tenant-wide invalidation, as by an erasure, moves every name of it.
+	gen, _ = m.Generation(ctx, "t1", "login")
+	m.Changed(ctx, store.Change{TenantID: "t1"})
+	_ = m.Set(ctx, "k2", "t1", "login", gen, []byte(`{"count":2}`), time.Hour)
+	if _, _, ok, _ := m.Get(ctx, "k2"); ok {
+		t.Fatal("fill racing a tenant invalidation was cached")
+	}
+}
+
+func TestInvalidation_Tags(t *testing.T) {
+	horizon := time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC)
+	tests := []struct {
+		change store.Change
+		want   string
+		ok     bool
+	}{
+		{store.Change{}, allTag, true},
+		{store.Change{TenantID: "t1"}, tenantTag("t1"), true},
+		{store.Change{TenantID: "t1", EventName: "signup", OldestTS: horizon.Add(-time.Second)}, nameTag("t1", "signup"), true},
+		{store.Change{TenantID: "t1", EventName: "signup", OldestTS: horizon}, "", false},
+	}
+	for _, tt := range tests {
+		inv, ok := invalidationFor(tt.change, horizon)
+		if ok != tt.ok || (ok && inv.tag() != tt.want) {
+			t.Errorf("invalidationFor(%+v) = %q %v, want %q %v", tt.change, inv.tag(), ok, tt.want, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		// The tag survives the trip through a notification payload.
+		payload, _ := json.Marshal(inv)
+		var got invalidation
+		if err := json.Unmarshal(payload, &got); err != nil || got.tag() != tt.want {
+			t.Errorf("payload %s decodes to %q, want %q", payload, got.tag(), tt.want)
+		}
+	}
+}
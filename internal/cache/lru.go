@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process Backend holding at most a fixed number of entries,
+// evicting the least recently used. Each replica has its own.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front = most recently used
+	items    map[string]*list.Element
+	tags     map[string]map[string]struct{} // tag -> keys
+	gens     map[string]int64               // tag -> invalidations
+	now      func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+// NewLRU creates an LRU holding up to capacity entries.
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+		tags:     map[string]map[string]struct{}{},
+		gens:     map[string]int64{},
+		now:      time.Now,
+	}
+}
+
+// Get implements Backend.
+func (l *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if !l.now().Before(e.expires) {
+		l.remove(el)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+// Generation implements Backend.
+func (l *LRU) Generation(_ context.Context, tags ...string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.generation(tags), nil
+}
+
+// Set implements Backend.
+func (l *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration, gen int64, tags ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.generation(tags) != gen {
+		return nil
+	}
+	if el, ok := l.items[key]; ok {
+		l.remove(el)
+	}
+
+	e := &lruEntry{key: key, value: value, expires: l.now().Add(ttl), tags: tags}
+	l.items[key] = l.order.PushFront(e)
+	for _, t := range tags {
+		keys := l.tags[t]
+		if keys == nil {
+			keys = map[string]struct{}{}
+			l.tags[t] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for l.order.Len() > l.capacity {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+// InvalidateTag implements Backend.
+func (l *LRU) InvalidateTag(_ context.Context, tag string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key := range l.tags[tag] {
+		if el, ok := l.items[key]; ok {
+			l.remove(el)
+		}
+	}
+	delete(l.tags, tag)
+	l.gens[tag]++
+	return nil
+}
+
+// Len returns the number of entries, including expired ones not yet evicted.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// generation sums the invalidations of tags. l.mu must be held.
+func (l *LRU) generation(tags []string) int64 {
+	var gen int64
+	for _, t := range tags {
+		gen += l.gens[t]
+	}
+	return gen
+}
+
+// remove drops el and its tag references. l.mu must be held.
+func (l *LRU) remove(el *list.Element) {
+	e := l.order.Remove(el).(*lruEntry)
+	delete(l.items, e.key)
+	for _, t := range e.tags {
+		if keys := l.tags[t]; keys != nil {
+			delete(keys, e.key)
+			if len(keys) == 0 {
+				delete(l.tags, t)
+			}
+		}
+	}
+}
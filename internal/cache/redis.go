@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis is a Backend over a Redis (or RESP-compatible) server, shared by all
+// replicas so an invalidation by one of them, or by cmd/worker, reaches every
+// replica. Entries are plain keys; each tag is a set of keys with a counter of
+// its invalidations. Writes and invalidations are Lua scripts, so each runs
+// atomically.
+type Redis struct {
+	addr    string
+	prefix  string
+	timeout time.Duration
+	conns   chan *redisConn
+}
+
+// NewRedis creates a Redis backend for addr ("host:port"), namespacing keys
+// with prefix and keeping up to poolSize idle connections.
+func NewRedis(addr, prefix string, poolSize int) *Redis {
+	return &Redis{
+		addr:    addr,
+		prefix:  prefix,
+		timeout: 2 * time.Second,
+		conns:   make(chan *redisConn, poolSize),
+	}
+}
+
+// Get implements Backend.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := r.do(ctx, "GET", r.prefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	b, ok := v.([]byte)
+	return b, ok, nil
+}
+
+// setScript stores an entry unless the generation of its tags moved. KEYS are
+// the entry, then the set and the counter of each tag; ARGV the value, the
+// TTL in milliseconds, the expected generation and the unprefixed key. Tag
+// sets live as long as their longest entry.
+var setScript = newRedisScript(`
+local n = (#KEYS - 1) / 2
+local gen = 0
+for i = 1, n do
+	gen = gen + (tonumber(redis.call('GET', KEYS[1 + n + i])) or 0)
+end
+if gen ~= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+for i = 1, n do
+	redis.call('SADD', KEYS[1 + i], ARGV[4])
+	if redis.call('PTTL', KEYS[1 + i]) < tonumber(ARGV[2]) then
+		redis.call('PEXPIRE', KEYS[1 + i], ARGV[2])
+	end
+end
+return 1`)
+
+// invalidateScript deletes the entries of a tag and bumps its counter. KEYS
+// are the set and the counter of the tag; ARGV the key prefix.
+var invalidateScript = newRedisScript(`
+local keys = redis.call('SMEMBERS', KEYS[1])
+for _, k in ipairs(keys) do
+	redis.call('DEL', ARGV[1] .. k)
+end
+redis.call('DEL', KEYS[1])
+redis.call('INCR', KEYS[2])
+return #keys`)
+
+// Generation implements Backend.
+func (r *Redis) Generation(ctx context.Context, tags ...string) (int64, error) {
+	args := make([]string, 0, len(tags)+1)
+	args = append(args, "MGET")
+	for _, t := range tags {
+		args = append(args, r.prefix+"gen:"+t)
+	}
+	v, err := r.do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	values, _ := v.([]interface{})
+
+	var gen int64
+	for _, c := range values {
+		if b, ok := c.([]byte); ok {
+			n, err := strconv.ParseInt(string(b), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			gen += n
+		}
+	}
+	return gen, nil
+}
+
+// Set implements Backend.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration, gen int64, tags ...string) error {
+	keys := make([]string, 0, 1+2*len(tags))
+	keys = append(keys, r.prefix+key)
+	for _, t := range tags {
+		keys = append(keys, r.prefix+"tag:"+t)
+	}
+	for _, t := range tags {
+		keys = append(keys, r.prefix+"gen:"+t)
+	}
+	_, err := r.eval(ctx, setScript, keys,
+		string(value), strconv.FormatInt(ttl.Milliseconds(), 10), strconv.FormatInt(gen, 10), key)
+	return err
+}
+
+// InvalidateTag implements Backend.
+func (r *Redis) InvalidateTag(ctx context.Context, tag string) error {
+	_, err := r.eval(ctx, invalidateScript, []string{r.prefix + "tag:" + tag, r.prefix + "gen:" + tag}, r.prefix)
+	return err
+}
+
+// redisScript is a Lua script run with EVALSHA, loaded on first use.
+type redisScript struct {
+	src, sha string
+}
+
+func newRedisScript(src string) *redisScript {
+	sum := sha1.Sum([]byte(src))
+	return &redisScript{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+// eval runs s over keys and args, sending its source when the server does not
+// have it cached yet.
+func (r *Redis) eval(ctx context.Context, s *redisScript, keys []string, args ...string) (interface{}, error) {
+	cmd := make([]string, 0, 3+len(keys)+len(args))
+	cmd = append(cmd, "EVALSHA", s.sha, strconv.Itoa(len(keys)))
+	cmd = append(append(cmd, keys...), args...)
+
+	v, err := r.do(ctx, cmd...)
+	var replyErr redisError
+	if errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "NOSCRIPT") {
+		cmd[0], cmd[1] = "EVAL", s.src
+		v, err = r.do(ctx, cmd...)
+	}
+	return v, err
+}
+
+// Close closes the idle connections.
+func (r *Redis) Close() {
+	for {
+		select {
+		case c := <-r.conns:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
+
+// redisConn is one connection speaking RESP.
+type redisConn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+// redisError is an error reply of the server; the connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// do runs one command and returns its reply: nil, int64, []byte (bulk and
+// simple strings) or []interface{}.
+func (r *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = c.SetDeadline(deadline)
+
+	v, err := c.roundTrip(args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.Close()
+		return nil, err
+	}
+	r.put(c)
+	return v, err
+}
+
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.conns:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Timeout: r.timeout}
+	nc, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &redisConn{Conn: nc, rd: bufio.NewReader(nc)}, nil
+}
+
+func (r *Redis) put(c *redisConn) {
+	select {
+	case r.conns <- c:
+	default:
+		c.Close()
+	}
+}
+
+func (c *redisConn) roundTrip(args []string) (interface{}, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, a := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(a)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, a...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+	return readReply(c.rd)
+}
+
+// readReply parses one RESP2 reply.
+func readReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return []byte(body), nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		out := make([]interface{}, n)
+		for i := range out {
+			v, err := readReply(rd)
+			var replyErr redisError
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadReply(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want interface{}
+	}{
+		{"+OK\r\n", []byte("OK")},
+		{":42\r\n", int64(42)},
+		{"$5\r\nhello\r\n", []byte("hello")},
+		{"$-1\r\n", nil},
+		{"*2\r\n$1\r\na\r\n:1\r\n", []interface{}{[]byte("a"), int64(1)}},
+		{"*0\r\n", []interface{}{}},
+	}
+	for _, tc := range cases {
+		got, err := readReply(bufio.NewReader(strings.NewReader(tc.raw)))
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("readReply(%q) = %#v, %v", tc.raw, got, err)
+		}
+	}
+
+	if _, err := readReply(bufio.NewReader(strings.NewReader("-ERR wrong type\r\n"))); err == nil ||
+		err.Error() != "redis: ERR wrong type" {
+		t.Fatalf("error reply: %v", err)
+	}
+}
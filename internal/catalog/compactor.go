@@ -0,0 +1,64 @@
+// Package catalog keeps the event catalog up to date by folding in the deltas
+// appended by ingestion in the background.
+package catalog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// maxBatch bounds the deltas one compaction transaction folds per table, so a
+// backlog (e.g. after an ingestion burst) is worked off in short transactions.
+const maxBatch = 10000
+
+// Options tunes the compactor.
+type Options struct {
+	Interval time.Duration // wait between passes once caught up
+}
+
+// Compactor folds new catalog entries into the catalog.
+type Compactor struct {
+	st   *store.PostgresStore
+	opts Options
+}
+
+// New creates a compactor over the given store.
+func New(st *store.PostgresStore, opts Options) *Compactor {
+	return &Compactor{st: st, opts: opts}
+}
+
+// Run compacts until ctx is cancelled. Steps are taken back to back while
+// behind, then every Interval. Passes hold an advisory lock, so every replica
+// can run a compactor.
+func (c *Compactor) Run(ctx context.Context) {
+	for {
+		caughtUp, err := c.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("catalog: compaction failed", "err", err)
+		}
+
+		if !caughtUp && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.opts.Interval):
+		}
+	}
+}
+
+// RunOnce performs one compaction step and reports whether the catalog caught up.
+func (c *Compactor) RunOnce(ctx context.Context) (bool, error) {
+	rows, caughtUp, err := c.st.CompactCatalog(ctx, maxBatch)
+	if err != nil {
+		return false, err
+	}
+	if !caughtUp {
+		slog.Info("catalog: compacting backlog", "rows", rows)
+	}
+	return caughtUp, nil
+}
@@ -1,25 +1,162 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
+	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// Ingestion modes selectable via INGEST_MODE.
+const (
+	IngestModeInline = "inline" // POST /events writes to events before responding
+	IngestModeAsync  = "async"  // POST /events appends to event_queue and returns 202
+)
+
+// TenantLimits are the ingestion limits of one tenant. Zero means unlimited.
+// Bursts default to the per-second rate (at least 1) when unset.
+type TenantLimits struct {
+	RequestsPerSec float64 `json:"requests_per_sec"`
+	RequestBurst   int     `json:"request_burst"`
+	EventsPerSec   float64 `json:"events_per_sec"`
+	EventBurst     int     `json:"event_burst"`
+	DailyEvents    int64   `json:"daily_events"`
+	MonthlyEvents  int64   `json:"monthly_events"`
+}
+
+// defaultTenantKey is the RATE_LIMITS / RETENTION_POLICIES / SCHEMA_VALIDATION
+// entry applied to tenants without their own entry.
+const defaultTenantKey = "*"
+
 // Config contains runtime configuration required by the service.
 type Config struct {
-	DBURL   string
-	APIKeys map[string]string // apiKey -> tenantID
+	DBURL       string
+	LogLevel    slog.Level        // minimum level of the JSON logs
+	AutoMigrate bool              // apply pending migrations on boot
+	APIKeys     map[string]string // apiKey -> tenantID, synced into api_keys on boot
+	DevAPIKeys  map[string]string // apiKey -> tenantID, accepted from memory only
+
+	HTTPAddr         string
+	HTTPReadTimeout  time.Duration // reading a whole request, body included
+	HTTPWriteTimeout time.Duration // writing a response; streams set per-write deadlines instead
+	HTTPIdleTimeout  time.Duration // keep-alive connections between requests
+	ShutdownDelay    time.Duration // /ready reports 503 this long before the listener closes
+	ShutdownTimeout  time.Duration // deadline for draining requests and background jobs
+
+	AdminAPIKey    string        // enables the /admin API when set
+	APIKeyCacheTTL time.Duration // how long key validation results are cached
+
+	// Bearer JWT authentication, enabled when JWTJWKS is set.
+	JWTJWKS        string // JWK set URL or file path
+	JWTIssuer      string
+	JWTAudience    string
+	JWTTenantClaim string
+	JWTScopeClaim  string
+	JWTJWKSRefresh time.Duration
+
+	IngestMode   string
+	IngestWorker bool // run the queue worker inside the API process (async mode)
+
+	WorkerBatchSize    int
+	WorkerPollInterval time.Duration
+	WorkerMaxAttempts  int
+
+	PartitionMaintainer bool   // run partition maintenance inside the API process
+	PartitionInterval   string // "day" or "month"
+	PartitionAhead      int
+	EventRetention      time.Duration // 0 keeps events forever
+	PartitionDetach     bool          // detach expired partitions instead of dropping them
+
+	DefaultLimits TenantLimits
+	TenantLimits  map[string]TenantLimits // tenantID -> limits (replaces DefaultLimits)
+
+	SchemaValidation       string            // SchemaValidation* mode of tenants without their own
+	TenantSchemaValidation map[string]string // tenantID -> mode (replaces SchemaValidation)
+	SchemaCacheTTL         time.Duration     // how long a tenant's compiled schemas are reused
+
+	PurgeJob         bool                     // run the retention purge job inside the API process
+	DefaultRetention time.Duration            // per-tenant retention when a tenant has no policy; 0 keeps events
+	TenantRetention  map[string]time.Duration // tenantID -> retention (replaces DefaultRetention)
+	PurgeDryRun      bool                     // scheduled purges only report what they would delete
+	PurgeBatchSize   int
+	PurgeInterval    time.Duration
+
+	PrivacyRunner    bool          // process GDPR erasure/export jobs inside the API process
+	PrivacyExportTTL time.Duration // how long finished exports can be downloaded
+
+	RollupCompactor bool          // maintain the hourly metrics rollups inside the API process
+	RollupInterval  time.Duration // wait between compaction passes
+
+	CatalogCompactor bool          // fold ingested events into the event catalog inside the API process
+	CatalogInterval  time.Duration // wait between catalog compaction passes
+
+	MetricsCache          string        // MetricsCacheMemory, MetricsCacheRedis or MetricsCacheOff
+	MetricsCacheSize      int           // entries kept by the in-memory cache
+	MetricsCacheRedisAddr string        // host:port of the Redis backend
+	MetricsCacheHorizon   time.Duration // lateness horizon: windows ending earlier are cached long-term
+	MetricsCachePastTTL   time.Duration
+	MetricsCacheRecentTTL time.Duration
+
+	LiveStreams       bool          // serve GET /metrics/stream and publish ingestion counts
+	LiveMaxStreams    int           // concurrent streams per tenant and replica
+	LiveFlushInterval time.Duration // how often ingestion counts are published
+
+	TelemetryAddr string // listen address of GET /internal/metrics; empty disables it
+
+	TracingExporter    string  // TracingNone, TracingOTLP, TracingOTLPGRPC, TracingStdout or TracingFile
+	TracingEndpoint    string  // OTLP base URL, e.g. http://otel-collector:4318; empty uses the exporter's default
+	TracingFile        string  // output path of the file exporter
+	TracingServiceName string  // service.name reported with every span
+	TracingSampleRatio float64 // share of new traces recorded; incoming sampled traces are kept
 }
 
+// Event schema validation modes selectable per tenant via SCHEMA_VALIDATION.
+const (
+	SchemaValidationOff     = "off"     // schemas are not checked
+	SchemaValidationLenient = "lenient" // invalid events are accepted and flagged in the response
+	SchemaValidationStrict  = "strict"  // invalid or unregistered events are rejected with 422
+)
+
+// Trace exporters.
+const (
+	TracingNone     = "none"
+	TracingOTLP     = "otlp"
+	TracingOTLPGRPC = "otlp-grpc"
+	TracingStdout   = "stdout"
+	TracingFile     = "file"
+)
+
+// Metrics cache backends.
+const (
+	MetricsCacheMemory = "memory"
+	MetricsCacheRedis  = "redis"
+	MetricsCacheOff    = "off"
+)
+
 // Load reads required values from environment variables.
-// API_KEYS format: "tenant1:key1,tenant2:key2"
+// API_KEYS format: "tenant1:key1,tenant2:key2" (bootstrap keys; manage the rest via /admin/keys)
+// RATE_LIMITS format: JSON object of tenantID -> TenantLimits, "*" being the default.
+// RETENTION_POLICIES format: JSON object of tenantID -> retention ("90d", "0" keeps), "*" being the default.
+// SCHEMA_VALIDATION format: JSON object of tenantID -> "off", "lenient" or "strict", "*" being the default.
 func Load() (Config, error) {
 	dbURL := strings.TrimSpace(os.Getenv("DB_URL"))
 	if dbURL == "" {
 		return Config{}, errors.New("DB_URL required")
 	}
 
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(envString("LOG_LEVEL", "info"))); err != nil {
+		return Config{}, errors.New(`LOG_LEVEL must be "debug", "info", "warn" or "error"`)
+	}
+
+	autoMigrate, err := envBool("AUTO_MIGRATE", true)
+	if err != nil {
+		return Config{}, err
+	}
+
 	apiKeysRaw := strings.TrimSpace(os.Getenv("API_KEYS"))
 	apiKeys := map[string]string{}
 
@@ -43,13 +180,463 @@ func Load() (Config, error) {
 		}
 	}
 
-	// Local dev fallback so the service runs out-of-the-box.
-	if len(apiKeys) == 0 {
-		apiKeys["tenant-key-123"] = "tenant1"
+	readTimeout, err := envDuration("HTTP_READ_TIMEOUT", 15*time.Second)
+	if err != nil || readTimeout <= 0 {
+		return Config{}, errors.New("HTTP_READ_TIMEOUT must be a positive duration")
+	}
+
+	writeTimeout, err := envDuration("HTTP_WRITE_TIMEOUT", 30*time.Second)
+	if err != nil || writeTimeout <= 0 {
+		return Config{}, errors.New("HTTP_WRITE_TIMEOUT must be a positive duration")
+	}
+
+	idleTimeout, err := envDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute)
+	if err != nil || idleTimeout <= 0 {
+		return Config{}, errors.New("HTTP_IDLE_TIMEOUT must be a positive duration")
+	}
+
+	// Kubernetes waits terminationGracePeriodSeconds (30s by default) before
+	// SIGKILL; delay plus timeout should stay below it.
+	shutdownTimeout, err := envDuration("SHUTDOWN_TIMEOUT", 20*time.Second)
+	if err != nil || shutdownTimeout <= 0 {
+		return Config{}, errors.New("SHUTDOWN_TIMEOUT must be a positive duration")
+	}
+
+	shutdownDelay, err := envDuration("SHUTDOWN_DELAY", 5*time.Second)
+	if err != nil || shutdownDelay < 0 {
+		return Config{}, errors.New("SHUTDOWN_DELAY must be a non-negative duration")
+	}
+
+	adminAPIKey := envString("ADMIN_API_KEY", "")
+
+	// Local dev fallback so the service runs out-of-the-box. It is never stored,
+	// so it stops working as soon as API_KEYS or ADMIN_API_KEY is set.
+	devAPIKeys := map[string]string{}
+	if len(apiKeys) == 0 && adminAPIKey == "" {
+		devAPIKeys["tenant-key-123"] = "tenant1"
+	}
+
+	apiKeyCacheTTL, err := envDuration("API_KEY_CACHE_TTL", 30*time.Second)
+	if err != nil || apiKeyCacheTTL < 0 {
+		return Config{}, errors.New("API_KEY_CACHE_TTL must be a non-negative duration")
+	}
+
+	jwtJWKS := envString("JWT_JWKS", "")
+	jwtIssuer := envString("JWT_ISSUER", "")
+	jwtAudience := envString("JWT_AUDIENCE", "")
+	if jwtJWKS != "" && (jwtIssuer == "" || jwtAudience == "") {
+		return Config{}, errors.New("JWT_ISSUER and JWT_AUDIENCE are required when JWT_JWKS is set")
+	}
+
+	jwksRefresh, err := envDuration("JWT_JWKS_REFRESH", 10*time.Minute)
+	if err != nil || jwksRefresh <= 0 {
+		return Config{}, errors.New("JWT_JWKS_REFRESH must be a positive duration")
+	}
+
+	ingestMode := envString("INGEST_MODE", IngestModeInline)
+	if ingestMode != IngestModeInline && ingestMode != IngestModeAsync {
+		return Config{}, errors.New(`INGEST_MODE must be "inline" or "async"`)
+	}
+
+	ingestWorker, err := envBool("INGEST_WORKER", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	batchSize, err := envInt("WORKER_BATCH_SIZE", 500)
+	if err != nil || batchSize < 1 {
+		return Config{}, errors.New("WORKER_BATCH_SIZE must be a positive integer")
+	}
+
+	pollInterval, err := envDuration("WORKER_POLL_INTERVAL", 500*time.Millisecond)
+	if err != nil || pollInterval <= 0 {
+		return Config{}, errors.New("WORKER_POLL_INTERVAL must be a positive duration")
+	}
+
+	maxAttempts, err := envInt("WORKER_MAX_ATTEMPTS", 10)
+	if err != nil || maxAttempts < 1 {
+		return Config{}, errors.New("WORKER_MAX_ATTEMPTS must be a positive integer")
+	}
+
+	partitionMaintainer, err := envBool("PARTITION_MAINTAINER", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	partitionInterval := envString("PARTITION_INTERVAL", "month")
+	if partitionInterval != "day" && partitionInterval != "month" {
+		return Config{}, errors.New(`PARTITION_INTERVAL must be "day" or "month"`)
+	}
+
+	partitionAhead, err := envInt("PARTITION_PREMAKE", 3)
+	if err != nil || partitionAhead < 1 {
+		return Config{}, errors.New("PARTITION_PREMAKE must be a positive integer")
+	}
+
+	retention, err := envDays("EVENT_RETENTION", 0)
+	if err != nil || retention < 0 {
+		return Config{}, errors.New(`EVENT_RETENTION must be a non-negative duration (e.g. "90d", "2160h")`)
+	}
+
+	expireMode := envString("PARTITION_EXPIRE_MODE", "drop")
+	if expireMode != "drop" && expireMode != "detach" {
+		return Config{}, errors.New(`PARTITION_EXPIRE_MODE must be "drop" or "detach"`)
+	}
+
+	defaultLimits, tenantLimits, err := parseRateLimits(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		return Config{}, err
+	}
+
+	schemaValidation, tenantSchemaValidation, err := parseSchemaValidation(os.Getenv("SCHEMA_VALIDATION"))
+	if err != nil {
+		return Config{}, err
+	}
+
+	schemaCacheTTL, err := envDuration("SCHEMA_CACHE_TTL", 30*time.Second)
+	if err != nil || schemaCacheTTL < 0 {
+		return Config{}, errors.New("SCHEMA_CACHE_TTL must be a non-negative duration")
+	}
+
+	purgeJob, err := envBool("PURGE_JOB", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	defaultRetention, tenantRetention, err := parseRetentionPolicies(os.Getenv("RETENTION_POLICIES"))
+	if err != nil {
+		return Config{}, err
+	}
+
+	purgeDryRun, err := envBool("PURGE_DRY_RUN", false)
+	if err != nil {
+		return Config{}, err
+	}
+
+	purgeBatchSize, err := envInt("PURGE_BATCH_SIZE", 5000)
+	if err != nil || purgeBatchSize < 1 {
+		return Config{}, errors.New("PURGE_BATCH_SIZE must be a positive integer")
+	}
+
+	purgeInterval, err := envDuration("PURGE_INTERVAL", time.Hour)
+	if err != nil || purgeInterval <= 0 {
+		return Config{}, errors.New("PURGE_INTERVAL must be a positive duration")
+	}
+
+	privacyRunner, err := envBool("PRIVACY_RUNNER", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	exportTTL, err := envDays("PRIVACY_EXPORT_TTL", 7*24*time.Hour)
+	if err != nil || exportTTL <= 0 {
+		return Config{}, errors.New(`PRIVACY_EXPORT_TTL must be a positive duration (e.g. "7d", "48h")`)
+	}
+
+	rollupCompactor, err := envBool("ROLLUP_COMPACTOR", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	rollupInterval, err := envDuration("ROLLUP_INTERVAL", 10*time.Second)
+	if err != nil || rollupInterval <= 0 {
+		return Config{}, errors.New("ROLLUP_INTERVAL must be a positive duration")
+	}
+
+	catalogCompactor, err := envBool("CATALOG_COMPACTOR", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	catalogInterval, err := envDuration("CATALOG_INTERVAL", 10*time.Second)
+	if err != nil || catalogInterval <= 0 {
+		return Config{}, errors.New("CATALOG_INTERVAL must be a positive duration")
+	}
+
+	metricsCache := envString("METRICS_CACHE", MetricsCacheMemory)
+	if metricsCache != MetricsCacheMemory && metricsCache != MetricsCacheRedis && metricsCache != MetricsCacheOff {
+		return Config{}, errors.New(`METRICS_CACHE must be "memory", "redis" or "off"`)
+	}
+
+	cacheSize, err := envInt("METRICS_CACHE_SIZE", 10000)
+	if err != nil || cacheSize < 1 {
+		return Config{}, errors.New("METRICS_CACHE_SIZE must be a positive integer")
+	}
+
+	redisAddr := envString("METRICS_CACHE_REDIS_ADDR", "")
+	if metricsCache == MetricsCacheRedis && redisAddr == "" {
+		return Config{}, errors.New("METRICS_CACHE_REDIS_ADDR is required when METRICS_CACHE=redis")
+	}
+
+	cacheHorizon, err := envDuration("METRICS_CACHE_HORIZON", time.Hour)
+	if err != nil || cacheHorizon < 0 {
+		return Config{}, errors.New("METRICS_CACHE_HORIZON must be a non-negative duration")
+	}
+
+	pastTTL, err := envDuration("METRICS_CACHE_PAST_TTL", 24*time.Hour)
+	if err != nil || pastTTL <= 0 {
+		return Config{}, errors.New("METRICS_CACHE_PAST_TTL must be a positive duration")
+	}
+
+	recentTTL, err := envDuration("METRICS_CACHE_RECENT_TTL", 10*time.Second)
+	if err != nil || recentTTL <= 0 {
+		return Config{}, errors.New("METRICS_CACHE_RECENT_TTL must be a positive duration")
+	}
+
+	liveStreams, err := envBool("LIVE_STREAMS", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	liveMaxStreams, err := envInt("LIVE_MAX_STREAMS", 20)
+	if err != nil || liveMaxStreams < 1 {
+		return Config{}, errors.New("LIVE_MAX_STREAMS must be a positive integer")
+	}
+
+	liveFlush, err := envDuration("LIVE_FLUSH_INTERVAL", time.Second)
+	if err != nil || liveFlush <= 0 {
+		return Config{}, errors.New("LIVE_FLUSH_INTERVAL must be a positive duration")
+	}
+
+	// Operational metrics get their own listener, away from the tenant API.
+	telemetryAddr := envString("TELEMETRY_ADDR", ":9090")
+	if telemetryAddr == "off" {
+		telemetryAddr = ""
+	}
+
+	tracingExporter := envString("TRACING_EXPORTER", TracingNone)
+	switch tracingExporter {
+	case TracingNone, TracingOTLP, TracingOTLPGRPC, TracingStdout, TracingFile:
+	default:
+		return Config{}, errors.New(`TRACING_EXPORTER must be "none", "otlp", "otlp-grpc", "stdout" or "file"`)
+	}
+
+	tracingFile := envString("TRACING_FILE", "traces.jsonl")
+
+	sampleRatio, err := envFloat("TRACING_SAMPLE_RATIO", 1)
+	if err != nil || sampleRatio < 0 || sampleRatio > 1 {
+		return Config{}, errors.New("TRACING_SAMPLE_RATIO must be a number between 0 and 1")
 	}
 
 	return Config{
-		DBURL:   dbURL,
-		APIKeys: apiKeys,
+		DBURL:       dbURL,
+		LogLevel:    logLevel,
+		AutoMigrate: autoMigrate,
+		APIKeys:     apiKeys,
+		DevAPIKeys:  devAPIKeys,
+
+		HTTPAddr:         envString("HTTP_ADDR", ":8080"),
+		HTTPReadTimeout:  readTimeout,
+		HTTPWriteTimeout: writeTimeout,
+		HTTPIdleTimeout:  idleTimeout,
+		ShutdownDelay:    shutdownDelay,
+		ShutdownTimeout:  shutdownTimeout,
+
+		AdminAPIKey:    adminAPIKey,
+		APIKeyCacheTTL: apiKeyCacheTTL,
+
+		JWTJWKS:        jwtJWKS,
+		JWTIssuer:      jwtIssuer,
+		JWTAudience:    jwtAudience,
+		JWTTenantClaim: envString("JWT_TENANT_CLAIM", "tenant_id"),
+		JWTScopeClaim:  envString("JWT_SCOPE_CLAIM", "scope"),
+		JWTJWKSRefresh: jwksRefresh,
+
+		IngestMode:   ingestMode,
+		IngestWorker: ingestWorker,
+
+		WorkerBatchSize:    batchSize,
+		WorkerPollInterval: pollInterval,
+		WorkerMaxAttempts:  maxAttempts,
+
+		PartitionMaintainer: partitionMaintainer,
+		PartitionInterval:   partitionInterval,
+		PartitionAhead:      partitionAhead,
+		EventRetention:      retention,
+		PartitionDetach:     expireMode == "detach",
+
+		DefaultLimits: defaultLimits,
+		TenantLimits:  tenantLimits,
+
+		SchemaValidation:       schemaValidation,
+		TenantSchemaValidation: tenantSchemaValidation,
+		SchemaCacheTTL:         schemaCacheTTL,
+
+		PurgeJob:         purgeJob,
+		DefaultRetention: defaultRetention,
+		TenantRetention:  tenantRetention,
+		PurgeDryRun:      purgeDryRun,
+		PurgeBatchSize:   purgeBatchSize,
+		PurgeInterval:    purgeInterval,
+
+		PrivacyRunner:    privacyRunner,
+		PrivacyExportTTL: exportTTL,
+
+		RollupCompactor: rollupCompactor,
+		RollupInterval:  rollupInterval,
+
+		CatalogCompactor: catalogCompactor,
+		CatalogInterval:  catalogInterval,
+
+		MetricsCache:          metricsCache,
+		MetricsCacheSize:      cacheSize,
+		MetricsCacheRedisAddr: redisAddr,
+		MetricsCacheHorizon:   cacheHorizon,
+		MetricsCachePastTTL:   pastTTL,
+		MetricsCacheRecentTTL: recentTTL,
+
+		LiveStreams:       liveStreams,
+		LiveMaxStreams:    liveMaxStreams,
+		LiveFlushInterval: liveFlush,
+
+		TelemetryAddr: telemetryAddr,
+
+		TracingExporter:    tracingExporter,
+		TracingEndpoint:    envString("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		TracingFile:        tracingFile,
+		TracingServiceName: envString("OTEL_SERVICE_NAME", "event-analytics-service"),
+		TracingSampleRatio: sampleRatio,
 	}, nil
 }
+
+// parseRateLimits parses RATE_LIMITS, e.g.
+// {"*":{"requests_per_sec":50},"tenant1":{"events_per_sec":500,"daily_events":1000000}}
+func parseRateLimits(raw string) (TenantLimits, map[string]TenantLimits, error) {
+	tenants := map[string]TenantLimits{}
+	if strings.TrimSpace(raw) == "" {
+		return TenantLimits{}, tenants, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &tenants); err != nil {
+		return TenantLimits{}, nil, errors.New("RATE_LIMITS must be a JSON object of tenant -> limits")
+	}
+	for _, l := range tenants {
+		if l.RequestsPerSec < 0 || l.EventsPerSec < 0 || l.RequestBurst < 0 || l.EventBurst < 0 ||
+			l.DailyEvents < 0 || l.MonthlyEvents < 0 {
+			return TenantLimits{}, nil, errors.New("RATE_LIMITS values must not be negative")
+		}
+	}
+
+	def := tenants[defaultTenantKey]
+	delete(tenants, defaultTenantKey)
+	return def, tenants, nil
+}
+
+// parseRetentionPolicies parses RETENTION_POLICIES, e.g. {"*":"395d","tenant2":"90d"}.
+func parseRetentionPolicies(raw string) (time.Duration, map[string]time.Duration, error) {
+	tenants := map[string]time.Duration{}
+	if strings.TrimSpace(raw) == "" {
+		return 0, tenants, nil
+	}
+
+	var policies map[string]string
+	if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+		return 0, nil, errors.New(`RETENTION_POLICIES must be a JSON object of tenant -> retention (e.g. "90d")`)
+	}
+	for tenant, v := range policies {
+		d, err := parseDays(v)
+		if err != nil || d < 0 {
+			return 0, nil, errors.New("RETENTION_POLICIES: invalid retention " + strconv.Quote(v) + " for " + strconv.Quote(tenant))
+		}
+		tenants[tenant] = d
+	}
+
+	def := tenants[defaultTenantKey]
+	delete(tenants, defaultTenantKey)
+	return def, tenants, nil
+}
+
+// parseSchemaValidation parses SCHEMA_VALIDATION, e.g. {"*":"lenient","tenant1":"strict"}.
+// Tenants default to lenient.
+func parseSchemaValidation(raw string) (string, map[string]string, error) {
+	tenants := map[string]string{}
+	if strings.TrimSpace(raw) == "" {
+		return SchemaValidationLenient, tenants, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &tenants); err != nil {
+		return "", nil, errors.New(`SCHEMA_VALIDATION must be a JSON object of tenant -> "off", "lenient" or "strict"`)
+	}
+	for tenant, mode := range tenants {
+		if mode != SchemaValidationOff && mode != SchemaValidationLenient && mode != SchemaValidationStrict {
+			return "", nil, errors.New("SCHEMA_VALIDATION: invalid mode " + strconv.Quote(mode) + " for " + strconv.Quote(tenant))
+		}
+	}
+
+	def, ok := tenants[defaultTenantKey]
+	if !ok {
+		def = SchemaValidationLenient
+	}
+	delete(tenants, defaultTenantKey)
+	return def, tenants, nil
+}
+
+// envString returns the trimmed value of key, or def when unset/empty.
+func envString(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt parses key as an integer, or returns def when unset/empty.
+func envInt(key string, def int) (int, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// envFloat parses key as a floating-point number, or returns def when unset/empty.
+func envFloat(key string, def float64) (float64, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// envBool parses key as a boolean (true/false/1/0), or returns def when unset/empty.
+func envBool(key string, def bool) (bool, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.New(key + " must be true or false")
+	}
+	return b, nil
+}
+
+// envDuration parses key as a Go duration (e.g. "500ms", "5s"), or returns def when unset/empty.
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// envDays parses key like envDuration, additionally accepting whole days ("90d").
+func envDays(key string, def time.Duration) (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def, nil
+	}
+	return parseDays(v)
+}
+
+// parseDays parses a Go duration or a number of whole days ("90d"). "0" is zero.
+func parseDays(v string) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(v)
+}
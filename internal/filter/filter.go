@@ -0,0 +1,362 @@
+// Package filter parses the property filter expressions accepted by GET /metrics.
+//
+// Grammar (keywords are case-insensitive):
+//
+//	expr       = or
+//	or         = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | "(" expr ")" | predicate
+//	predicate  = "exists" "(" field ")"
+//	           | field ( "=" | "!=" | ">" | ">=" | "<" | "<=" | "contains" ) value
+//	           | field "in" "(" value { "," value } ")"
+//	field      = "properties" "." key { "." key }
+//	value      = 'string' | "string" | number | true | false | null
+//
+// The parser only produces an AST; turning it into SQL is the store's job, which
+// binds every field path and value as a query parameter.
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Limits that keep a single filter cheap to parse and to execute.
+const (
+	MaxLength   = 2048
+	MaxDepth    = 32
+	MaxInValues = 100
+)
+
+// Op is a comparison operator.
+type Op string
+
+// Supported operators.
+const (
+	OpEq       Op = "="
+	OpNeq      Op = "!="
+	OpGt       Op = ">"
+	OpGte      Op = ">="
+	OpLt       Op = "<"
+	OpLte      Op = "<="
+	OpIn       Op = "in"
+	OpContains Op = "contains"
+	OpExists   Op = "exists"
+)
+
+// Expr is a node of a parsed filter expression.
+type Expr interface {
+	expr()
+}
+
+// And matches when every term matches.
+type And struct {
+	Terms []Expr
+}
+
+// Or matches when any term matches.
+type Or struct {
+	Terms []Expr
+}
+
+// Not negates X.
+type Not struct {
+	X Expr
+}
+
+// Predicate compares the property at Path with Values.
+//
+// Path is the property path below "properties" (at least one key). Values holds
+// JSON-compatible literals: string, json.Number, bool or nil. It is empty for
+// OpExists, has one entry for binary operators and one or more for OpIn.
+// Numeric comparisons (>, >=, <, <=) always carry a json.Number.
+type Predicate struct {
+	Path   []string
+	Op     Op
+	Values []any
+}
+
+func (And) expr()       {}
+func (Or) expr()        {}
+func (Not) expr()       {}
+func (Predicate) expr() {}
+
+// SyntaxError reports an invalid expression. Pos is the 0-based byte offset
+// in the input where parsing failed.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Parse parses a filter expression.
+// Errors are always *SyntaxError.
+func Parse(input string) (Expr, error) {
+	if len(input) > MaxLength {
+		return nil, &SyntaxError{Pos: MaxLength, Msg: fmt.Sprintf("filter longer than %d bytes", MaxLength)}
+	}
+
+	p := &parser{lex: lexer{src: input}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokEOF {
+		return nil, &SyntaxError{Pos: 0, Msg: "empty filter"}
+	}
+
+	e, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return e, nil
+}
+
+// parser is a recursive-descent parser over the token stream produced by lexer.
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) next() error {
+	t, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(kind tokenKind, what string) error {
+	if p.tok.kind != kind {
+		return p.errorf("expected %s, found %s", what, p.tok)
+	}
+	return p.next()
+}
+
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	terms := []Expr{left}
+	for p.tok.isKeyword("or") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return Or{Terms: terms}, nil
+}
+
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	terms := []Expr{left}
+	for p.tok.isKeyword("and") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return And{Terms: terms}, nil
+}
+
+func (p *parser) parseUnary(depth int) (Expr, error) {
+	if depth >= MaxDepth {
+		return nil, p.errorf("filter nested deeper than %d levels", MaxDepth)
+	}
+
+	switch {
+	case p.tok.isKeyword("not"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not{X: x}, nil
+
+	case p.tok.kind == tokLParen:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case p.tok.isKeyword("exists"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokLParen, `"("`); err != nil {
+			return nil, err
+		}
+		path, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return Predicate{Path: path, Op: OpExists}, nil
+	}
+
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (Expr, error) {
+	path, err := p.parseField()
+	if err != nil {
+		return nil, err
+	}
+
+	var op Op
+	switch {
+	case p.tok.kind == tokOp:
+		op = Op(p.tok.text)
+	case p.tok.isKeyword("contains"):
+		op = OpContains
+	case p.tok.isKeyword("in"):
+		op = OpIn
+	default:
+		return nil, p.errorf("expected operator, found %s", p.tok)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if op == OpIn {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return Predicate{Path: path, Op: op, Values: values}, nil
+	}
+
+	valuePos := p.tok.pos
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case OpGt, OpGte, OpLt, OpLte:
+		if _, ok := v.(json.Number); !ok {
+			return nil, &SyntaxError{Pos: valuePos, Msg: fmt.Sprintf("operator %s requires a number", op)}
+		}
+	}
+	return Predicate{Path: path, Op: op, Values: []any{v}}, nil
+}
+
+// parseField parses properties.<key>{.<key>} and returns the keys below properties.
+func (p *parser) parseField() ([]string, error) {
+	if p.tok.kind != tokIdent || p.tok.text != "properties" {
+		return nil, p.errorf("expected properties.<key>, found %s", p.tok)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var path []string
+	for p.tok.kind == tokDot {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch p.tok.kind {
+		case tokIdent, tokString:
+			path = append(path, p.tok.text)
+		default:
+			return nil, p.errorf("expected property key, found %s", p.tok)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if len(path) == 0 {
+		return nil, p.errorf("expected properties.<key>, found %s", p.tok)
+	}
+	return path, nil
+}
+
+func (p *parser) parseList() ([]any, error) {
+	if err := p.expect(tokLParen, `"("`); err != nil {
+		return nil, err
+	}
+
+	var values []any
+	for {
+		if len(values) == MaxInValues {
+			return nil, p.errorf("in list longer than %d values", MaxInValues)
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+
+		if p.tok.kind != tokComma {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(tokRParen, `")"`); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (p *parser) parseValue() (any, error) {
+	var v any
+	switch {
+	case p.tok.kind == tokString:
+		v = p.tok.text
+	case p.tok.kind == tokNumber:
+		v = json.Number(p.tok.text)
+	case p.tok.isKeyword("true"):
+		v = true
+	case p.tok.isKeyword("false"):
+		v = false
+	case p.tok.isKeyword("null"):
+		v = nil
+	default:
+		return nil, p.errorf("expected value, found %s", p.tok)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse_Valid(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Expr
+	}{
+		{
+			in:   `properties.source = 'web'`,
+			want: Predicate{Path: []string{"source"}, Op: OpEq, Values: []any{"web"}},
+		},
+		{
+			in: `properties.source = 'web' and properties.country in ('DE', "FR")`,
+			want: And{Terms: []Expr{
+				Predicate{Path: []string{"source"}, Op: OpEq, Values: []any{"web"}},
+				Predicate{Path: []string{"country"}, Op: OpIn, Values: []any{"DE", "FR"}},
+			}},
+		},
+		{
+			in: `NOT (properties.a.b >= -1.5e3 OR exists(properties."odd key"))`,
+			want: Not{X: Or{Terms: []Expr{
+				Predicate{Path: []string{"a", "b"}, Op: OpGte, Values: []any{json.Number("-1.5e3")}},
+				Predicate{Path: []string{"odd key"}, Op: OpExists},
+			}}},
+		},
+		{
+			in:   `properties.tags contains 'it''s' `,
+			want: Predicate{Path: []string{"tags"}, Op: OpContains, Values: []any{"it's"}},
+		},
+		{
+			in:   `properties.beta != null`,
+			want: Predicate{Path: []string{"beta"}, Op: OpNeq, Values: []any{nil}},
+		},
+		{
+			// and binds tighter than or.
+			in: `properties.a = 1 or properties.b = true and properties.c < 3`,
+			want: Or{Terms: []Expr{
+				Predicate{Path: []string{"a"}, Op: OpEq, Values: []any{json.Number("1")}},
+				And{Terms: []Expr{
+					Predicate{Path: []string{"b"}, Op: OpEq, Values: []any{true}},
+					Predicate{Path: []string{"c"}, Op: OpLt, Values: []any{json.Number("3")}},
+				}},
+			}},
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := Parse(tc.in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.in, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("Parse(%q) = %#v, want %#v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParse_ErrorPosition(t *testing.T) {
+	cases := []struct {
+		in  string
+		pos int
+	}{
+		{in: ``, pos: 0},
+		{in: `source = 'web'`, pos: 0},
+		{in: `properties.source == 'web'`, pos: 19},
+		{in: `properties.source = 'web`, pos: 20},
+		{in: `properties.n > 'x'`, pos: 15},
+		{in: `properties.c in ('a',)`, pos: 21},
+		{in: `(properties.a = 1`, pos: 17},
+		{in: `properties.a = 1 properties.b = 2`, pos: 17},
+		{in: `properties.a ! 1`, pos: 13},
+	}
+
+	for _, tc := range cases {
+		_, err := Parse(tc.in)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("Parse(%q): expected *SyntaxError, got %v", tc.in, err)
+		}
+		if se.Pos != tc.pos {
+			t.Fatalf("Parse(%q): error %q at %d, want position %d", tc.in, se.Msg, se.Pos, tc.pos)
+		}
+	}
+}
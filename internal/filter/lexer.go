@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokDot
+	tokComma
+	tokLParen
+	tokRParen
+)
+
+// token is a lexical token; pos is its byte offset in the input.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// isKeyword reports whether t is the (case-insensitive) bare word kw.
+func (t token) isKeyword(kw string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// lexer splits a filter expression into tokens.
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && isSpace(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}, nil
+	}
+
+	start := l.pos
+	ch := l.src[l.pos]
+
+	switch {
+	case ch == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}, nil
+	case ch == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}, nil
+	case ch == ',':
+		l.pos++
+		return token{kind: tokComma, text: ",", pos: start}, nil
+	case ch == '.':
+		l.pos++
+		return token{kind: tokDot, text: ".", pos: start}, nil
+	case ch == '=':
+		l.pos++
+		return token{kind: tokOp, text: "=", pos: start}, nil
+	case ch == '!' || ch == '<' || ch == '>':
+		l.pos++
+		if l.pos < len(l.src) && l.src[l.pos] == '=' {
+			l.pos++
+			return token{kind: tokOp, text: l.src[start:l.pos], pos: start}, nil
+		}
+		if ch == '!' {
+			return token{}, &SyntaxError{Pos: start, Msg: `unexpected "!" (did you mean "!="?)`}
+		}
+		return token{kind: tokOp, text: string(ch), pos: start}, nil
+	case ch == '\'' || ch == '"':
+		return l.lexString(ch)
+	case ch == '-' || isDigit(ch):
+		return l.lexNumber()
+	case isIdentStart(ch):
+		for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, &SyntaxError{Pos: start, Msg: fmt.Sprintf("unexpected character %q", r)}
+}
+
+// lexString reads a quoted string. The quote character is escaped by doubling
+// it (SQL style) or with a backslash, which also escapes itself.
+func (l *lexer) lexString(quote byte) (token, error) {
+	start := l.pos
+	l.pos++
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		ch := l.src[l.pos]
+		switch {
+		case ch == '\\' && l.pos+1 < len(l.src):
+			b.WriteByte(l.src[l.pos+1])
+			l.pos += 2
+		case ch == quote && l.pos+1 < len(l.src) && l.src[l.pos+1] == quote:
+			b.WriteByte(quote)
+			l.pos += 2
+		case ch == quote:
+			l.pos++
+			return token{kind: tokString, text: b.String(), pos: start}, nil
+		default:
+			b.WriteByte(ch)
+			l.pos++
+		}
+	}
+	return token{}, &SyntaxError{Pos: start, Msg: "unterminated string"}
+}
+
+// lexNumber reads a JSON-style number: -?digits[.digits][(e|E)[+-]digits].
+func (l *lexer) lexNumber() (token, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	if !l.digits() {
+		return token{}, &SyntaxError{Pos: start, Msg: "invalid number"}
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.pos++
+		if !l.digits() {
+			return token{}, &SyntaxError{Pos: start, Msg: "invalid number"}
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if !l.digits() {
+			return token{}, &SyntaxError{Pos: start, Msg: "invalid number"}
+		}
+	}
+	if l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+		return token{}, &SyntaxError{Pos: start, Msg: "invalid number"}
+	}
+	return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+}
+
+// digits consumes one or more ASCII digits and reports whether any were read.
+func (l *lexer) digits() bool {
+	start := l.pos
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	return l.pos > start
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch == '$' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+}
+
+func isIdentPart(ch byte) bool {
+	return isIdentStart(ch) || isDigit(ch) || ch == '-'
+}
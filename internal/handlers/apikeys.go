@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/models"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// maxKeyGracePeriod bounds how long a rotated key may keep working.
+const maxKeyGracePeriod = 30 * 24 * time.Hour
+
+// RegisterAdminRoutes registers the API key management endpoints.
+//
+// POST   /admin/keys             create a key (plaintext returned once)
+// GET    /admin/keys             list keys, optionally ?tenant_id=
+// POST   /admin/keys/:id/rotate  replace a key, optionally keeping the old one for a grace period
+// DELETE /admin/keys/:id         revoke a key
+//
+// The caller is responsible for admin authentication (auth.AdminMiddleware).
+// Requests authenticated with a tenant's admin-scoped key (auth.TenantID set) only
+// see and manage that tenant's keys; operator requests may manage every tenant.
+// v is notified of revocations so this replica stops accepting the key at once.
+func RegisterAdminRoutes(r gin.IRoutes, st *store.PostgresStore, v *auth.KeyValidator) {
+	r.POST("/admin/keys", func(c *gin.Context) {
+		var req models.APIKeyCreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+			return
+		}
+
+		req.TenantID = strings.TrimSpace(req.TenantID)
+		if own := auth.TenantID(c); own != "" {
+			if req.TenantID != "" && req.TenantID != own {
+				c.JSON(http.StatusForbidden, gin.H{"error": "cannot manage keys of another tenant"})
+				return
+			}
+			req.TenantID = own
+		}
+		if req.TenantID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tenant_id is required"})
+			return
+		}
+
+		if len(req.Scopes) == 0 {
+			req.Scopes = auth.DefaultScopes
+		}
+		for _, s := range req.Scopes {
+			if !auth.ValidScope(s) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "unknown scope " + strconv.Quote(s) + " (allowed: events:write, metrics:read, admin)",
+				})
+				return
+			}
+		}
+
+		var expiresAt *time.Time
+		if req.ExpiresAt != "" {
+			t, err := parseRFC3339(req.ExpiresAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be RFC3339"})
+				return
+			}
+			if !t.After(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+				return
+			}
+			expiresAt = &t
+		}
+
+		plaintext, k, err := auth.NewAPIKey(req.TenantID, req.Scopes)
+		if err != nil {
+			internalError(c, "key generation failed", err)
+			return
+		}
+		k.ExpiresAt = expiresAt
+
+		created, err := st.CreateAPIKey(c.Request.Context(), k)
+		if err != nil {
+			internalError(c, "db insert failed", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, models.APIKeyCreated{APIKey: apiKeyModel(created), Key: plaintext})
+	})
+
+	r.GET("/admin/keys", func(c *gin.Context) {
+		tenantID := strings.TrimSpace(c.Query("tenant_id"))
+		if own := auth.TenantID(c); own != "" {
+			tenantID = own
+		}
+
+		keys, err := st.ListAPIKeys(c.Request.Context(), tenantID)
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+
+		out := make([]models.APIKey, len(keys))
+		for i, k := range keys {
+			out[i] = apiKeyModel(k)
+		}
+		c.JSON(http.StatusOK, gin.H{"keys": out})
+	})
+
+	r.POST("/admin/keys/:id/rotate", func(c *gin.Context) {
+		var req models.APIKeyRotateRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+				return
+			}
+		}
+
+		var grace time.Duration
+		if req.GracePeriod != "" {
+			d, err := parseWindow(req.GracePeriod)
+			if err != nil || d < 0 || d > maxKeyGracePeriod {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period must be a duration up to 30d (e.g. 1h, 7d)"})
+				return
+			}
+			grace = d
+		}
+
+		plaintext, next, err := auth.NewAPIKey("", nil)
+		if err != nil {
+			internalError(c, "key generation failed", err)
+			return
+		}
+
+		oldID := c.Param("id")
+		created, err := st.RotateAPIKey(c.Request.Context(), oldID, auth.TenantID(c), next, time.Now(), grace)
+		switch {
+		case errors.Is(err, store.ErrAPIKeyNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		case errors.Is(err, store.ErrAPIKeyInactive):
+			c.JSON(http.StatusConflict, gin.H{"error": "api key is revoked or expired"})
+			return
+		case err != nil:
+			internalError(c, "db update failed", err)
+			return
+		}
+		v.Invalidate(oldID)
+
+		c.JSON(http.StatusCreated, models.APIKeyCreated{APIKey: apiKeyModel(created), Key: plaintext})
+	})
+
+	r.DELETE("/admin/keys/:id", func(c *gin.Context) {
+		k, err := st.RevokeAPIKey(c.Request.Context(), c.Param("id"), auth.TenantID(c), time.Now())
+		switch {
+		case errors.Is(err, store.ErrAPIKeyNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		case err != nil:
+			internalError(c, "db update failed", err)
+			return
+		}
+		v.Invalidate(k.ID)
+
+		c.JSON(http.StatusOK, apiKeyModel(k))
+	})
+}
+
+// apiKeyModel converts a stored key to its API representation.
+func apiKeyModel(k store.APIKey) models.APIKey {
+	scopes := k.Scopes
+	if len(scopes) == 0 {
+		scopes = auth.DefaultScopes
+	}
+	return models.APIKey{
+		ID:         k.ID,
+		TenantID:   k.TenantID,
+		Scopes:     scopes,
+		CreatedAt:  k.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:  formatTimePtr(k.ExpiresAt),
+		RevokedAt:  formatTimePtr(k.RevokedAt),
+		LastUsedAt: formatTimePtr(k.LastUsedAt),
+	}
+}
+
+func formatTimePtr(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
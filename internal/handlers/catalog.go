@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/models"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// RegisterCatalogRoutes registers the event discovery endpoint.
+//
+// GET /events/catalog[?event_name=...]
+// - Requires X-API-Key (tenant context)
+// - Lists every event name sent with first/last seen times and volume
+// - Lists the property keys of each name by JSON type, with sample values
+// - Reads the catalog and its pending deltas, never the events table
+func RegisterCatalogRoutes(r gin.IRoutes, st *store.PostgresStore) {
+	r.GET("/events/catalog", func(c *gin.Context) {
+		tenantID := auth.TenantID(c)
+		if tenantID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		catalog, err := st.EventCatalog(c.Request.Context(), tenantID, c.Query("event_name"))
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+
+		resp := models.CatalogResponse{Events: make([]models.CatalogEvent, len(catalog))}
+		for i, e := range catalog {
+			props := make([]models.CatalogProperty, len(e.Properties))
+			for j, p := range e.Properties {
+				props[j] = models.CatalogProperty{
+					Key:       p.Key,
+					Type:      p.Type,
+					FirstSeen: p.FirstSeen.UTC().Format(time.RFC3339),
+					LastSeen:  p.LastSeen.UTC().Format(time.RFC3339),
+					Volume:    p.Events,
+					Samples:   p.Samples,
+				}
+			}
+			resp.Events[i] = models.CatalogEvent{
+				EventName:  e.EventName,
+				FirstSeen:  e.FirstSeen.UTC().Format(time.RFC3339),
+				LastSeen:   e.LastSeen.UTC().Format(time.RFC3339),
+				Volume:     e.Events,
+				Properties: props,
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/logging"
+)
+
+// internalError logs err with the request ID, tenant and route of c and
+// responds 500 with msg. The error itself is not returned to the client.
+func internalError(c *gin.Context, msg string, err error) {
+	logging.FromContext(c.Request.Context()).Error(msg,
+		"tenant_id", auth.TenantID(c),
+		"route", c.FullPath(),
+		"err", err,
+	)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -9,9 +12,23 @@ import (
 
 	"github.com/PratikDhanave/event-analytics-service/internal/auth"
 	"github.com/PratikDhanave/event-analytics-service/internal/models"
+	"github.com/PratikDhanave/event-analytics-service/internal/ratelimit"
+	"github.com/PratikDhanave/event-analytics-service/internal/schema"
 	"github.com/PratikDhanave/event-analytics-service/internal/store"
+	"github.com/PratikDhanave/event-analytics-service/internal/tracing"
 )
 
+// maxActorIDLength bounds user_id / anonymous_id so they stay cheap to index.
+const maxActorIDLength = 256
+
+// maxEventNameLength bounds event_name, which is indexed, cached and published
+// in live count notifications.
+const maxEventNameLength = 256
+
+// maxBatchSize bounds POST /events/batch so a single request cannot hold
+// an unbounded insert (and its parameter arrays) in memory.
+const maxBatchSize = 1000
+
 // parseRFC3339 parses an RFC3339 timestamp and normalizes it to UTC.
 func parseRFC3339(ts string) (time.Time, error) {
 	t, err := time.Parse(time.RFC3339, ts)
@@ -21,13 +38,92 @@ func parseRFC3339(ts string) (time.Time, error) {
 	return t.UTC(), nil
 }
 
-// RegisterEventRoutes registers the ingestion-path endpoint.
+// validateEvent applies the POST /events contract to a payload and returns the parsed timestamp.
+// The error message is safe to return to clients as-is.
+func validateEvent(req models.EventIngestRequest) (time.Time, error) {
+	// Required fields per contract.
+	if req.EventName == "" {
+		return time.Time{}, errors.New("event_name required")
+	}
+	if len(req.EventName) > maxEventNameLength {
+		return time.Time{}, fmt.Errorf("event_name must be at most %d bytes", maxEventNameLength)
+	}
+	if req.Timestamp == "" {
+		return time.Time{}, errors.New("timestamp required")
+	}
+
+	ts, err := parseRFC3339(req.Timestamp)
+	if err != nil {
+		return time.Time{}, errors.New("timestamp must be RFC3339")
+	}
+
+	if len(req.UserID) > maxActorIDLength || len(req.AnonymousID) > maxActorIDLength {
+		return time.Time{}, fmt.Errorf("user_id and anonymous_id must be at most %d bytes", maxActorIDLength)
+	}
+	return ts, nil
+}
+
+// checkSchema validates an event against the schemas of its tenant; a nil
+// registry accepts everything.
+func checkSchema(c *gin.Context, schemas *schema.Registry, tenantID string, req models.EventIngestRequest) (schema.Result, error) {
+	if schemas == nil {
+		return schema.Result{}, nil
+	}
+	return schemas.Check(c.Request.Context(), tenantID, req.EventName, req.Properties)
+}
+
+// schemaRejection summarizes why strict validation refused an event.
+func schemaRejection(res schema.Result) string {
+	if len(res.Errors) == 1 && res.Errors[0].Field == "event_name" {
+		return "event_name is not registered"
+	}
+	return "properties do not match the event schema"
+}
+
+// schemaErrors converts validation errors to their API representation.
+func schemaErrors(errs []schema.FieldError) []models.SchemaError {
+	if len(errs) == 0 {
+		return nil
+	}
+	out := make([]models.SchemaError, len(errs))
+	for i, e := range errs {
+		out[i] = models.SchemaError{Field: e.Field, Message: e.Message}
+	}
+	return out
+}
+
+// storedSchemaErrors returns the violations stored with an event that lenient
+// validation flagged, or nil.
+func storedSchemaErrors(res schema.Result) json.RawMessage {
+	if !res.Flagged() {
+		return nil
+	}
+	b, _ := json.Marshal(schemaErrors(res.Errors)) // strings only, cannot fail
+	return b
+}
+
+// RegisterEventRoutes registers the ingestion-path endpoints.
 //
 // POST /events
 // - Requires X-API-Key (tenant context)
 // - Durable: returns success only after DB write completes
 // - Idempotent: duplicates detected via (tenant_id, event_id) uniqueness
-func RegisterEventRoutes(r gin.IRoutes, st *store.PostgresStore) {
+// - async: the write goes to the event_queue outbox and returns 202
+// - Schemas: strict tenants get 422 with field-level errors, lenient ones schema_errors
+//
+// POST /events/batch
+// - Same contract per item; all accepted items are written in one insert
+// - Returns a per-item result (inserted/queued, duplicate or rejected with a reason)
+//
+// schemas is the tenant schema registry; nil disables validation.
+func RegisterEventRoutes(r gin.IRoutes, st *store.PostgresStore, async bool, schemas *schema.Registry) {
+	// persist writes a batch and reports which event IDs were new. In async mode
+	// "new" means newly queued; the worker dedupes against events when draining.
+	persist, newStatus := st.InsertEvents, models.BatchStatusInserted
+	if async {
+		persist, newStatus = st.EnqueueEvents, models.BatchStatusQueued
+	}
+
 	r.POST("/events", func(c *gin.Context) {
 		tenantID := auth.TenantID(c)
 		if tenantID == "" {
@@ -41,19 +137,24 @@ func RegisterEventRoutes(r gin.IRoutes, st *store.PostgresStore) {
 			return
 		}
 
-		// Required fields per contract.
-		if req.EventName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "event_name required"})
-			return
-		}
-		if req.Timestamp == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "timestamp required"})
+		ts, err := validateEvent(req)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		tracing.SetAttr(c.Request.Context(), "event_name", req.EventName)
 
-		ts, err := parseRFC3339(req.Timestamp)
+		checked, err := checkSchema(c, schemas, tenantID, req)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "timestamp must be RFC3339"})
+			internalError(c, "schema lookup failed", err)
+			return
+		}
+		if checked.Rejected() {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":      schemaRejection(checked),
+				"event_name": req.EventName,
+				"errors":     schemaErrors(checked.Errors),
+			})
 			return
 		}
 
@@ -69,16 +170,41 @@ func RegisterEventRoutes(r gin.IRoutes, st *store.PostgresStore) {
 			eventID = uuid.New().String()
 		}
 
-		inserted, err := st.InsertEvent(
-			c.Request.Context(),
-			tenantID,
-			eventID,
-			req.EventName,
-			ts,
-			req.Properties,
-		)
+		event := store.Event{
+			EventID:     eventID,
+			EventName:   req.EventName,
+			TS:          ts,
+			Properties:  req.Properties,
+			UserID:      req.UserID,
+			AnonymousID: req.AnonymousID,
+
+			SchemaErrors: storedSchemaErrors(checked),
+		}
+
+		if async {
+			queued, err := st.EnqueueEvents(c.Request.Context(), tenantID, []store.Event{event})
+			if err != nil {
+				internalError(c, "queue insert failed", err)
+				return
+			}
+
+			// 202 once durably queued, 200 if this event ID is already waiting in the queue.
+			if !queued[eventID] {
+				c.JSON(http.StatusOK, models.EventIngestResponse{
+					EventID: eventID, Duplicate: true, SchemaErrors: schemaErrors(checked.Errors),
+				})
+				return
+			}
+			ratelimit.EventsWritten(c, 1)
+			c.JSON(http.StatusAccepted, models.EventIngestResponse{
+				EventID: eventID, Queued: true, SchemaErrors: schemaErrors(checked.Errors),
+			})
+			return
+		}
+
+		inserted, err := st.InsertEvent(c.Request.Context(), tenantID, event)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+			internalError(c, "db insert failed", err)
 			return
 		}
 
@@ -88,11 +214,130 @@ func RegisterEventRoutes(r gin.IRoutes, st *store.PostgresStore) {
 		if !inserted {
 			status = http.StatusOK
 			dup = true
+		} else {
+			ratelimit.EventsWritten(c, 1)
 		}
 
 		c.JSON(status, models.EventIngestResponse{
-			EventID:   eventID,
-			Duplicate: dup,
+			EventID:      eventID,
+			Duplicate:    dup,
+			SchemaErrors: schemaErrors(checked.Errors),
 		})
 	})
+
+	r.POST("/events/batch", func(c *gin.Context) {
+		tenantID := auth.TenantID(c)
+		if tenantID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		var req models.EventBatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+			return
+		}
+		if len(req.Events) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "events required"})
+			return
+		}
+		if len(req.Events) > maxBatchSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("at most %d events per batch", maxBatchSize),
+			})
+			return
+		}
+
+		results := make([]models.EventBatchItemResult, len(req.Events))
+		batch := make([]store.Event, 0, len(req.Events))
+		// firstIndex maps an event ID to the batch item that will carry it, so a
+		// repeated ID inside the same request is reported as a duplicate of that item.
+		firstIndex := make(map[string]int, len(req.Events))
+
+		for i, item := range req.Events {
+			results[i].Index = i
+
+			ts, err := validateEvent(item)
+			if err != nil {
+				results[i].Status = models.BatchStatusRejected
+				results[i].Error = err.Error()
+				continue
+			}
+
+			checked, err := checkSchema(c, schemas, tenantID, item)
+			if err != nil {
+				internalError(c, "schema lookup failed", err)
+				return
+			}
+			results[i].SchemaErrors = schemaErrors(checked.Errors)
+			if checked.Rejected() {
+				results[i].Status = models.BatchStatusRejected
+				results[i].Error = schemaRejection(checked)
+				continue
+			}
+
+			// There is no per-item header, so event_id is the idempotency key;
+			// without it a UUID is generated (cannot dedupe client retries).
+			eventID := item.EventID
+			if eventID == "" {
+				eventID = uuid.New().String()
+			}
+			results[i].EventID = eventID
+
+			if _, seen := firstIndex[eventID]; seen {
+				results[i].Status = models.BatchStatusDuplicate
+				continue
+			}
+			firstIndex[eventID] = i
+
+			batch = append(batch, store.Event{
+				EventID:     eventID,
+				EventName:   item.EventName,
+				TS:          ts,
+				Properties:  item.Properties,
+				UserID:      item.UserID,
+				AnonymousID: item.AnonymousID,
+
+				SchemaErrors: storedSchemaErrors(checked),
+			})
+		}
+
+		written, err := persist(c.Request.Context(), tenantID, batch)
+		if err != nil {
+			internalError(c, "db insert failed", err)
+			return
+		}
+
+		resp := models.EventBatchResponse{Results: results}
+		for i := range results {
+			if results[i].Status == "" {
+				if written[results[i].EventID] {
+					results[i].Status = newStatus
+				} else {
+					results[i].Status = models.BatchStatusDuplicate
+				}
+			}
+
+			switch results[i].Status {
+			case models.BatchStatusInserted:
+				resp.Inserted++
+			case models.BatchStatusQueued:
+				resp.Queued++
+			case models.BatchStatusDuplicate:
+				resp.Duplicates++
+			case models.BatchStatusRejected:
+				resp.Rejected++
+			}
+		}
+
+		ratelimit.EventsWritten(c, resp.Inserted+resp.Queued)
+
+		// Partial success is expected for batches, so the per-item results carry
+		// the outcome and the request itself succeeds.
+		status := http.StatusOK
+		if async {
+			status = http.StatusAccepted
+		}
+		c.JSON(status, resp)
+	})
 }
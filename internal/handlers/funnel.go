@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/models"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// Funnel bounds: step count and conversion window.
+const (
+	minFunnelSteps  = 2
+	maxFunnelSteps  = 10
+	maxFunnelWindow = 90 * 24 * time.Hour
+)
+
+// defaultFunnelWindow applies when the request does not specify a window.
+const defaultFunnelWindow = "7d"
+
+// RegisterFunnelRoutes registers the funnel analysis endpoint.
+//
+// POST /funnels
+// - Requires X-API-Key (tenant context)
+// - Body: ordered steps (event names), from/to (RFC3339) and a conversion window
+// - Returns actors reaching each step with overall and step-to-step conversion
+func RegisterFunnelRoutes(r gin.IRoutes, st *store.PostgresStore) {
+	r.POST("/funnels", func(c *gin.Context) {
+		tenantID := auth.TenantID(c)
+		if tenantID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		var req models.FunnelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+			return
+		}
+
+		if len(req.Steps) < minFunnelSteps || len(req.Steps) > maxFunnelSteps {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("steps must contain %d to %d event names", minFunnelSteps, maxFunnelSteps),
+			})
+			return
+		}
+		for _, s := range req.Steps {
+			if s == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "steps must not be empty"})
+				return
+			}
+		}
+
+		if req.From == "" || req.To == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from, to are required"})
+			return
+		}
+		from, err := parseRFC3339(req.From)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be RFC3339"})
+			return
+		}
+		to, err := parseRFC3339(req.To)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be RFC3339"})
+			return
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be < to"})
+			return
+		}
+
+		if req.Window == "" {
+			req.Window = defaultFunnelWindow
+		}
+		window, err := parseWindow(req.Window)
+		if err != nil || window <= 0 || window > maxFunnelWindow {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be a positive duration up to 90d (e.g. 30m, 24h, 7d)"})
+			return
+		}
+
+		reached, err := st.Funnel(c.Request.Context(), store.FunnelQuery{
+			TenantID: tenantID,
+			Steps:    req.Steps,
+			From:     from,
+			To:       to,
+			Window:   window,
+		})
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+
+		steps := make([]models.FunnelStep, len(req.Steps))
+		for i, name := range req.Steps {
+			prev := reached[0]
+			if i > 0 {
+				prev = reached[i-1]
+			}
+			steps[i] = models.FunnelStep{
+				EventName:          name,
+				Actors:             reached[i],
+				ConversionRate:     ratio(reached[i], reached[0]),
+				StepConversionRate: ratio(reached[i], prev),
+			}
+		}
+
+		c.JSON(http.StatusOK, models.FunnelResponse{
+			From:   from.Format(time.RFC3339),
+			To:     to.Format(time.RFC3339),
+			Window: req.Window,
+			Steps:  steps,
+		})
+	})
+}
+
+// parseWindow parses a Go duration, additionally accepting whole days ("7d").
+// Day counts are bounded before they are multiplied, so they cannot overflow.
+func parseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.New("invalid day count")
+		}
+		if n < 0 || n > int(maxFunnelWindow/(24*time.Hour)) {
+			return 0, errors.New("day count out of range")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ratio returns n/d, or 0 when d is 0.
+func ratio(n, d int64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
@@ -1,22 +1,68 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/cache"
+	"github.com/PratikDhanave/event-analytics-service/internal/filter"
+	"github.com/PratikDhanave/event-analytics-service/internal/models"
 	"github.com/PratikDhanave/event-analytics-service/internal/store"
+	"github.com/PratikDhanave/event-analytics-service/internal/tracing"
 )
 
+// maxSeriesBuckets caps how many buckets one series request may produce.
+const maxSeriesBuckets = 5000
+
+// Group-by bounds: how many property keys may be combined and how many groups are returned.
+const (
+	maxGroupKeys      = 5
+	defaultGroupLimit = 10
+	maxGroupLimit     = 1000
+)
+
+// groupByPrefix is the only supported group_by namespace (top-level JSONB properties).
+const groupByPrefix = "properties."
+
+// seriesIntervals maps the accepted interval values to the shortest duration
+// a bucket of that unit can have; used to bound the number of buckets.
+var seriesIntervals = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    23 * time.Hour, // DST transition days are 23h long
+	"week":   7*24*time.Hour - time.Hour,
+	"month":  28*24*time.Hour - time.Hour,
+}
+
 // RegisterMetricRoutes registers the serving-path endpoint.
 //
-// GET /metrics?event_name=...&from=...&to=...
+// GET /metrics?event_name=...&from=...&to=...[&interval=...&tz=...]
 // - Requires X-API-Key (tenant context)
 // - Returns count for the window [from,to)
-func RegisterMetricRoutes(r gin.IRoutes, st *store.PostgresStore) {
-	r.GET("/metrics", func(c *gin.Context) {
+// - filter restricts counted events by property (see package filter)
+// - interval (minute|hour|day|week|month) adds a zero-filled bucket series
+// - tz (IANA name, default UTC) sets the calendar the buckets align to
+// - group_by=properties.<key> (repeatable) breaks the count down per value
+// - limit (default 10) keeps the top groups; the rest is summed into other
+// - aggregate=unique_users counts distinct actors instead of events
+// - accuracy=approx estimates unique users with HyperLogLog (default exact)
+//
+// With a non-nil mc, responses are cached and carry Cache-Control and ETag
+// (see cacheMetrics).
+func RegisterMetricRoutes(r gin.IRoutes, st *store.PostgresStore, mc *cache.MetricsCache) {
+	chain := []gin.HandlerFunc{}
+	if mc != nil {
+		chain = append(chain, cacheMetrics(mc))
+	}
+
+	r.GET("/metrics", append(chain, func(c *gin.Context) {
 		tenantID := auth.TenantID(c)
 		if tenantID == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
@@ -32,6 +78,7 @@ func RegisterMetricRoutes(r gin.IRoutes, st *store.PostgresStore) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "event_name, from, to are required"})
 			return
 		}
+		tracing.SetAttr(c.Request.Context(), "event_name", eventName)
 
 		from, err := time.Parse(time.RFC3339, fromStr)
 		if err != nil {
@@ -53,15 +100,234 @@ func RegisterMetricRoutes(r gin.IRoutes, st *store.PostgresStore) {
 			return
 		}
 
-		count, err := st.CountEvents(c.Request.Context(), tenantID, eventName, from, to)
+		q := store.MetricsQuery{
+			TenantID:  tenantID,
+			EventName: eventName,
+			From:      from,
+			To:        to,
+		}
+
+		if raw := c.Query("filter"); raw != "" {
+			expr, err := filter.Parse(raw)
+			if err != nil {
+				var se *filter.SyntaxError
+				if errors.As(err, &se) {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"error":    "invalid filter: " + se.Msg,
+						"position": se.Pos,
+					})
+					return
+				}
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filter"})
+				return
+			}
+			q.Filter = expr
+		}
+
+		q.Aggregate, err = parseAggregate(c.Query("aggregate"), c.Query("accuracy"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"event_name": eventName,
-			"count":      count,
+		groupBy, err := parseGroupBy(c.QueryArray("group_by"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		interval := c.Query("interval")
+		switch {
+		case len(groupBy) > 0 && interval != "":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_by cannot be combined with interval"})
+		case len(groupBy) > 0 && q.Aggregate == store.AggregateUniqueUsersApprox:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_by requires accuracy=exact"})
+		case len(groupBy) > 0:
+			serveGroupedMetrics(c, st, q, groupBy)
+		case interval != "":
+			serveSeriesMetrics(c, st, q, interval)
+		case c.Query("tz") != "":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tz requires interval"})
+		default:
+			count, err := st.CountEvents(c.Request.Context(), q)
+			if err != nil {
+				internalError(c, "db query failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, withAggregate(gin.H{
+				"event_name": eventName,
+				"count":      count,
+			}, q))
+		}
+	})...)
+}
+
+// serveGroupedMetrics answers GET /metrics?group_by=... with the top groups and an other bucket.
+func serveGroupedMetrics(c *gin.Context, st *store.PostgresStore, q store.MetricsQuery, groupBy []string) {
+	limit := defaultGroupLimit
+	if v := c.Query("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxGroupLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("limit must be between 1 and %d", maxGroupLimit),
+			})
+			return
+		}
+	}
+
+	keys := make([]string, len(groupBy))
+	for i, g := range groupBy {
+		keys[i] = strings.TrimPrefix(g, groupByPrefix)
+	}
+
+	groups, other, err := st.CountEventsGrouped(c.Request.Context(), q, keys, limit)
+	if err != nil {
+		internalError(c, "db query failed", err)
+		return
+	}
+
+	total := other
+	out := make([]models.MetricsGroup, 0, len(groups))
+	for _, g := range groups {
+		total += g.Count
+		values := make(map[string]*string, len(groupBy))
+		for i, expr := range groupBy {
+			values[expr] = g.Values[i]
+		}
+		out = append(out, models.MetricsGroup{Values: values, Count: g.Count})
+	}
+
+	// Distinct actors do not add up across groups; the total needs its own query.
+	if q.Aggregate != store.AggregateCount {
+		total, err = st.CountEvents(c.Request.Context(), q)
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, withAggregate(gin.H{
+		"event_name": q.EventName,
+		"count":      total,
+		"group_by":   groupBy,
+		"groups":     out,
+		"other":      other,
+	}, q))
+}
+
+// serveSeriesMetrics answers GET /metrics?interval=... with a zero-filled bucket series.
+func serveSeriesMetrics(c *gin.Context, st *store.PostgresStore, q store.MetricsQuery, interval string) {
+	minBucket, ok := seriesIntervals[interval]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be one of minute, hour, day, week, month"})
+		return
+	}
+	if q.To.Sub(q.From)/minBucket+2 > maxSeriesBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("window too large for interval %s (max %d buckets)", interval, maxSeriesBuckets),
 		})
-	})
+		return
+	}
+
+	tz := c.DefaultQuery("tz", "UTC")
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "Local" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tz must be an IANA time zone name"})
+		return
+	}
+
+	buckets, err := st.CountEventsSeries(c.Request.Context(), q, interval, tz)
+	if err != nil {
+		internalError(c, "db query failed", err)
+		return
+	}
+
+	var total int64
+	series := make([]models.MetricsBucket, 0, len(buckets))
+	for _, b := range buckets {
+		total += b.Count
+		series = append(series, models.MetricsBucket{
+			BucketStart: b.Start.In(loc).Format(time.RFC3339),
+			Count:       b.Count,
+		})
+	}
+
+	// Distinct actors do not add up across buckets; the total needs its own query.
+	if q.Aggregate != store.AggregateCount {
+		total, err = st.CountEvents(c.Request.Context(), q)
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, withAggregate(gin.H{
+		"event_name": q.EventName,
+		"count":      total,
+		"interval":   interval,
+		"tz":         tz,
+		"series":     series,
+	}, q))
+}
+
+// parseGroupBy normalizes group_by values (repeated and/or comma-separated) and
+// validates that each one addresses a top-level property.
+func parseGroupBy(raw []string) ([]string, error) {
+	var exprs []string
+	seen := map[string]bool{}
+
+	for _, r := range raw {
+		for _, expr := range strings.Split(r, ",") {
+			expr = strings.TrimSpace(expr)
+			if expr == "" {
+				continue
+			}
+			if !strings.HasPrefix(expr, groupByPrefix) || len(expr) == len(groupByPrefix) {
+				return nil, errors.New("group_by must be properties.<key>")
+			}
+			if seen[expr] {
+				continue
+			}
+			seen[expr] = true
+			exprs = append(exprs, expr)
+		}
+	}
+
+	if len(exprs) > maxGroupKeys {
+		return nil, fmt.Errorf("at most %d group_by keys", maxGroupKeys)
+	}
+	return exprs, nil
+}
+
+// parseAggregate maps the aggregate and accuracy query params to a store aggregate.
+func parseAggregate(aggregate, accuracy string) (store.Aggregate, error) {
+	switch aggregate {
+	case "", "count":
+		if accuracy != "" {
+			return "", errors.New("accuracy requires aggregate=unique_users")
+		}
+		return store.AggregateCount, nil
+	case "unique_users":
+		switch accuracy {
+		case "", "exact":
+			return store.AggregateUniqueUsers, nil
+		case "approx":
+			return store.AggregateUniqueUsersApprox, nil
+		}
+		return "", errors.New("accuracy must be exact or approx")
+	}
+	return "", errors.New("aggregate must be count or unique_users")
+}
+
+// withAggregate annotates a metrics response with the aggregate that produced it.
+// Plain event counts keep the original response shape.
+func withAggregate(resp gin.H, q store.MetricsQuery) gin.H {
+	if q.Aggregate == store.AggregateCount {
+		return resp
+	}
+	resp["aggregate"] = "unique_users"
+	resp["approximate"] = q.Aggregate == store.AggregateUniqueUsersApprox
+	return resp
 }
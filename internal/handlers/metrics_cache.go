@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/cache"
+	"github.com/PratikDhanave/event-analytics-service/internal/logging"
+)
+
+// cacheMetrics serves GET /metrics from mc and fills it with successful
+// responses, keyed by tenant and query string. Every response carries
+// Cache-Control (private, max-age of the entry's remaining TTL) and an ETag of
+// its body; a matching If-None-Match yields 304. X-Cache reports hit or miss.
+func cacheMetrics(mc *cache.MetricsCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := auth.TenantID(c)
+		to, err := time.Parse(time.RFC3339, c.Query("to"))
+		if tenantID == "" || err != nil {
+			c.Next() // invalid requests are answered (and rejected) by the handler
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := metricsCacheKey(tenantID, c.Request.URL.Query().Encode())
+
+		body, left, ok, err := mc.Get(ctx, key)
+		if err != nil {
+			logging.FromContext(ctx).Warn("metrics cache get failed", "err", err)
+		}
+		if ok {
+			c.Header("X-Cache", "hit")
+			writeCached(c, body, left)
+			c.Abort()
+			return
+		}
+
+		// Read before computing the result: an invalidation from here on may
+		// make it stale, and then Set drops it.
+		gen, genErr := mc.Generation(ctx, tenantID, c.Query("event_name"))
+		if genErr != nil {
+			logging.FromContext(ctx).Warn("metrics cache generation failed", "err", genErr)
+		}
+
+		w := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.Status() != http.StatusOK {
+			_, _ = c.Writer.Write(w.buf.Bytes())
+			return
+		}
+
+		ttl := mc.TTL(to)
+		if genErr == nil {
+			if err := mc.Set(ctx, key, tenantID, c.Query("event_name"), gen, w.buf.Bytes(), ttl); err != nil {
+				logging.FromContext(ctx).Warn("metrics cache set failed", "err", err)
+			}
+		}
+		c.Header("X-Cache", "miss")
+		writeCached(c, w.buf.Bytes(), ttl)
+	}
+}
+
+// metricsCacheKey hashes the tenant and canonical query (sorted by parameter).
+func metricsCacheKey(tenantID, query string) string {
+	sum := sha256.Sum256([]byte(tenantID + "\x00" + query))
+	return "metrics:" + hex.EncodeToString(sum[:])
+}
+
+// writeCached writes a cacheable JSON body with its validators, or 304 when
+// the client already holds it.
+func writeCached(c *gin.Context, body []byte, maxAge time.Duration) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge/time.Second)))
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag (weakly compared).
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds back the response body so it can be cached and
+// written with its validators once the handler is done.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error)       { return w.buf.Write(b) }
+func (w *bufferedWriter) WriteString(s string) (int, error) { return w.buf.WriteString(s) }
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/logging"
+	"github.com/PratikDhanave/event-analytics-service/internal/models"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// exportWriteTimeout is how long a client may take to accept one chunk of an
+// export download.
+const exportWriteTimeout = 30 * time.Second
+
+// RegisterPrivacyRoutes registers the data subject request endpoints.
+//
+// POST /admin/privacy/erase            queue deletion of every event of a user (202)
+// POST /admin/privacy/export           queue an export of every event of a user (202)
+// GET  /admin/privacy/jobs             list jobs, ?tenant_id= (operators) and optional ?user_id=
+// GET  /admin/privacy/jobs/:id         job status
+// GET  /admin/privacy/jobs/:id/export  download a finished export as JSONL
+// GET  /admin/privacy/audit            audit trail, ?tenant_id= (operators) and optional ?user_id=
+//
+// Jobs run asynchronously (see package privacy); clients poll the job until it
+// succeeds or fails. Requests, state changes and downloads are audited.
+// Tenant admins only see and act on their own tenant.
+func RegisterPrivacyRoutes(r gin.IRoutes, st *store.PostgresStore) {
+	create := func(kind string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var req models.PrivacyRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+				return
+			}
+
+			tenantID, ok := adminTenant(c, req.TenantID)
+			if !ok {
+				return
+			}
+			req.UserID = strings.TrimSpace(req.UserID)
+			if req.UserID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+				return
+			}
+
+			id, err := newPrivacyJobID()
+			if err != nil {
+				internalError(c, "job id generation failed", err)
+				return
+			}
+
+			j, err := st.CreatePrivacyJob(c.Request.Context(), store.PrivacyJob{
+				ID:          id,
+				Kind:        kind,
+				TenantID:    tenantID,
+				UserID:      req.UserID,
+				RequestedBy: auth.Actor(c),
+			})
+			if err != nil {
+				internalError(c, "db insert failed", err)
+				return
+			}
+
+			c.Header("Location", "/admin/privacy/jobs/"+j.ID)
+			c.JSON(http.StatusAccepted, privacyJobModel(j))
+		}
+	}
+	r.POST("/admin/privacy/erase", create(store.PrivacyErase))
+	r.POST("/admin/privacy/export", create(store.PrivacyExport))
+
+	r.GET("/admin/privacy/jobs", func(c *gin.Context) {
+		tenantID, ok := adminTenant(c, c.Query("tenant_id"))
+		if !ok {
+			return
+		}
+
+		jobs, err := st.ListPrivacyJobs(c.Request.Context(), tenantID, strings.TrimSpace(c.Query("user_id")))
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+
+		out := make([]models.PrivacyJob, len(jobs))
+		for i, j := range jobs {
+			out[i] = privacyJobModel(j)
+		}
+		c.JSON(http.StatusOK, gin.H{"jobs": out})
+	})
+
+	r.GET("/admin/privacy/jobs/:id", func(c *gin.Context) {
+		j, ok := getPrivacyJob(c, st)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, privacyJobModel(j))
+	})
+
+	r.GET("/admin/privacy/jobs/:id/export", func(c *gin.Context) {
+		j, ok := getPrivacyJob(c, st)
+		if !ok {
+			return
+		}
+
+		switch {
+		case j.Kind != store.PrivacyExport:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "job is not an export"})
+			return
+		case j.Status == store.PrivacyExpired:
+			c.JSON(http.StatusGone, gin.H{"error": "export has expired"})
+			return
+		case j.Status != store.PrivacySucceeded:
+			c.JSON(http.StatusConflict, gin.H{"error": "export is " + j.Status, "status": j.Status})
+			return
+		}
+
+		if err := st.AppendPrivacyAudit(c.Request.Context(), j, "export_downloaded", auth.Actor(c), ""); err != nil {
+			internalError(c, "db insert failed", err)
+			return
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="`+j.ID+`.jsonl"`)
+		c.Status(http.StatusOK)
+
+		// Headers are sent with the first chunk; a later failure can only cut the body short.
+		// Every chunk gets its own write deadline, so large exports outlive the
+		// server's WriteTimeout as long as the client keeps reading.
+		rc := http.NewResponseController(c.Writer)
+		if err := st.StreamPrivacyExport(c.Request.Context(), j.ID, func(chunk []byte) error {
+			_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+			_, err := c.Writer.Write(chunk)
+			c.Writer.Flush()
+			return err
+		}); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("privacy export download failed", "job_id", j.ID, "err", err)
+		}
+	})
+
+	r.GET("/admin/privacy/audit", func(c *gin.Context) {
+		tenantID, ok := adminTenant(c, c.Query("tenant_id"))
+		if !ok {
+			return
+		}
+
+		entries, err := st.ListPrivacyAudit(c.Request.Context(), tenantID, strings.TrimSpace(c.Query("user_id")))
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+
+		out := make([]models.PrivacyAuditEntry, len(entries))
+		for i, a := range entries {
+			out[i] = models.PrivacyAuditEntry{
+				JobID:    a.JobID,
+				TenantID: a.TenantID,
+				UserID:   a.UserID,
+				Action:   a.Action,
+				Actor:    a.Actor,
+				Detail:   a.Detail,
+				At:       a.At.UTC().Format(time.RFC3339),
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"entries": out})
+	})
+}
+
+// adminTenant resolves the tenant an admin request applies to: the caller's
+// own tenant for tenant admins, the requested one for operators. It writes the
+// error response and returns false when there is none or it is not allowed.
+func adminTenant(c *gin.Context, requested string) (string, bool) {
+	requested = strings.TrimSpace(requested)
+	if own := auth.TenantID(c); own != "" {
+		if requested != "" && requested != own {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot access another tenant"})
+			return "", false
+		}
+		return own, true
+	}
+	if requested == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant_id is required"})
+		return "", false
+	}
+	return requested, true
+}
+
+// getPrivacyJob loads the job named by :id, writing 404 when it does not exist
+// or belongs to another tenant.
+func getPrivacyJob(c *gin.Context, st *store.PostgresStore) (store.PrivacyJob, bool) {
+	j, err := st.GetPrivacyJob(c.Request.Context(), c.Param("id"), auth.TenantID(c))
+	switch {
+	case errors.Is(err, store.ErrPrivacyJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "privacy job not found"})
+		return store.PrivacyJob{}, false
+	case err != nil:
+		internalError(c, "db query failed", err)
+		return store.PrivacyJob{}, false
+	}
+	return j, true
+}
+
+// newPrivacyJobID returns e.g. "pj_3f9c0a...", 16 random bytes in hex.
+func newPrivacyJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "pj_" + hex.EncodeToString(b), nil
+}
+
+func privacyJobModel(j store.PrivacyJob) models.PrivacyJob {
+	m := models.PrivacyJob{
+		ID:          j.ID,
+		Kind:        j.Kind,
+		TenantID:    j.TenantID,
+		UserID:      j.UserID,
+		Status:      j.Status,
+		RequestedBy: j.RequestedBy,
+		Events:      j.Events,
+		Error:       j.Error,
+		CreatedAt:   j.CreatedAt.UTC().Format(time.RFC3339),
+		StartedAt:   formatTimePtr(j.StartedAt),
+		FinishedAt:  formatTimePtr(j.FinishedAt),
+		ExpiresAt:   formatTimePtr(j.ExpiresAt),
+	}
+	if j.Kind == store.PrivacyExport && j.Status == store.PrivacySucceeded {
+		m.ExportURL = "/admin/privacy/jobs/" + j.ID + "/export"
+	}
+	return m
+}
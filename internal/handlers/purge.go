@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/purge"
+)
+
+// RegisterPurgeRoutes registers the data retention endpoints.
+//
+// GET  /admin/purge          retention policies, purge counters and the last run
+// POST /admin/purge/preview  dry run: report what a purge would delete now, deleting nothing
+//
+// The caller is responsible for admin authentication (auth.AdminMiddleware).
+// Tenant admins only see their own policy and their own part of the last run;
+// previews scan every tenant and are restricted to operators.
+func RegisterPurgeRoutes(r gin.IRoutes, p *purge.Purger) {
+	r.GET("/admin/purge", func(c *gin.Context) {
+		def, tenants := p.Policies()
+		stats := p.Stats()
+
+		if own := auth.TenantID(c); own != "" {
+			var last []purge.TenantReport
+			if stats.LastRun != nil {
+				for _, t := range stats.LastRun.Tenants {
+					if t.TenantID == own {
+						last = append(last, t)
+					}
+				}
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"tenant_id": own,
+				"retention": formatRetention(p.Retention(own)),
+				"last_run":  last,
+			})
+			return
+		}
+
+		policies := make(map[string]string, len(tenants))
+		for t, r := range tenants {
+			policies[t] = formatRetention(r)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"default_retention": formatRetention(def),
+			"tenants":           policies,
+			"dry_run":           p.DryRun(),
+			"stats":             stats,
+		})
+	})
+
+	r.POST("/admin/purge/preview", func(c *gin.Context) {
+		if auth.TenantID(c) != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "purge previews require the operator admin key"})
+			return
+		}
+
+		rep, err := p.Preview(c.Request.Context())
+		if err != nil {
+			internalError(c, "purge preview failed", err)
+			return
+		}
+		c.JSON(http.StatusOK, rep)
+	})
+}
+
+// formatRetention renders a retention period as whole days ("90d") when it is
+// one, "0" when events are kept forever, and a Go duration otherwise.
+func formatRetention(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d <= 0:
+		return "0"
+	case d%day == 0:
+		return strconv.Itoa(int(d/day)) + "d"
+	default:
+		return d.String()
+	}
+}
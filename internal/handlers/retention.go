@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/models"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// Retention bounds: offsets per cohort and cohort rows per request.
+const (
+	defaultRetentionPeriods = 7
+	maxRetentionPeriods     = 90
+	maxRetentionCohorts     = 400
+)
+
+// RegisterRetentionRoutes registers the cohort retention endpoint.
+//
+// GET /retention?start_event=...&return_event=...&from=...&to=...&granularity=day|week|month
+// - Requires X-API-Key (tenant context)
+// - Cohorts actors by the period of their first start_event in [from,to)
+// - periods (default 7) sets how many follow-up periods are reported
+// - tz (IANA name, default UTC) sets the calendar periods align to
+// - return_event defaults to start_event
+func RegisterRetentionRoutes(r gin.IRoutes, st *store.PostgresStore) {
+	r.GET("/retention", func(c *gin.Context) {
+		tenantID := auth.TenantID(c)
+		if tenantID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		startEvent := c.Query("start_event")
+		returnEvent := c.DefaultQuery("return_event", startEvent)
+		fromStr := c.Query("from")
+		toStr := c.Query("to")
+
+		if startEvent == "" || fromStr == "" || toStr == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_event, from, to are required"})
+			return
+		}
+
+		from, err := parseRFC3339(fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be RFC3339"})
+			return
+		}
+		to, err := parseRFC3339(toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be RFC3339"})
+			return
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be < to"})
+			return
+		}
+
+		granularity := c.DefaultQuery("granularity", "day")
+		switch granularity {
+		case "day", "week", "month":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be one of day, week, month"})
+			return
+		}
+		if to.Sub(from)/seriesIntervals[granularity]+2 > maxRetentionCohorts {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("window too large for granularity %s (max %d cohorts)", granularity, maxRetentionCohorts),
+			})
+			return
+		}
+
+		periods := defaultRetentionPeriods
+		if v := c.Query("periods"); v != "" {
+			periods, err = strconv.Atoi(v)
+			if err != nil || periods < 1 || periods > maxRetentionPeriods {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("periods must be between 1 and %d", maxRetentionPeriods),
+				})
+				return
+			}
+		}
+
+		tz := c.DefaultQuery("tz", "UTC")
+		loc, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tz must be an IANA time zone name"})
+			return
+		}
+
+		cohorts, err := st.Retention(c.Request.Context(), store.RetentionQuery{
+			TenantID:    tenantID,
+			StartEvent:  startEvent,
+			ReturnEvent: returnEvent,
+			From:        from,
+			To:          to,
+			Unit:        granularity,
+			TZ:          tz,
+			Periods:     periods,
+		})
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+
+		now := time.Now()
+		out := make([]models.RetentionCohort, 0, len(cohorts))
+		for _, ch := range cohorts {
+			start := ch.Start.In(loc)
+			row := models.RetentionCohort{
+				CohortStart: start.Format(time.RFC3339),
+				Size:        ch.Size,
+				Retained:    make([]*int64, len(ch.Retained)),
+				Rates:       make([]*float64, len(ch.Retained)),
+			}
+			for k, n := range ch.Retained {
+				if periodStart(start, granularity, k).After(now) {
+					continue
+				}
+				rate := ratio(n, ch.Size)
+				row.Retained[k] = &n
+				row.Rates[k] = &rate
+			}
+			out = append(out, row)
+		}
+
+		c.JSON(http.StatusOK, models.RetentionResponse{
+			StartEvent:  startEvent,
+			ReturnEvent: returnEvent,
+			Granularity: granularity,
+			TZ:          tz,
+			Periods:     periods,
+			Cohorts:     out,
+		})
+	})
+}
+
+// periodStart returns the start of the k-th period after start in start's location.
+func periodStart(start time.Time, granularity string, k int) time.Time {
+	switch granularity {
+	case "week":
+		return start.AddDate(0, 0, 7*k)
+	case "month":
+		return start.AddDate(0, k, 0)
+	}
+	return start.AddDate(0, 0, k)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/models"
+	"github.com/PratikDhanave/event-analytics-service/internal/schema"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// maxSchemaSize bounds a registered schema document.
+const maxSchemaSize = 64 << 10
+
+// RegisterSchemaRoutes registers the event schema registry endpoints.
+//
+// GET    /admin/schemas              list schemas, ?tenant_id= (operators)
+// GET    /admin/schemas/:event_name  one schema
+// PUT    /admin/schemas/:event_name  register or replace a schema (201 when new)
+// DELETE /admin/schemas/:event_name  remove a schema
+//
+// Schemas are JSON Schema documents for the properties of an event; see
+// package schema for the supported keywords. Unsupported keywords and invalid
+// documents are rejected with 400 rather than stored. reg is told about every
+// change so this replica validates with it at once. Tenant admins only see and
+// manage their own tenant.
+func RegisterSchemaRoutes(r gin.IRoutes, st *store.PostgresStore, reg *schema.Registry) {
+	r.GET("/admin/schemas", func(c *gin.Context) {
+		tenantID, ok := adminTenant(c, c.Query("tenant_id"))
+		if !ok {
+			return
+		}
+
+		schemas, err := st.ListEventSchemas(c.Request.Context(), tenantID)
+		if err != nil {
+			internalError(c, "db query failed", err)
+			return
+		}
+
+		out := make([]models.EventSchema, len(schemas))
+		for i, s := range schemas {
+			out[i] = eventSchemaModel(s)
+		}
+		c.JSON(http.StatusOK, gin.H{"schemas": out})
+	})
+
+	r.GET("/admin/schemas/:event_name", func(c *gin.Context) {
+		tenantID, ok := adminTenant(c, c.Query("tenant_id"))
+		if !ok {
+			return
+		}
+
+		s, err := st.GetEventSchema(c.Request.Context(), tenantID, c.Param("event_name"))
+		switch {
+		case errors.Is(err, store.ErrEventSchemaNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "event schema not found"})
+			return
+		case err != nil:
+			internalError(c, "db query failed", err)
+			return
+		}
+		c.JSON(http.StatusOK, eventSchemaModel(s))
+	})
+
+	r.PUT("/admin/schemas/:event_name", func(c *gin.Context) {
+		var req models.EventSchemaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+			return
+		}
+
+		tenantID, ok := adminTenant(c, req.TenantID)
+		if !ok {
+			return
+		}
+		eventName := strings.TrimSpace(c.Param("event_name"))
+		if eventName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "event_name is required"})
+			return
+		}
+		if len(req.Schema) == 0 || string(req.Schema) == "null" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "schema is required"})
+			return
+		}
+		if len(req.Schema) > maxSchemaSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "schema must be at most 64 KiB"})
+			return
+		}
+		if _, err := schema.Compile(req.Schema); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schema: " + err.Error()})
+			return
+		}
+
+		s, err := st.PutEventSchema(c.Request.Context(), store.EventSchema{
+			TenantID:  tenantID,
+			EventName: eventName,
+			Schema:    req.Schema,
+		})
+		if err != nil {
+			internalError(c, "db upsert failed", err)
+			return
+		}
+		reg.Invalidate(tenantID)
+
+		status := http.StatusOK
+		if s.Version == 1 {
+			status = http.StatusCreated
+		}
+		c.JSON(status, eventSchemaModel(s))
+	})
+
+	r.DELETE("/admin/schemas/:event_name", func(c *gin.Context) {
+		tenantID, ok := adminTenant(c, c.Query("tenant_id"))
+		if !ok {
+			return
+		}
+
+		err := st.DeleteEventSchema(c.Request.Context(), tenantID, c.Param("event_name"))
+		switch {
+		case errors.Is(err, store.ErrEventSchemaNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "event schema not found"})
+			return
+		case err != nil:
+			internalError(c, "db delete failed", err)
+			return
+		}
+		reg.Invalidate(tenantID)
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// eventSchemaModel converts a stored schema to its API representation.
+func eventSchemaModel(s store.EventSchema) models.EventSchema {
+	return models.EventSchema{
+		TenantID:  s.TenantID,
+		EventName: s.EventName,
+		Schema:    s.Schema,
+		Version:   s.Version,
+		CreatedAt: s.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: s.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
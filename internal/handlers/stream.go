@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/live"
+	"github.com/PratikDhanave/event-analytics-service/internal/models"
+	"github.com/PratikDhanave/event-analytics-service/internal/tracing"
+)
+
+// Stream tick bounds and how long a client may take to accept one message
+// before it is disconnected.
+const (
+	defaultStreamInterval = time.Second
+	minStreamInterval     = time.Second
+	maxStreamInterval     = time.Minute
+	streamWriteTimeout    = 10 * time.Second
+)
+
+// RegisterStreamRoutes registers the live counter stream.
+//
+// GET /metrics/stream?event_name=...[&interval=1s]
+// - Requires X-API-Key (tenant context)
+// - Server-Sent Events: one "count" event per interval (1s..1m)
+// - count is the number of events of event_name ingested since the previous event
+// - 429 when the tenant already has LIVE_MAX_STREAMS streams open
+//
+// Counts accumulate between ticks instead of queueing, and a client that does
+// not accept a message within streamWriteTimeout is disconnected. Each write
+// sets its own deadline, replacing the server's WriteTimeout, and streams end
+// when the hub drains on shutdown.
+func RegisterStreamRoutes(r gin.IRoutes, hub *live.Hub) {
+	r.GET("/metrics/stream", func(c *gin.Context) {
+		tenantID := auth.TenantID(c)
+		if tenantID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		eventName := c.Query("event_name")
+		if eventName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "event_name is required"})
+			return
+		}
+		tracing.SetAttr(c.Request.Context(), "event_name", eventName)
+
+		interval := defaultStreamInterval
+		if v := c.Query("interval"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < minStreamInterval || d > maxStreamInterval {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("interval must be a duration between %s and %s", minStreamInterval, maxStreamInterval),
+				})
+				return
+			}
+			interval = d
+		}
+
+		sub, err := hub.Subscribe(tenantID, eventName)
+		if err != nil { // live.ErrTooManyStreams
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		defer sub.Close()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no") // keep reverse proxies from buffering the stream
+		c.Status(http.StatusOK)
+
+		rc := http.NewResponseController(c.Writer)
+		send := func(b []byte) bool {
+			_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := c.Writer.Write(b); err != nil {
+				return false
+			}
+			return rc.Flush() == nil
+		}
+
+		if !send([]byte("retry: 3000\n\n")) {
+			return
+		}
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-hub.Draining(): // shutting down; the client reconnects elsewhere
+				return
+			case now := <-t.C:
+				n := sub.Take()
+				data, _ := json.Marshal(models.LiveCount{
+					EventName: eventName,
+					Count:     n,
+					PerSecond: float64(n) / interval.Seconds(),
+					At:        now.UTC().Format(time.RFC3339),
+				})
+				if !send([]byte("event: count\ndata: " + string(data) + "\n\n")) {
+					return
+				}
+			}
+		}
+	})
+}
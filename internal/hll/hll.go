@@ -0,0 +1,47 @@
+// Package hll implements the HyperLogLog cardinality estimate.
+//
+// The store computes the registers inside Postgres (one row per non-empty
+// register, see store.registersSQL) and only ships their summary back, so this
+// package only turns those register statistics into an estimate.
+package hll
+
+import "math"
+
+// Precision is the number of hash bits used to pick a register (m = 2^Precision).
+// 14 gives 16384 registers and a standard error of about 0.8%.
+//
+// Registers follow the usual layout over 64-bit hashes: the low Precision bits
+// pick the register and the rank is the position of the first set bit in the
+// remaining 64-Precision bits, counted from the most significant one
+// (64-Precision+1 when they are all zero).
+const Precision = 14
+
+// EstimateFromRegisters estimates cardinality from register statistics:
+// sum is Σ 2^-rank over the non-zero registers and nonZero is how many there are.
+// Empty registers contribute 2^0 each and are accounted for here.
+func EstimateFromRegisters(p uint8, sum float64, nonZero int) float64 {
+	m := float64(uint64(1) << p)
+	zeros := m - float64(nonZero)
+
+	raw := alpha(m) * m * m / (sum + zeros)
+
+	// Small-range correction: linear counting is more accurate while many
+	// registers are still empty. With 64-bit hashes no large-range correction
+	// is needed.
+	if raw <= 2.5*m && zeros > 0 {
+		return m * math.Log(m/zeros)
+	}
+	return raw
+}
+
+func alpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/m)
+}
@@ -0,0 +1,50 @@
+package hll
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+// estimate builds registers from hashes with the documented layout and
+// estimates their cardinality.
+func estimate(hashes []uint64) float64 {
+	registers := make([]uint8, 1<<Precision)
+	for _, h := range hashes {
+		idx := h & (1<<Precision - 1)
+		rank := uint8(bits.LeadingZeros64(h>>Precision) - Precision + 1)
+		registers[idx] = max(registers[idx], rank)
+	}
+
+	var (
+		sum     float64
+		nonZero int
+	)
+	for _, r := range registers {
+		if r != 0 {
+			sum += math.Ldexp(1, -int(r))
+			nonZero++
+		}
+	}
+	return EstimateFromRegisters(Precision, sum, nonZero)
+}
+
+func TestEstimateFromRegisters_WithinError(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{0, 10, 1000, 50000, 300000} {
+		hashes := make([]uint64, 0, 2*n)
+		for i := 0; i < n; i++ {
+			h := rng.Uint64()
+			hashes = append(hashes, h, h) // duplicates must not change the estimate
+		}
+
+		got := estimate(hashes)
+		// Allow ~4 standard errors (1.04/sqrt(m) ≈ 0.8%) plus a little slack for tiny n.
+		tol := 0.035*float64(n) + 1
+		if math.Abs(got-float64(n)) > tol {
+			t.Fatalf("n=%d: estimate %.1f outside ±%.1f", n, got, tol)
+		}
+	}
+}
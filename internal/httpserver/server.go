@@ -8,43 +8,153 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/cache"
 	"github.com/PratikDhanave/event-analytics-service/internal/config"
 	"github.com/PratikDhanave/event-analytics-service/internal/handlers"
+	"github.com/PratikDhanave/event-analytics-service/internal/live"
+	"github.com/PratikDhanave/event-analytics-service/internal/logging"
+	"github.com/PratikDhanave/event-analytics-service/internal/purge"
+	"github.com/PratikDhanave/event-analytics-service/internal/ratelimit"
+	"github.com/PratikDhanave/event-analytics-service/internal/schema"
 	"github.com/PratikDhanave/event-analytics-service/internal/store"
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+	"github.com/PratikDhanave/event-analytics-service/internal/tracing"
 )
 
 // NewRouter wires public endpoints and authenticated APIs.
 // Public: /health, /ready
-// Authenticated: /events, /metrics
-func NewRouter(cfg config.Config, st *store.PostgresStore) *gin.Engine {
+// Authenticated (events:write): /events
+// Authenticated (metrics:read): /metrics, /metrics/stream, /funnels, /retention, /events/catalog
+// Admin (X-Admin-Key or admin scope): /admin/keys, /admin/purge, /admin/privacy, /admin/schemas
+//
+// Tenant routes accept X-API-Key or, when JWT_JWKS is configured, a bearer JWT.
+// An error is returned if the JWK set cannot be loaded. purger backs the
+// retention endpoints and is shared with the background purge job. metrics
+// caches GET /metrics responses; nil disables caching. hub serves
+// GET /metrics/stream; nil leaves it out. Every request is traced (see
+// package tracing), continuing the caller's trace when a traceparent is sent,
+// and logged with its request ID (see package logging). /ready reports 503
+// once draining returns true, so load balancers stop routing to a replica
+// that is shutting down; nil never drains.
+func NewRouter(
+	cfg config.Config,
+	st *store.PostgresStore,
+	purger *purge.Purger,
+	metrics *cache.MetricsCache,
+	hub *live.Hub,
+	draining func() bool,
+) (*gin.Engine, error) {
 	gin.SetMode(gin.ReleaseMode)
 
 	r := gin.New()
-	r.Use(gin.Recovery())
+	// The span comes first so the access log carries its trace ID. Recovery
+	// comes last so the access log, telemetry and the span of a request that
+	// panicked still see its 500.
+	r.Use(
+		tracing.Middleware(cfg.TracingServiceName),
+		logging.Middleware(auth.TenantID),
+		telemetry.Middleware(),
+		tracing.Tenant(auth.TenantID),
+		logging.Recovery(),
+	)
 
 	// Liveness: confirms the process is running.
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	// Readiness: confirms the DB dependency is reachable.
+	// Readiness: confirms the DB dependency is reachable and the replica is not shutting down.
 	r.GET("/ready", func(c *gin.Context) {
+		if draining != nil && draining() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second)
 		defer cancel()
 
 		if err := st.Ping(ctx); err != nil {
+			logging.FromContext(ctx).Warn("readiness check failed", "err", err)
 			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready", "error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"status": "ready"})
 	})
 
-	// Auth group enforces tenant context via X-API-Key.
-	authGroup := r.Group("/")
-	authGroup.Use(auth.APIKeyMiddleware(cfg.APIKeys))
+	// Authenticated groups resolve the tenant from X-API-Key or a bearer JWT, check
+	// the credential's scope for the group, then apply per-tenant rate limits and quotas.
+	keys := auth.NewKeyValidator(st, cfg.APIKeyCacheTTL)
+	for key, tenantID := range cfg.DevAPIKeys {
+		k, err := auth.LegacyAPIKey(key, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		keys.AddStaticKey(k)
+	}
+
+	var jwt *auth.JWTVerifier
+	if cfg.JWTJWKS != "" {
+		var err error
+		jwt, err = auth.NewJWTVerifier(auth.JWTConfig{
+			JWKS:        cfg.JWTJWKS,
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			TenantClaim: cfg.JWTTenantClaim,
+			ScopeClaim:  cfg.JWTScopeClaim,
+			Refresh:     cfg.JWTJWKSRefresh,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	tenantAuth := auth.Middleware(keys, jwt)
+
+	limiter := ratelimit.New(cfg.DefaultLimits, cfg.TenantLimits, st)
+
+	ingestGroup := r.Group("/")
+	ingestGroup.Use(tenantAuth, auth.RequireScope(auth.ScopeEventsWrite), limiter.Middleware())
 
-	handlers.RegisterEventRoutes(authGroup, st)
-	handlers.RegisterMetricRoutes(authGroup, st)
+	schemas := schema.NewRegistry(st, schema.Options{
+		DefaultMode: cfg.SchemaValidation,
+		TenantModes: cfg.TenantSchemaValidation,
+		CacheTTL:    cfg.SchemaCacheTTL,
+	})
+	handlers.RegisterEventRoutes(ingestGroup, st, cfg.IngestMode == config.IngestModeAsync, schemas)
+
+	readGroup := r.Group("/")
+	readGroup.Use(tenantAuth, auth.RequireScope(auth.ScopeMetricsRead), limiter.Middleware())
+
+	handlers.RegisterMetricRoutes(readGroup, st, metrics)
+	if hub != nil {
+		handlers.RegisterStreamRoutes(readGroup, hub)
+	}
+	handlers.RegisterFunnelRoutes(readGroup, st)
+	handlers.RegisterRetentionRoutes(readGroup, st)
+	handlers.RegisterCatalogRoutes(readGroup, st)
+
+	// Admin group manages API keys, data retention, data subject requests and
+	// event schemas: every tenant with the operator key, or the caller's own
+	// tenant with an admin-scoped API key.
+	adminGroup := r.Group("/")
+	adminGroup.Use(auth.AdminMiddleware(cfg.AdminAPIKey, keys))
+
+	handlers.RegisterAdminRoutes(adminGroup, st, keys)
+	handlers.RegisterPurgeRoutes(adminGroup, purger)
+	handlers.RegisterPrivacyRoutes(adminGroup, st)
+	handlers.RegisterSchemaRoutes(adminGroup, st, schemas)
+
+	return r, nil
+}
 
-	return r
+// NewServer returns the HTTP server for handler, listening on cfg.HTTPAddr
+// with the configured timeouts.
+func NewServer(cfg config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.HTTPAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: min(cfg.HTTPReadTimeout, 5*time.Second),
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
 }
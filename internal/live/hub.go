@@ -0,0 +1,266 @@
+// Package live fans out per-event-name ingestion counts to streaming clients
+// (GET /metrics/stream).
+//
+// Every process that writes events (API replicas, cmd/worker) sums what it
+// wrote per tenant and event name and publishes the sums on a Postgres
+// notification channel once per flush interval. Every API replica listens on
+// that channel and adds the sums to the matching subscriptions, so a stream on
+// any replica sees events ingested by all of them.
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// channel is the Postgres notification channel carrying count deltas.
+const channel = "event_counts"
+
+// maxPayload keeps notifications below Postgres' 8000 byte payload limit.
+const maxPayload = 7500
+
+// ErrTooManyStreams is returned by Subscribe when a tenant has MaxStreams open.
+var ErrTooManyStreams = errors.New("too many live streams for tenant")
+
+// Options tunes a Hub.
+type Options struct {
+	FlushInterval time.Duration // how often locally written counts are published
+	MaxStreams    int           // concurrent subscriptions per tenant
+}
+
+type scope struct {
+	tenantID  string
+	eventName string
+}
+
+// delta is the wire format of one published count.
+type delta struct {
+	TenantID  string `json:"t"`
+	EventName string `json:"n"`
+	Count     int64  `json:"c"`
+}
+
+// Hub publishes local counts and delivers published counts to subscriptions.
+type Hub struct {
+	st   *store.PostgresStore
+	opts Options
+
+	mu      sync.Mutex
+	pending map[scope]int64 // written here, not yet published
+	subs    map[scope]map[*Subscription]struct{}
+	streams map[string]int // tenantID -> open subscriptions
+
+	draining  chan struct{} // closed by Drain
+	drainOnce sync.Once
+}
+
+// New creates a hub over the given store.
+func New(st *store.PostgresStore, opts Options) *Hub {
+	return &Hub{
+		st:       st,
+		opts:     opts,
+		pending:  map[scope]int64{},
+		subs:     map[scope]map[*Subscription]struct{}{},
+		streams:  map[string]int{},
+		draining: make(chan struct{}),
+	}
+}
+
+// Drain asks every stream to end, e.g. when the server shuts down; clients
+// reconnect (to another replica) after the advertised retry delay.
+func (h *Hub) Drain() {
+	h.drainOnce.Do(func() { close(h.draining) })
+}
+
+// Draining is closed once Drain has been called.
+func (h *Hub) Draining() <-chan struct{} {
+	return h.draining
+}
+
+// Changed records events written by this process; it is a store.ChangeHook.
+func (h *Hub) Changed(_ context.Context, c store.Change) {
+	if c.Written == 0 {
+		return
+	}
+	h.mu.Lock()
+	h.pending[scope{c.TenantID, c.EventName}] += int64(c.Written)
+	h.mu.Unlock()
+}
+
+// Run publishes local counts and delivers the counts of every process to
+// subscriptions until ctx is cancelled. A lost listening connection is
+// re-established; counts published meanwhile are missed.
+func (h *Hub) Run(ctx context.Context) {
+	go h.Publish(ctx)
+
+	for ctx.Err() == nil {
+		err := h.st.Listen(ctx, channel, h.deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("live: listening failed", "err", err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Publish publishes local counts every FlushInterval until ctx is cancelled,
+// without listening (e.g. in cmd/worker, which serves no streams). Counts
+// written since the last tick are published once more on the way out.
+func (h *Hub) Publish(ctx context.Context) {
+	t := time.NewTicker(h.opts.FlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := h.flush(final); err != nil {
+				slog.Error("live: publishing counts failed", "err", err)
+			}
+			return
+		case <-t.C:
+		}
+		if err := h.flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("live: publishing counts failed", "err", err)
+		}
+	}
+}
+
+// flush publishes and resets the pending counts, in as many notifications as
+// the payload limit requires. Counts that fail to publish are dropped; live
+// counters are best effort. A failed notification does not hold back the
+// others; the first error is returned.
+func (h *Hub) flush(ctx context.Context) error {
+	h.mu.Lock()
+	pending := h.pending
+	h.pending = map[scope]int64{}
+	h.mu.Unlock()
+
+	var first error
+	for _, payload := range payloads(pending) {
+		if err := h.st.Notify(ctx, channel, payload); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// payloads encodes pending as JSON arrays of deltas of at most maxPayload
+// bytes each. A delta too large for a notification on its own (a very long
+// tenant ID or event name) is logged and skipped.
+func payloads(pending map[scope]int64) []string {
+	var (
+		out   []string
+		batch []byte
+	)
+	for k, n := range pending {
+		d, err := json.Marshal(delta{TenantID: k.tenantID, EventName: k.eventName, Count: n})
+		if err != nil {
+			slog.Warn("live: encoding count failed", "err", err)
+			continue
+		}
+		if len(d)+2 > maxPayload {
+			slog.Warn("live: count too large to publish, skipped",
+				"tenant_id", k.tenantID, "event_name_bytes", len(k.eventName))
+			continue
+		}
+		// batch holds "[" and the deltas so far; "," and "]" are added below.
+		if len(batch) > 0 && len(batch)+1+len(d)+1 > maxPayload {
+			out = append(out, string(append(batch, ']')))
+			batch = batch[:0]
+		}
+		if len(batch) == 0 {
+			batch = append(batch, '[')
+		} else {
+			batch = append(batch, ',')
+		}
+		batch = append(batch, d...)
+	}
+	if len(batch) > 0 {
+		out = append(out, string(append(batch, ']')))
+	}
+	return out
+}
+
+// deliver adds a published payload to the matching subscriptions.
+func (h *Hub) deliver(payload string) {
+	var deltas []delta
+	if err := json.Unmarshal([]byte(payload), &deltas); err != nil {
+		slog.Warn("live: malformed notification", "err", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, d := range deltas {
+		for s := range h.subs[scope{d.TenantID, d.EventName}] {
+			s.count.Add(d.Count)
+		}
+	}
+}
+
+// Subscription accumulates the count of one tenant's event name. Counts are
+// summed rather than queued, so a slow reader costs no memory: it simply
+// takes a larger delta next time.
+type Subscription struct {
+	h     *Hub
+	key   scope
+	count atomic.Int64
+}
+
+// Subscribe opens a subscription to the events of eventName written for
+// tenantID, or returns ErrTooManyStreams.
+func (h *Hub) Subscribe(tenantID, eventName string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.streams[tenantID] >= h.opts.MaxStreams {
+		return nil, ErrTooManyStreams
+	}
+	h.streams[tenantID]++
+
+	s := &Subscription{h: h, key: scope{tenantID, eventName}}
+	subs := h.subs[s.key]
+	if subs == nil {
+		subs = map[*Subscription]struct{}{}
+		h.subs[s.key] = subs
+	}
+	subs[s] = struct{}{}
+	return s, nil
+}
+
+// Take returns the count accumulated since the previous call.
+func (s *Subscription) Take() int64 {
+	return s.count.Swap(0)
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs := h.subs[s.key]
+	if _, ok := subs[s]; !ok {
+		return // already closed
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.subs, s.key)
+	}
+	if h.streams[s.key.tenantID]--; h.streams[s.key.tenantID] <= 0 {
+		delete(h.streams, s.key.tenantID)
+	}
+}
@@ -0,0 +1,123 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+func TestHub_DeliversToMatchingSubscriptions(t *testing.T) {
+	h := New(nil, Options{MaxStreams: 2})
+
+	a, _ := h.Subscribe("t1", "signup")
+	b, _ := h.Subscribe("t1", "signup")
+	other, _ := New(nil, Options{MaxStreams: 1}).Subscribe("t1", "signup")
+
+	h.deliver(`[{"t":"t1","n":"signup","c":3},{"t":"t2","n":"signup","c":5},{"t":"t1","n":"login","c":7}]`)
+	h.deliver(`[{"t":"t1","n":"signup","c":1}]`)
+
+	if got := a.Take(); got != 4 {
+		t.Fatalf("a: got %d", got)
+	}
+	if got := a.Take(); got != 0 {
+		t.Fatalf("a after take: got %d", got)
+	}
+	if got := b.Take(); got != 4 {
+		t.Fatalf("b: got %d", got)
+	}
+	if got := other.Take(); got != 0 {
+		t.Fatalf("subscription of another hub: got %d", got)
+	}
+
+	a.Close()
+	h.deliver(`[{"t":"t1","n":"signup","c":2}]`)
+	if got := a.Take(); got != 0 {
+		t.Fatalf("closed subscription: got %d", got)
+	}
+}
+
+func TestHub_LimitsStreamsPerTenant(t *testing.T) {
+	h := New(nil, Options{MaxStreams: 1})
+
+	s, err := h.Subscribe("t1", "signup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Subscribe("t1", "login"); !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("expected ErrTooManyStreams, got %v", err)
+	}
+	if _, err := h.Subscribe("t2", "signup"); err != nil {
+		t.Fatalf("other tenant: %v", err)
+	}
+
+	s.Close()
+	s.Close() // idempotent
+	if _, err := h.Subscribe("t1", "login"); err != nil {
+		t.Fatalf("after close: %v", err)
+	}
+}
+
+func TestHub_RecordsOnlyWrites(t *testing.T) {
+	h := New(nil, Options{})
+	ctx := context.Background()
+
+	h.Changed(ctx, store.Change{TenantID: "t1", EventName: "signup", Written: 2})
+	h.Changed(ctx, store.Change{TenantID: "t1", EventName: "signup", Written: 3})
+	h.Changed(ctx, store.Change{TenantID: "t1"}) // deletion
+
+	if len(h.pending) != 1 || h.pending[scope{"t1", "signup"}] != 5 {
+		t.Fatalf("pending: %v", h.pending)
+	}
+}
+
+func TestHub_Drain(t *testing.T) {
+	h := New(nil, Options{})
+
+	select {
+	case <-h.Draining():
+		t.Fatal("draining before Drain")
+	default:
+	}
+
+	h.Drain()
+	h.Drain() // idempotent
+	select {
+	case <-h.Draining():
+	default:
+		t.Fatal("not draining after Drain")
+	}
+}
+
+func TestPayloads_SplitsAndSkipsOversizeCounts(t *testing.T) {
+	pending := map[scope]int64{
+		{"t1", strings.Repeat("x", maxPayload)}: 1,
+	}
+	for i := 0; i < 500; i++ {
+		pending[scope{"t1", fmt.Sprintf("event-%03d", i)}] = int64(i + 1)
+	}
+
+	var total int64
+	for _, p := range payloads(pending) {
+		if len(p) > maxPayload {
+			t.Fatalf("payload of %d bytes", len(p))
+		}
+		var deltas []delta
+		if err := json.Unmarshal([]byte(p), &deltas); err != nil {
+			t.Fatalf("payload %q: %v", p, err)
+		}
+		for _, d := range deltas {
+			if len(d.EventName) > 100 {
+				t.Fatal("oversize count published")
+			}
+			total += d.Count
+		}
+	}
+	if want := int64(500 * 501 / 2); total != want {
+		t.Fatalf("published %d, want %d", total, want)
+	}
+}
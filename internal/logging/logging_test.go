@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(func(*gin.Context) string { return "tenant1" }), Recovery())
+	r.POST("/events", func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+	r.GET("/panic", func(*gin.Context) { panic("boom") })
+	return r, &buf
+}
+
+func TestMiddleware_AccessLog(t *testing.T) {
+	r, buf := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/events?secret=q", strings.NewReader(`{"properties":{"email":"a@b.c"}}`))
+	req.Header.Set("X-API-Key", "tenant-key-123")
+	req.Header.Set(HeaderRequestID, "req-42")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(HeaderRequestID); got != "req-42" {
+		t.Fatalf("X-Request-ID = %q, want the incoming one", got)
+	}
+	for _, secret := range []string{"tenant-key-123", "a@b.c", "secret=q"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Fatalf("log leaks %q: %s", secret, buf.String())
+		}
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v: %s", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"level":      "INFO",
+		"msg":        "request",
+		"request_id": "req-42",
+		"method":     "POST",
+		"route":      "/events",
+		"path":       "/events",
+		"status":     float64(201),
+		"bytes":      float64(w.Body.Len()),
+		"tenant_id":  "tenant1",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+	if _, ok := line["latency_ms"]; !ok {
+		t.Error("latency_ms missing")
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	for _, incoming := range []string{"", "has space", strings.Repeat("x", maxRequestID+1)} {
+		req := httptest.NewRequest(http.MethodPost, "/events", nil)
+		req.Header.Set(HeaderRequestID, incoming)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if got := w.Header().Get(HeaderRequestID); got == "" || got == incoming {
+			t.Errorf("incoming %q: X-Request-ID = %q, want a generated ID", incoming, got)
+		}
+	}
+}
+
+func TestRecovery_LogsPanicWithoutHeaders(t *testing.T) {
+	r, buf := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("X-API-Key", "tenant-key-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"msg":"panic"`) || !strings.Contains(out, `"status":500`) {
+		t.Fatalf("panic or its access log line missing: %s", out)
+	}
+	if strings.Contains(out, "tenant-key-123") {
+		t.Fatalf("log leaks the API key: %s", out)
+	}
+}
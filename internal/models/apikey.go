@@ -0,0 +1,33 @@
+package models
+
+// APIKeyCreateRequest is the POST /admin/keys payload.
+// Scopes may be omitted; ExpiresAt is an optional RFC3339 timestamp.
+type APIKeyCreateRequest struct {
+	TenantID  string   `json:"tenant_id"`
+	Scopes    []string `json:"scopes,omitempty"`
+	ExpiresAt string   `json:"expires_at,omitempty"`
+}
+
+// APIKeyRotateRequest is the optional POST /admin/keys/:id/rotate payload.
+// GracePeriod keeps the old key valid for a while, e.g. "1h" or "7d".
+type APIKeyRotateRequest struct {
+	GracePeriod string `json:"grace_period,omitempty"`
+}
+
+// APIKey describes a stored key. It never contains the secret.
+type APIKey struct {
+	ID         string   `json:"id"`
+	TenantID   string   `json:"tenant_id"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  *string  `json:"expires_at"`
+	RevokedAt  *string  `json:"revoked_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+}
+
+// APIKeyCreated is returned when a key is created or rotated. Key is the
+// plaintext credential; it is only ever returned here.
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "encoding/json"
+
+// CatalogResponse is returned by GET /events/catalog.
+type CatalogResponse struct {
+	Events []CatalogEvent `json:"events"`
+}
+
+// CatalogEvent is an event name the tenant has sent. Times are RFC3339 event
+// timestamps; Volume counts stored events, duplicates excluded.
+type CatalogEvent struct {
+	EventName  string            `json:"event_name"`
+	FirstSeen  string            `json:"first_seen"`
+	LastSeen   string            `json:"last_seen"`
+	Volume     int64             `json:"volume"`
+	Properties []CatalogProperty `json:"properties"`
+}
+
+// CatalogProperty is a top-level property key seen with one JSON type
+// (string, number, boolean, object, array or null); a key sent with several
+// types is listed once per type. Samples holds up to 5 distinct scalar values.
+type CatalogProperty struct {
+	Key       string            `json:"key"`
+	Type      string            `json:"type"`
+	FirstSeen string            `json:"first_seen"`
+	LastSeen  string            `json:"last_seen"`
+	Volume    int64             `json:"volume"`
+	Samples   []json.RawMessage `json:"samples"`
+}
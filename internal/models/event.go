@@ -2,16 +2,58 @@ package models
 
 // EventIngestRequest is the POST /events payload.
 // event_id is optional; best practice is to pass Idempotency-Key header for retries.
+// user_id / anonymous_id optionally identify the actor for unique-user metrics.
 type EventIngestRequest struct {
-	EventID    string                 `json:"event_id,omitempty"`
-	EventName  string                 `json:"event_name"`
-	Timestamp  string                 `json:"timestamp"`
-	Properties map[string]interface{} `json:"properties,omitempty"`
+	EventID     string                 `json:"event_id,omitempty"`
+	EventName   string                 `json:"event_name"`
+	Timestamp   string                 `json:"timestamp"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+	UserID      string                 `json:"user_id,omitempty"`
+	AnonymousID string                 `json:"anonymous_id,omitempty"`
 }
 
 // EventIngestResponse is returned by POST /events.
 // Duplicate indicates idempotent success (the event already existed).
+// Queued is set in async ingestion mode: the event is durably queued, not yet queryable.
+// SchemaErrors flags an event accepted despite failing its schema (lenient validation).
 type EventIngestResponse struct {
-	EventID   string `json:"event_id"`
-	Duplicate bool   `json:"duplicate"`
+	EventID      string        `json:"event_id"`
+	Duplicate    bool          `json:"duplicate"`
+	Queued       bool          `json:"queued,omitempty"`
+	SchemaErrors []SchemaError `json:"schema_errors,omitempty"`
+}
+
+// EventBatchRequest is the POST /events/batch payload.
+// Each item follows the POST /events contract; event_id is the per-item idempotency key.
+type EventBatchRequest struct {
+	Events []EventIngestRequest `json:"events"`
+}
+
+// Batch item statuses reported in EventBatchItemResult.Status.
+const (
+	BatchStatusInserted  = "inserted"
+	BatchStatusQueued    = "queued"
+	BatchStatusDuplicate = "duplicate"
+	BatchStatusRejected  = "rejected"
+)
+
+// EventBatchItemResult reports the outcome for one item of a batch, in request order.
+// Error is only set for rejected items. SchemaErrors lists the schema violations
+// of items rejected by strict validation or flagged by lenient validation.
+type EventBatchItemResult struct {
+	Index        int           `json:"index"`
+	EventID      string        `json:"event_id,omitempty"`
+	Status       string        `json:"status"`
+	Error        string        `json:"error,omitempty"`
+	SchemaErrors []SchemaError `json:"schema_errors,omitempty"`
+}
+
+// EventBatchResponse is returned by POST /events/batch.
+// Queued replaces Inserted in async ingestion mode.
+type EventBatchResponse struct {
+	Inserted   int                    `json:"inserted"`
+	Queued     int                    `json:"queued,omitempty"`
+	Duplicates int                    `json:"duplicates"`
+	Rejected   int                    `json:"rejected"`
+	Results    []EventBatchItemResult `json:"results"`
 }
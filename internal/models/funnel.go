@@ -0,0 +1,28 @@
+package models
+
+// FunnelRequest is the POST /funnels payload.
+// Window is the conversion window measured from each actor's first step,
+// e.g. "30m", "24h" or "7d"; it defaults to 7 days.
+type FunnelRequest struct {
+	Steps  []string `json:"steps"`
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Window string   `json:"window,omitempty"`
+}
+
+// FunnelStep reports how many actors reached one step.
+// ConversionRate is relative to the first step, StepConversionRate to the previous one.
+type FunnelStep struct {
+	EventName          string  `json:"event_name"`
+	Actors             int64   `json:"actors"`
+	ConversionRate     float64 `json:"conversion_rate"`
+	StepConversionRate float64 `json:"step_conversion_rate"`
+}
+
+// FunnelResponse is returned by POST /funnels.
+type FunnelResponse struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Window string       `json:"window"`
+	Steps  []FunnelStep `json:"steps"`
+}
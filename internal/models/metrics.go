@@ -0,0 +1,24 @@
+package models
+
+// MetricsBucket is one point of the GET /metrics?interval=... series.
+// BucketStart is RFC3339 in the requested time zone.
+type MetricsBucket struct {
+	BucketStart string `json:"bucket_start"`
+	Count       int64  `json:"count"`
+}
+
+// MetricsGroup is one group of the GET /metrics?group_by=... breakdown.
+// Values is keyed by the group_by expression; null means the property was absent.
+type MetricsGroup struct {
+	Values map[string]*string `json:"values"`
+	Count  int64              `json:"count"`
+}
+
+// LiveCount is one "count" event of the GET /metrics/stream SSE stream: events
+// ingested during the interval ending At (RFC3339).
+type LiveCount struct {
+	EventName string  `json:"event_name"`
+	Count     int64   `json:"count"`
+	PerSecond float64 `json:"per_second"`
+	At        string  `json:"at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// PrivacyRequest is the POST /admin/privacy/erase and /admin/privacy/export payload.
+// TenantID may be omitted with a tenant admin key.
+type PrivacyRequest struct {
+	TenantID string `json:"tenant_id"`
+	UserID   string `json:"user_id"`
+}
+
+// PrivacyJob describes an erasure or export job.
+// Events is the number of events erased or exported once the job succeeded.
+type PrivacyJob struct {
+	ID          string  `json:"id"`
+	Kind        string  `json:"kind"`
+	TenantID    string  `json:"tenant_id"`
+	UserID      string  `json:"user_id"`
+	Status      string  `json:"status"`
+	RequestedBy string  `json:"requested_by"`
+	Events      int64   `json:"events"`
+	Error       string  `json:"error,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+	StartedAt   *string `json:"started_at"`
+	FinishedAt  *string `json:"finished_at"`
+	ExpiresAt   *string `json:"expires_at,omitempty"`
+	ExportURL   string  `json:"export_url,omitempty"`
+}
+
+// PrivacyAuditEntry is one entry of GET /admin/privacy/audit.
+type PrivacyAuditEntry struct {
+	JobID    string `json:"job_id"`
+	TenantID string `json:"tenant_id"`
+	UserID   string `json:"user_id"`
+	Action   string `json:"action"`
+	Actor    string `json:"actor"`
+	Detail   string `json:"detail,omitempty"`
+	At       string `json:"at"`
+}
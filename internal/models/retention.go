@@ -0,0 +1,23 @@
+package models
+
+// RetentionCohort is one row of the GET /retention matrix.
+//
+// Retained[k] and Rates[k] describe the k-th period after the cohort period
+// (k=0 is the cohort period itself). Both are null for periods that have not
+// started yet, so partially elapsed cohorts are not mistaken for churn.
+type RetentionCohort struct {
+	CohortStart string     `json:"cohort_start"`
+	Size        int64      `json:"size"`
+	Retained    []*int64   `json:"retained"`
+	Rates       []*float64 `json:"rates"`
+}
+
+// RetentionResponse is returned by GET /retention.
+type RetentionResponse struct {
+	StartEvent  string            `json:"start_event"`
+	ReturnEvent string            `json:"return_event"`
+	Granularity string            `json:"granularity"`
+	TZ          string            `json:"tz"`
+	Periods     int               `json:"periods"`
+	Cohorts     []RetentionCohort `json:"cohorts"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "encoding/json"
+
+// SchemaError is one field-level schema violation, e.g.
+// {"field":"properties.plan","message":"must be one of [\"free\",\"pro\"]"}.
+type SchemaError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// EventSchemaRequest is the PUT /admin/schemas/:event_name payload.
+// TenantID may be omitted with a tenant admin key.
+type EventSchemaRequest struct {
+	TenantID string          `json:"tenant_id"`
+	Schema   json.RawMessage `json:"schema"`
+}
+
+// EventSchema is the JSON Schema registered for the properties of an event name.
+// Version starts at 1 and is bumped by every replacement.
+type EventSchema struct {
+	TenantID  string          `json:"tenant_id"`
+	EventName string          `json:"event_name"`
+	Schema    json.RawMessage `json:"schema"`
+	Version   int             `json:"version"`
+	CreatedAt string          `json:"created_at"`
+	UpdatedAt string          `json:"updated_at"`
+}
@@ -0,0 +1,88 @@
+// Package partition keeps the range partitions of the events table in shape:
+// it creates upcoming partitions ahead of time and expires old ones.
+package partition
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// pruneBatchSize is how many event_ids rows are deleted per statement.
+const pruneBatchSize = 10000
+
+// Options tunes the maintainer.
+type Options struct {
+	Interval      store.PartitionInterval // partition width (day or month)
+	Ahead         int                     // future partitions kept ready beyond the current one
+	Retention     time.Duration           // expire partitions older than this; 0 keeps everything
+	Detach        bool                    // detach expired partitions instead of dropping them
+	CheckInterval time.Duration           // time between maintenance passes
+}
+
+// Maintainer runs partition maintenance passes.
+type Maintainer struct {
+	st   *store.PostgresStore
+	opts Options
+	now  func() time.Time
+}
+
+// New creates a maintainer over the given store.
+func New(st *store.PostgresStore, opts Options) *Maintainer {
+	return &Maintainer{st: st, opts: opts, now: time.Now}
+}
+
+// Run performs a pass immediately and then every CheckInterval until ctx is
+// cancelled. Passes hold an advisory lock, so running a maintainer on every
+// replica is safe.
+func (m *Maintainer) Run(ctx context.Context) {
+	for {
+		if err := m.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("partition: maintenance failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.opts.CheckInterval):
+		}
+	}
+}
+
+// RunOnce creates missing partitions and, when retention is set, expires old ones.
+func (m *Maintainer) RunOnce(ctx context.Context) error {
+	now := m.now()
+
+	created, err := m.st.EnsurePartitions(ctx, m.opts.Interval, now, m.opts.Ahead)
+	if err != nil {
+		return err
+	}
+	for _, name := range created {
+		slog.Info("partition: created", "partition", name)
+	}
+
+	if m.opts.Retention <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-m.opts.Retention)
+
+	expired, err := m.st.ExpirePartitions(ctx, cutoff, m.opts.Detach)
+	if err != nil {
+		return err
+	}
+	for _, name := range expired {
+		if m.opts.Detach {
+			slog.Info("partition: detached", "partition", name)
+		} else {
+			slog.Info("partition: dropped", "partition", name)
+		}
+	}
+
+	pruned, err := m.st.PruneEventIDs(ctx, cutoff, pruneBatchSize)
+	if pruned > 0 {
+		slog.Info("partition: pruned expired event ids", "event_ids", pruned)
+	}
+	return err
+}
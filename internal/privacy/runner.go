@@ -0,0 +1,95 @@
+// Package privacy processes data subject requests (GDPR erasure and export)
+// queued in privacy_jobs by the admin API.
+package privacy
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// runnerActor is the audit trail actor of job state changes made by the runner.
+const runnerActor = "privacy-runner"
+
+// Options tunes the runner.
+type Options struct {
+	BatchSize    int           // events deleted or read per statement
+	PollInterval time.Duration // idle wait when no job is pending
+	ExportTTL    time.Duration // how long export output can be downloaded
+	StaleAfter   time.Duration // a running job without heartbeat for this long is taken over
+}
+
+// Runner claims and executes privacy jobs.
+type Runner struct {
+	st   *store.PostgresStore
+	opts Options
+	now  func() time.Time
+}
+
+// New creates a runner over the given store.
+func New(st *store.PostgresStore, opts Options) *Runner {
+	return &Runner{st: st, opts: opts, now: time.Now}
+}
+
+// Run processes jobs until ctx is cancelled. Jobs are claimed with SKIP LOCKED,
+// so every replica can run a runner.
+func (r *Runner) Run(ctx context.Context) {
+	for {
+		worked, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("privacy: pass failed", "err", err)
+		}
+
+		if worked {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.opts.PollInterval):
+		}
+	}
+}
+
+// RunOnce expires old export output, then claims and executes one job. It
+// reports whether a job was run. A job's own failure is recorded on the job;
+// only a failure to claim or record it is returned.
+func (r *Runner) RunOnce(ctx context.Context) (bool, error) {
+	if n, err := r.st.ExpirePrivacyExports(ctx, r.now()); err != nil {
+		return false, err
+	} else if n > 0 {
+		slog.Info("privacy: expired exports", "exports", n)
+	}
+
+	j, ok, err := r.st.ClaimPrivacyJob(ctx, r.opts.StaleAfter, runnerActor)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	var (
+		n         int64
+		jobErr    error
+		expiresAt *time.Time
+	)
+	switch j.Kind {
+	case store.PrivacyErase:
+		n, jobErr = r.st.EraseUserEvents(ctx, j.ID, j.TenantID, j.UserID, r.opts.BatchSize)
+	case store.PrivacyExport:
+		n, jobErr = r.st.ExportUserEvents(ctx, j.ID, j.TenantID, j.UserID, r.opts.BatchSize)
+		at := r.now().Add(r.opts.ExportTTL)
+		expiresAt = &at
+	}
+	if ctx.Err() != nil {
+		// Shutting down: leave the job running so it is taken over once stale.
+		return true, ctx.Err()
+	}
+
+	if jobErr != nil {
+		slog.Error("privacy: job failed", "kind", j.Kind, "job_id", j.ID, "tenant_id", j.TenantID, "err", jobErr)
+	} else {
+		slog.Info("privacy: job done", "kind", j.Kind, "job_id", j.ID, "tenant_id", j.TenantID, "events", n)
+	}
+	return true, r.st.FinishPrivacyJob(ctx, j, n, jobErr, expiresAt, runnerActor)
+}
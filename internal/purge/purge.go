@@ -0,0 +1,232 @@
+// Package purge enforces per-tenant data retention: it deletes events older
+// than each tenant's retention period, dropping whole partitions where it can.
+package purge
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// Options tunes the purge job.
+type Options struct {
+	Default   time.Duration            // retention of tenants without a policy; 0 keeps events forever
+	Tenants   map[string]time.Duration // tenantID -> retention (replaces Default); 0 keeps events forever
+	BatchSize int                      // events deleted per statement
+	DryRun    bool                     // scheduled runs only report what they would purge
+	Interval  time.Duration            // time between scheduled runs
+}
+
+// PartitionReport is a partition dropped (or droppable, in a dry run) as a whole.
+type PartitionReport struct {
+	Name   string    `json:"name"`
+	End    time.Time `json:"range_end"`
+	Events int64     `json:"events"`
+}
+
+// TenantReport is what was deleted row by row for one tenant.
+type TenantReport struct {
+	TenantID string    `json:"tenant_id"`
+	Cutoff   time.Time `json:"cutoff"`
+	Events   int64     `json:"events"`
+}
+
+// Report describes one purge run. In a dry run nothing is deleted and the
+// counts are what would have been.
+type Report struct {
+	StartedAt  time.Time         `json:"started_at"`
+	Duration   string            `json:"duration"`
+	DryRun     bool              `json:"dry_run"`
+	Events     int64             `json:"events"`
+	Partitions []PartitionReport `json:"partitions"`
+	Tenants    []TenantReport    `json:"tenants"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Stats are cumulative counters of the scheduled and on-demand runs of this
+// process, plus the last report.
+type Stats struct {
+	Runs              int64   `json:"runs"`
+	Failures          int64   `json:"failures"`
+	EventsPurged      int64   `json:"events_purged"`
+	PartitionsDropped int64   `json:"partitions_dropped"`
+	LastRun           *Report `json:"last_run,omitempty"`
+}
+
+// Purger runs retention passes.
+type Purger struct {
+	st   *store.PostgresStore
+	opts Options
+	now  func() time.Time
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New creates a purger over the given store.
+func New(st *store.PostgresStore, opts Options) *Purger {
+	return &Purger{st: st, opts: opts, now: time.Now}
+}
+
+// Retention returns the retention period of a tenant; 0 means keep forever.
+func (p *Purger) Retention(tenantID string) time.Duration {
+	if r, ok := p.opts.Tenants[tenantID]; ok {
+		return r
+	}
+	return p.opts.Default
+}
+
+// Policies returns the default retention and the per-tenant overrides.
+func (p *Purger) Policies() (time.Duration, map[string]time.Duration) {
+	tenants := make(map[string]time.Duration, len(p.opts.Tenants))
+	for t, r := range p.opts.Tenants {
+		tenants[t] = r
+	}
+	return p.opts.Default, tenants
+}
+
+// DryRun reports whether scheduled runs only report what they would purge.
+func (p *Purger) DryRun() bool { return p.opts.DryRun }
+
+// cutoffs maps each tenant with a retention period to the time before which its
+// events expire.
+func (p *Purger) cutoffs(tenants []string, now time.Time) map[string]time.Time {
+	out := map[string]time.Time{}
+	for _, t := range tenants {
+		if r := p.Retention(t); r > 0 {
+			out[t] = now.Add(-r)
+		}
+	}
+	return out
+}
+
+// Run performs a pass immediately and then every Interval until ctx is
+// cancelled. With Options.DryRun the passes only report.
+func (p *Purger) Run(ctx context.Context) {
+	for {
+		if _, err := p.RunOnce(ctx, p.opts.DryRun); err != nil && ctx.Err() == nil {
+			slog.Error("purge: run failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.opts.Interval):
+		}
+	}
+}
+
+// Preview reports what RunOnce would purge now, without deleting anything or
+// recording the run in Stats.
+func (p *Purger) Preview(ctx context.Context) (Report, error) {
+	return p.runReport(ctx, true)
+}
+
+// RunOnce purges expired events of every tenant and returns what it removed.
+// Fully expired partitions are dropped first; the rest is deleted in batches of
+// BatchSize. With dryRun nothing is deleted and the report holds what would be.
+//
+// The report is returned, and recorded in Stats, even when the run fails part
+// way, so it accounts for what was removed before the failure.
+func (p *Purger) RunOnce(ctx context.Context, dryRun bool) (Report, error) {
+	rep, err := p.runReport(ctx, dryRun)
+
+	p.mu.Lock()
+	p.stats.Runs++
+	if err != nil {
+		p.stats.Failures++
+	}
+	if !dryRun {
+		p.stats.EventsPurged += rep.Events
+		p.stats.PartitionsDropped += int64(len(rep.Partitions))
+	}
+	last := rep
+	p.stats.LastRun = &last
+	p.mu.Unlock()
+
+	return rep, err
+}
+
+// runReport performs one pass and returns its report.
+func (p *Purger) runReport(ctx context.Context, dryRun bool) (Report, error) {
+	now := p.now()
+	rep := Report{StartedAt: now.UTC(), DryRun: dryRun, Partitions: []PartitionReport{}, Tenants: []TenantReport{}}
+
+	err := p.run(ctx, now, dryRun, &rep)
+	rep.Duration = p.now().Sub(now).Round(time.Millisecond).String()
+	if err != nil {
+		rep.Error = err.Error()
+	}
+	return rep, err
+}
+
+func (p *Purger) run(ctx context.Context, now time.Time, dryRun bool, rep *Report) error {
+	tenants, err := p.st.ListEventTenants(ctx)
+	if err != nil {
+		return err
+	}
+	cutoffs := p.cutoffs(tenants, now)
+	if len(cutoffs) == 0 {
+		return nil
+	}
+
+	parts, err := p.st.ExpiredPartitions(ctx, cutoffs)
+	if err != nil {
+		return err
+	}
+	var dropped []string
+	for _, part := range parts {
+		if !dryRun {
+			ok, err := p.st.DropEventPartition(ctx, part)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			slog.Info("purge: dropped partition", "partition", part.Name, "events", part.Events)
+		}
+		dropped = append(dropped, part.Name)
+		rep.Events += part.Events
+		rep.Partitions = append(rep.Partitions, PartitionReport{Name: part.Name, End: part.End, Events: part.Events})
+	}
+
+	ids := make([]string, 0, len(cutoffs))
+	for t := range cutoffs {
+		ids = append(ids, t)
+	}
+	sort.Strings(ids)
+
+	for _, tenantID := range ids {
+		cutoff := cutoffs[tenantID]
+
+		var n int64
+		if dryRun {
+			n, err = p.st.CountExpiredEvents(ctx, tenantID, cutoff, dropped)
+		} else {
+			n, err = p.st.PurgeExpiredEvents(ctx, tenantID, cutoff, p.opts.BatchSize)
+			if n > 0 {
+				slog.Info("purge: deleted expired events", "tenant_id", tenantID, "events", n, "cutoff", cutoff.UTC())
+			}
+		}
+		if n > 0 {
+			rep.Events += n
+			rep.Tenants = append(rep.Tenants, TenantReport{TenantID: tenantID, Cutoff: cutoff.UTC(), Events: n})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the counters.
+func (p *Purger) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
@@ -0,0 +1,36 @@
+package purge
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCutoffs(t *testing.T) {
+	const day = 24 * time.Hour
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	p := New(nil, Options{
+		Default: 395 * day,
+		Tenants: map[string]time.Duration{
+			"tenant2": 90 * day,
+			"archive": 0, // overrides the default: keep forever
+		},
+	})
+
+	got := p.cutoffs([]string{"tenant1", "tenant2", "archive"}, now)
+	want := map[string]time.Time{
+		"tenant1": now.Add(-395 * day),
+		"tenant2": now.Add(-90 * day),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	// Without a default, only tenants with a policy are purged.
+	p = New(nil, Options{Tenants: map[string]time.Duration{"tenant2": 90 * day}})
+	got = p.cutoffs([]string{"tenant1", "tenant2"}, now)
+	if len(got) != 1 || !got["tenant2"].Equal(now.Add(-90*day)) {
+		t.Fatalf("got %v", got)
+	}
+}
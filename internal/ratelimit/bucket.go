@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// bucket is a token bucket refilled continuously at rate tokens/sec up to burst.
+// It is not safe for concurrent use; Limiter serializes access.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int, now time.Time) *bucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &bucket{rate: rate, burst: b, tokens: b, last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// allow takes n tokens if available. Otherwise it takes nothing and returns how
+// long until they will be.
+//
+// A request larger than burst (e.g. a big batch) is admitted once the bucket is
+// full and drives it negative, so it is paid back by the following requests
+// instead of being rejected forever.
+func (b *bucket) allow(n float64, now time.Time) (bool, time.Duration) {
+	b.refill(now)
+	need := math.Min(n, b.burst)
+	if b.tokens >= need {
+		b.tokens -= n
+		return true, 0
+	}
+	return false, time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// refund gives back n tokens taken by allow, up to burst.
+func (b *bucket) refund(n float64) {
+	b.tokens = math.Min(b.burst, b.tokens+n)
+}
+
+// remaining is the number of whole tokens currently available.
+func (b *bucket) remaining() int64 {
+	return int64(math.Max(0, b.tokens))
+}
+
+// untilFull is how long until the bucket is back at burst.
+func (b *bucket) untilFull() time.Duration {
+	return time.Duration((b.burst - b.tokens) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,293 @@
+// Package ratelimit enforces per-tenant request/event rates and ingestion quotas.
+//
+// Rates are token buckets held in memory, so each replica enforces them on its
+// own share of the traffic. Daily and monthly quotas are counted in Postgres,
+// so they are shared by all replicas and survive restarts.
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/auth"
+	"github.com/PratikDhanave/event-analytics-service/internal/config"
+	"github.com/PratikDhanave/event-analytics-service/internal/logging"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// QuotaStore persists quota usage; implemented by store.PostgresStore.
+type QuotaStore interface {
+	ConsumeQuota(ctx context.Context, tenantID string, n int64, now time.Time, limits []store.QuotaLimit) (store.QuotaResult, error)
+	RefundQuota(ctx context.Context, tenantID string, n int64, now time.Time, limits []store.QuotaLimit) error
+}
+
+// maxBatchBytes caps the POST /events/batch body read to count its events.
+const maxBatchBytes = 8 << 20
+
+// writtenCtxKey holds the number of events an ingestion handler wrote.
+const writtenCtxKey = "events_written"
+
+// EventsWritten records that the ingestion handler serving c wrote (or queued)
+// n new events. Middleware charges the quotas for these only; an ingestion
+// request that never calls it is refunded in full.
+func EventsWritten(c *gin.Context, n int) {
+	c.Set(writtenCtxKey, int64(n))
+}
+
+// tenantBuckets holds the in-memory rate state of one tenant.
+type tenantBuckets struct {
+	requests *bucket
+	events   *bucket
+}
+
+// Limiter tracks rate-limit state for all tenants.
+type Limiter struct {
+	defaults config.TenantLimits
+	tenants  map[string]config.TenantLimits
+	quotas   QuotaStore
+
+	mu      sync.Mutex
+	buckets map[string]*tenantBuckets
+	now     func() time.Time
+}
+
+// New creates a limiter. Tenants without an entry in tenants use defaults.
+func New(defaults config.TenantLimits, tenants map[string]config.TenantLimits, quotas QuotaStore) *Limiter {
+	return &Limiter{
+		defaults: defaults,
+		tenants:  tenants,
+		quotas:   quotas,
+		buckets:  map[string]*tenantBuckets{},
+		now:      time.Now,
+	}
+}
+
+// limitsFor returns the effective limits of a tenant.
+func (l *Limiter) limitsFor(tenantID string) config.TenantLimits {
+	if lim, ok := l.tenants[tenantID]; ok {
+		return lim
+	}
+	return l.defaults
+}
+
+// Middleware enforces the limits of the authenticated tenant.
+// It must run after auth.Middleware (or auth.APIKeyMiddleware).
+//
+// Every request is charged against requests/sec. Ingestion requests (POST /events
+// and POST /events/batch) are also charged, per event, against events/sec and the
+// daily/monthly quotas. Quotas are reserved for every event of the request and the
+// events the handler did not report with EventsWritten (rejected, duplicate or
+// failed) are refunded once it returns. Rejections are 429 with Retry-After;
+// X-RateLimit-* headers describe the limit that applied.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := auth.TenantID(c)
+		if tenantID == "" {
+			c.Next()
+			return
+		}
+		lim := l.limitsFor(tenantID)
+
+		events := int64(0)
+		if c.Request.Method == http.MethodPost {
+			switch c.FullPath() {
+			case "/events":
+				events = 1
+			case "/events/batch":
+				n, ok := countBatchEvents(c)
+				if !ok {
+					c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+						"error": fmt.Sprintf("request body larger than %d bytes", maxBatchBytes),
+					})
+					return
+				}
+				events = n
+			}
+		}
+
+		if !l.allowRate(c, tenantID, lim, events) {
+			return
+		}
+
+		if events == 0 || (lim.DailyEvents == 0 && lim.MonthlyEvents == 0) {
+			c.Next()
+			return
+		}
+
+		limits := quotaLimits(lim)
+		now := l.now()
+		charged, ok := l.allowQuota(c, tenantID, limits, events, now)
+		if !ok {
+			l.refundRate(tenantID, events)
+			return
+		}
+
+		c.Next()
+
+		if !charged {
+			return
+		}
+		written := c.GetInt64(writtenCtxKey)
+		if unused := events - written; unused > 0 {
+			if err := l.quotas.RefundQuota(c.Request.Context(), tenantID, unused, now, limits); err != nil {
+				logging.FromContext(c.Request.Context()).Error("ratelimit: quota refund failed",
+					"tenant_id", tenantID, "events", unused, "err", err)
+			}
+		}
+	}
+}
+
+// allowRate charges the tenant's token buckets and writes a 429 when empty.
+func (l *Limiter) allowRate(c *gin.Context, tenantID string, lim config.TenantLimits, events int64) bool {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tb, ok := l.buckets[tenantID]
+	if !ok {
+		tb = &tenantBuckets{}
+		if lim.RequestsPerSec > 0 {
+			tb.requests = newBucket(lim.RequestsPerSec, lim.RequestBurst, now)
+		}
+		if lim.EventsPerSec > 0 {
+			tb.events = newBucket(lim.EventsPerSec, lim.EventBurst, now)
+		}
+		l.buckets[tenantID] = tb
+	}
+
+	if tb.requests != nil {
+		if ok, wait := tb.requests.allow(1, now); !ok {
+			tooManyRequests(c, "request rate limit exceeded", tb.requests.burst, 0, wait, wait)
+			return false
+		}
+	}
+
+	if tb.events != nil && events > 0 {
+		if ok, wait := tb.events.allow(float64(events), now); !ok {
+			// Give the request token back: the request was not served.
+			if tb.requests != nil {
+				tb.requests.refund(1)
+			}
+			tooManyRequests(c, "event rate limit exceeded", tb.events.burst, tb.events.remaining(), wait, wait)
+			return false
+		}
+		setRateHeaders(c, tb.events.burst, tb.events.remaining(), tb.events.untilFull())
+		return true
+	}
+
+	if tb.requests != nil {
+		setRateHeaders(c, tb.requests.burst, tb.requests.remaining(), tb.requests.untilFull())
+	}
+	return true
+}
+
+// refundRate gives back the tokens allowRate took for a request that was then
+// rejected by its quota.
+func (l *Limiter) refundRate(tenantID string, events int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tb, ok := l.buckets[tenantID]
+	if !ok {
+		return
+	}
+	if tb.requests != nil {
+		tb.requests.refund(1)
+	}
+	if tb.events != nil && events > 0 {
+		tb.events.refund(float64(events))
+	}
+}
+
+// quotaLimits returns the persistent quotas configured in lim.
+func quotaLimits(lim config.TenantLimits) []store.QuotaLimit {
+	var limits []store.QuotaLimit
+	if lim.DailyEvents > 0 {
+		limits = append(limits, store.QuotaLimit{Period: store.QuotaDaily, Limit: lim.DailyEvents})
+	}
+	if lim.MonthlyEvents > 0 {
+		limits = append(limits, store.QuotaLimit{Period: store.QuotaMonthly, Limit: lim.MonthlyEvents})
+	}
+	return limits
+}
+
+// allowQuota charges the tenant's persistent quotas and writes a 429 when exhausted.
+// charged reports whether the events were counted and must be refunded if unused.
+// If the quota store is unavailable the request is let through uncharged: ingestion
+// will surface the database error itself, and quotas should not add an outage of
+// their own.
+func (l *Limiter) allowQuota(c *gin.Context, tenantID string, limits []store.QuotaLimit, events int64, now time.Time) (charged, ok bool) {
+	res, err := l.quotas.ConsumeQuota(c.Request.Context(), tenantID, events, now, limits)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("ratelimit: quota check failed", "tenant_id", tenantID, "err", err)
+		return false, true
+	}
+	if res.Allowed {
+		return true, true
+	}
+
+	remaining := res.Exceeded.Limit - res.Used
+	if remaining < 0 {
+		remaining = 0
+	}
+	reset := res.ResetAt.Sub(now)
+	msg := "daily event quota exceeded"
+	if res.Exceeded.Period == store.QuotaMonthly {
+		msg = "monthly event quota exceeded"
+	}
+	tooManyRequests(c, msg, float64(res.Exceeded.Limit), remaining, reset, reset)
+	return false, false
+}
+
+// countBatchEvents counts the items of a POST /events/batch body without
+// consuming it. Malformed bodies count as one event; the handler rejects them.
+// ok is false when the body is larger than maxBatchBytes.
+func countBatchEvents(c *gin.Context) (n int64, ok bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return 0, false
+		}
+		return 1, true
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var batch struct {
+		Events []json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil || len(batch.Events) == 0 {
+		return 1, true
+	}
+	return int64(len(batch.Events)), true
+}
+
+func setRateHeaders(c *gin.Context, limit float64, remaining int64, reset time.Duration) {
+	c.Header("X-RateLimit-Limit", strconv.FormatInt(int64(limit), 10))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(reset.Seconds())), 10))
+}
+
+func tooManyRequests(c *gin.Context, msg string, limit float64, remaining int64, reset, retryAfter time.Duration) {
+	setRateHeaders(c, limit, remaining, reset)
+
+	// Whole seconds, at least 1, so clients never retry immediately.
+	retry := int64(math.Ceil(retryAfter.Seconds()))
+	if retry < 1 {
+		retry = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retry, 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": msg})
+}
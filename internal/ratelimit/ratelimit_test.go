@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/config"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// memQuotas is an in-memory QuotaStore keyed by period.
+type memQuotas struct {
+	used map[store.QuotaPeriod]int64
+}
+
+func (m *memQuotas) ConsumeQuota(_ context.Context, _ string, n int64, now time.Time, limits []store.QuotaLimit) (store.QuotaResult, error) {
+	for _, l := range limits {
+		if m.used[l.Period]+n > l.Limit {
+			return store.QuotaResult{Exceeded: l, Used: m.used[l.Period], ResetAt: now.Add(time.Hour)}, nil
+		}
+	}
+	for _, l := range limits {
+		m.used[l.Period] += n
+	}
+	return store.QuotaResult{Allowed: true}, nil
+}
+
+func (m *memQuotas) RefundQuota(_ context.Context, _ string, n int64, _ time.Time, limits []store.QuotaLimit) error {
+	for _, l := range limits {
+		m.used[l.Period] = max(0, m.used[l.Period]-n)
+	}
+	return nil
+}
+
+// newTestRouter serves ingestion routes that write every event except the
+// batch items marked {"dup":true}.
+func newTestRouter(l *Limiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("tenant_id", "t1"); c.Next() }, l.Middleware())
+	r.POST("/events", func(c *gin.Context) {
+		EventsWritten(c, 1)
+		c.Status(http.StatusCreated)
+	})
+	r.POST("/events/batch", func(c *gin.Context) {
+		var batch struct {
+			Events []struct{ Dup bool } `json:"events"`
+		}
+		_ = c.ShouldBindJSON(&batch)
+		written := 0
+		for _, e := range batch.Events {
+			if !e.Dup {
+				written++
+			}
+		}
+		EventsWritten(c, written)
+		c.Status(http.StatusOK)
+	})
+	r.GET("/metrics", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func do(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestMiddleware_RequestRate(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := New(config.TenantLimits{RequestsPerSec: 2}, nil, &memQuotas{})
+	l.now = func() time.Time { return now }
+	r := newTestRouter(l)
+
+	for i := 0; i < 2; i++ {
+		if w := do(r, http.MethodGet, "/metrics", ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200 got %d", i, w.Code)
+		}
+	}
+
+	w := do(r, http.MethodGet, "/metrics", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if w := do(r, http.MethodGet, "/metrics", ""); w.Code != http.StatusOK {
+		t.Fatalf("after refill: expected 200 got %d", w.Code)
+	}
+}
+
+func TestMiddleware_EventRateCountsBatchItems(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := New(config.TenantLimits{EventsPerSec: 10}, nil, &memQuotas{})
+	l.now = func() time.Time { return now }
+	r := newTestRouter(l)
+
+	batch := `{"events":[{},{},{},{},{},{},{},{}]}`
+	if w := do(r, http.MethodPost, "/events/batch", batch); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+	if w := do(r, http.MethodPost, "/events/batch", batch); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 got %d", w.Code)
+	}
+	// Two tokens are left for single events.
+	if w := do(r, http.MethodPost, "/events", "{}"); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", w.Code)
+	}
+}
+
+func TestMiddleware_DailyQuotaPerTenant(t *testing.T) {
+	quotas := &memQuotas{used: map[store.QuotaPeriod]int64{}}
+	l := New(config.TenantLimits{}, map[string]config.TenantLimits{"t1": {DailyEvents: 3}}, quotas)
+	r := newTestRouter(l)
+
+	if w := do(r, http.MethodPost, "/events/batch", `{"events":[{},{},{}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+
+	w := do(r, http.MethodPost, "/events", "{}")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 got %d", w.Code)
+	}
+	if w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("Retry-After") != "3600" {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+
+	// Reads are not charged against the ingestion quota.
+	if w := do(r, http.MethodGet, "/metrics", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+}
+
+func TestMiddleware_QuotaChargesWrittenEventsOnly(t *testing.T) {
+	quotas := &memQuotas{used: map[store.QuotaPeriod]int64{}}
+	l := New(config.TenantLimits{}, map[string]config.TenantLimits{"t1": {DailyEvents: 3}}, quotas)
+	r := newTestRouter(l)
+
+	if w := do(r, http.MethodPost, "/events/batch", `{"events":[{},{"dup":true},{"dup":true}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+	if got := quotas.used[store.QuotaDaily]; got != 1 {
+		t.Fatalf("duplicates charged: used = %d, want 1", got)
+	}
+
+	// A batch that writes nothing is refunded in full.
+	if w := do(r, http.MethodPost, "/events/batch", `{"events":[{"dup":true}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+	for i := 0; i < 2; i++ {
+		if w := do(r, http.MethodPost, "/events", "{}"); w.Code != http.StatusCreated {
+			t.Fatalf("event %d: expected 201 got %d", i, w.Code)
+		}
+	}
+	if got := quotas.used[store.QuotaDaily]; got != 3 {
+		t.Fatalf("used = %d, want 3", got)
+	}
+}
+
+func TestMiddleware_QuotaRejectionRefundsRate(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	quotas := &memQuotas{used: map[store.QuotaPeriod]int64{}}
+	l := New(config.TenantLimits{}, map[string]config.TenantLimits{
+		"t1": {RequestsPerSec: 1, EventsPerSec: 5, DailyEvents: 2},
+	}, quotas)
+	l.now = func() time.Time { return now }
+	r := newTestRouter(l)
+
+	if w := do(r, http.MethodPost, "/events/batch", `{"events":[{},{},{}]}`); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 got %d", w.Code)
+	}
+	// The rejected batch took neither the request token nor the event tokens.
+	if w := do(r, http.MethodPost, "/events/batch", `{"events":[{},{}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body)
+	}
+	if got := l.buckets["t1"].events.remaining(); got != 3 {
+		t.Fatalf("event tokens = %d, want 3", got)
+	}
+}
+
+func TestMiddleware_BatchBodyTooLarge(t *testing.T) {
+	l := New(config.TenantLimits{EventsPerSec: 10}, nil, &memQuotas{})
+	r := newTestRouter(l)
+
+	body := `{"events":[{}],"pad":"` + strings.Repeat("x", maxBatchBytes) + `"}`
+	if w := do(r, http.MethodPost, "/events/batch", body); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 got %d", w.Code)
+	}
+}
@@ -0,0 +1,64 @@
+// Package rollup keeps the hourly event count rollups up to date by folding in
+// the deltas of newly written and deleted events in the background.
+package rollup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// maxBatch bounds the deltas one compaction transaction folds, so a backlog
+// (e.g. after an ingestion burst) is worked off in short transactions.
+const maxBatch = 10000
+
+// Options tunes the compactor.
+type Options struct {
+	Interval time.Duration // wait between passes once caught up
+}
+
+// Compactor folds new events into the rollups.
+type Compactor struct {
+	st   *store.PostgresStore
+	opts Options
+}
+
+// New creates a compactor over the given store.
+func New(st *store.PostgresStore, opts Options) *Compactor {
+	return &Compactor{st: st, opts: opts}
+}
+
+// Run compacts until ctx is cancelled. Steps are taken back to back while
+// behind (e.g. after an ingestion burst), then every Interval. Passes hold an
+// advisory lock, so every replica can run a compactor.
+func (c *Compactor) Run(ctx context.Context) {
+	for {
+		caughtUp, err := c.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("rollup: compaction failed", "err", err)
+		}
+
+		if !caughtUp && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.opts.Interval):
+		}
+	}
+}
+
+// RunOnce performs one compaction step and reports whether the rollups caught up.
+func (c *Compactor) RunOnce(ctx context.Context) (bool, error) {
+	rows, caughtUp, err := c.st.CompactRollups(ctx, maxBatch)
+	if err != nil {
+		return false, err
+	}
+	if !caughtUp {
+		slog.Info("rollup: compacting backlog", "rows", rows)
+	}
+	return caughtUp, nil
+}
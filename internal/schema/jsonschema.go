@@ -0,0 +1,152 @@
+// Package schema validates event properties against the JSON Schemas tenants
+// register per event name (see Registry).
+//
+// Schemas are compiled and enforced by github.com/santhosh-tekuri/jsonschema
+// (drafts 4 to 2020-12, draft 2020-12 when $schema is absent). References may
+// only point inside the schema itself: nothing is loaded from files or over
+// the network. format is an annotation and is not asserted.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// maxErrors bounds the field errors reported for one payload.
+const maxErrors = 20
+
+// schemaURL identifies the schema being compiled; relative references resolve
+// against it, and fail, as nothing else is loaded.
+const schemaURL = "https://schemas.event-analytics-service.local/event.json"
+
+// FieldError is one violation, located by a path such as
+// "properties.items[0].sku".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	s *jsonschema.Schema
+}
+
+// Compile parses and checks a JSON Schema document against its meta-schema.
+func Compile(raw []byte) (*Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	c.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("only references within the schema are allowed, not %s", url)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %v", err)
+	}
+	if err := c.AddResource(schemaURL, bytes.NewReader(raw)); err != nil {
+		return nil, compileError(err)
+	}
+	s, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, compileError(err)
+	}
+	return &Schema{s: s}, nil
+}
+
+// compileError describes why a schema was refused, without the internal URL.
+func compileError(err error) error {
+	var se *jsonschema.SchemaError
+	if errors.As(err, &se) {
+		var ve *jsonschema.ValidationError
+		if errors.As(se.Err, &ve) {
+			var msgs []string
+			for _, l := range leaves(ve, nil) {
+				msgs = append(msgs, pointer(l.InstanceLocation)+": "+l.Message)
+			}
+			return fmt.Errorf("schema does not match its meta-schema: %s", strings.Join(msgs, "; "))
+		}
+		err = se.Err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), schemaURL, ""))
+}
+
+// pointer renders a JSON pointer of the schema document, "#" for its root.
+func pointer(p string) string {
+	return "#" + p
+}
+
+// Validate checks a decoded JSON value (as produced by encoding/json) and
+// returns up to maxErrors violations located relative to path, in field order.
+func (s *Schema) Validate(v interface{}, path string) []FieldError {
+	err := s.s.Validate(v)
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		if err != nil {
+			return []FieldError{{Field: path, Message: err.Error()}}
+		}
+		return nil
+	}
+
+	seen := map[FieldError]bool{}
+	var errs []FieldError
+	for _, l := range leaves(ve, nil) {
+		e := FieldError{Field: field(path, v, l.InstanceLocation), Message: l.Message}
+		if !seen[e] {
+			seen[e] = true
+			errs = append(errs, e)
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	if len(errs) > maxErrors {
+		errs = errs[:maxErrors]
+	}
+	return errs
+}
+
+// leaves collects the violations of ve that explain it. The branches of anyOf
+// and oneOf are not descended into: each fails in its own way, and the useful
+// report is that none (or several) matched.
+func leaves(ve *jsonschema.ValidationError, out []*jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(ve.Causes) == 0 ||
+		strings.HasSuffix(ve.KeywordLocation, "/anyOf") || strings.HasSuffix(ve.KeywordLocation, "/oneOf") {
+		return append(out, ve)
+	}
+	for _, c := range ve.Causes {
+		out = leaves(c, out)
+	}
+	return out
+}
+
+// field turns the JSON pointer loc into v into a path below path, such as
+// "properties.items[0].sku": array elements are indexed, object members dotted.
+func field(path string, v interface{}, loc string) string {
+	if loc == "" {
+		return path
+	}
+	var b strings.Builder
+	b.WriteString(path)
+	for _, tok := range strings.Split(loc[1:], "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		switch x := v.(type) {
+		case []interface{}:
+			i, _ := strconv.Atoi(tok)
+			b.WriteString("[" + tok + "]")
+			if i >= 0 && i < len(x) {
+				v = x[i]
+			}
+		case map[string]interface{}:
+			b.WriteString("." + tok)
+			v = x[tok]
+		default:
+			b.WriteString("." + tok)
+		}
+	}
+	return b.String()
+}
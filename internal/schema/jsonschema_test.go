@@ -0,0 +1,152 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+const checkoutSchema = `{
+	"type": "object",
+	"required": ["plan", "amount"],
+	"additionalProperties": false,
+	"properties": {
+		"plan":   {"enum": ["free", "pro"]},
+		"amount": {"type": "number", "minimum": 0},
+		"seats":  {"type": "integer", "maximum": 100},
+		"coupon": {"type": "string", "pattern": "^[A-Z0-9]+$", "maxLength": 8},
+		"items":  {"type": "array", "maxItems": 2, "items": {
+			"type": "object", "required": ["sku"], "properties": {"sku": {"type": "string", "minLength": 1}}
+		}}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(checkoutSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		props string
+		want  []FieldError
+	}{
+		{"valid", `{"plan":"pro","amount":9.5,"seats":3,"coupon":"SAVE10","items":[{"sku":"a"}]}`, nil},
+		{"missing required", `{"plan":"pro"}`, []FieldError{
+			{"properties", "missing properties: 'amount'"},
+		}},
+		{"wrong types", `{"plan":"pro","amount":"9.5","seats":1.5}`, []FieldError{
+			{"properties.amount", "expected number, but got string"},
+			{"properties.seats", "expected integer, but got number"},
+		}},
+		{"bounds", `{"plan":"gold","amount":-1,"seats":101,"coupon":"lower-case"}`, []FieldError{
+			{"properties.amount", "must be >= 0 but found -1"},
+			{"properties.coupon", "length must be <= 8, but got 10"},
+			{"properties.coupon", "does not match pattern '^[A-Z0-9]+$'"},
+			{"properties.plan", `value must be one of "free", "pro"`},
+			{"properties.seats", "must be <= 100 but found 101"},
+		}},
+		{"unknown property", `{"plan":"free","amount":0,"sign_up":true}`, []FieldError{
+			{"properties", "additionalProperties 'sign_up' not allowed"},
+		}},
+		{"nested items", `{"plan":"free","amount":0,"items":[{"sku":""},{},{"sku":"c"}]}`, []FieldError{
+			{"properties.items", "maximum 2 items required, but found 3 items"},
+			{"properties.items[0].sku", "length must be >= 1, but got 0"},
+			{"properties.items[1]", "missing properties: 'sku'"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Validate(decode(t, tt.props), "properties")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_Combinators(t *testing.T) {
+	s, err := Compile([]byte(`{
+		"properties": {
+			"id":   {"anyOf": [{"type": "string"}, {"type": "integer"}]},
+			"kind": {"oneOf": [{"const": "a"}, {"type": "string", "maxLength": 1}]},
+			"tag":  {"not": {"const": "internal"}},
+			"n":    {"allOf": [{"minimum": 1}, {"multipleOf": 0.5}]}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := s.Validate(decode(t, `{"id":7,"kind":"b","tag":"x","n":1.5}`), "properties"); errs != nil {
+		t.Fatalf("valid payload rejected: %v", errs)
+	}
+	errs := s.Validate(decode(t, `{"id":true,"kind":"a","tag":"internal","n":1.2}`), "properties")
+	want := []FieldError{
+		{"properties.id", "anyOf failed"},
+		{"properties.kind", "valid against schemas at indexes 0 and 1"},
+		{"properties.n", "1.2 not multipleOf 0.5"},
+		{"properties.tag", "not failed"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Fatalf("Validate() = %v, want %v", errs, want)
+	}
+}
+
+func TestValidate_CapsErrors(t *testing.T) {
+	s, err := Compile([]byte(`{"additionalProperties": {"type": "string"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := map[string]interface{}{}
+	for i := 0; i < 50; i++ {
+		props[strings.Repeat("k", i+1)] = i
+	}
+	if got := len(s.Validate(props, "properties")); got != maxErrors {
+		t.Fatalf("%d errors, want %d", got, maxErrors)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		schema, want string
+	}{
+		{`{`, "not valid JSON"},
+		{`[]`, "#: expected object or boolean, but got array"},
+		{`{"type":"float"}`, "#/type: anyOf failed"},
+		{`{"properties":{"a":{"minLength":-1}}}`, "#/properties/a/minLength: must be >= 0"},
+		{`{"pattern":"("}`, "'(' is not valid 'regex'"},
+		{`{"multipleOf":0}`, "#/multipleOf: must be > 0"},
+		// Nothing is loaded from outside the schema.
+		{`{"$ref":"other.json"}`, "only references within the schema are allowed"},
+		{`{"$ref":"file:///etc/passwd"}`, "only references within the schema are allowed, not file:///etc/passwd"},
+	} {
+		if _, err := Compile([]byte(tt.schema)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%s) error = %v, want %q", tt.schema, err, tt.want)
+		}
+	}
+
+	// Annotations, extensions and references within the schema are accepted.
+	if _, err := Compile([]byte(`{"$schema":"https://json-schema.org/draft/2020-12/schema",
+		"title":"Signup","description":"d","format":"email","x-owner":"growth"}`)); err != nil {
+		t.Fatalf("annotations rejected: %v", err)
+	}
+	s, err := Compile([]byte(`{"$defs":{"plan":{"enum":["free","pro"]}},"properties":{"plan":{"$ref":"#/$defs/plan"}}}`))
+	if err != nil {
+		t.Fatalf("local $ref rejected: %v", err)
+	}
+	if errs := s.Validate(decode(t, `{"plan":"gold"}`), "properties"); len(errs) != 1 || errs[0].Field != "properties.plan" {
+		t.Fatalf("local $ref not enforced: %v", errs)
+	}
+}
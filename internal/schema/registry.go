@@ -0,0 +1,152 @@
+package schema
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/config"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+)
+
+// Store reads registered schemas; implemented by store.PostgresStore.
+type Store interface {
+	ListEventSchemas(ctx context.Context, tenantID string) ([]store.EventSchema, error)
+}
+
+// Options configures a Registry.
+type Options struct {
+	DefaultMode string            // config.SchemaValidation* applied to tenants without their own mode
+	TenantModes map[string]string // tenantID -> mode (replaces DefaultMode)
+	CacheTTL    time.Duration     // how long the compiled schemas of a tenant are reused
+}
+
+// Result is the outcome of checking one event.
+type Result struct {
+	Mode   string
+	Errors []FieldError
+}
+
+// Rejected reports whether the event must be refused (strict mode).
+func (r Result) Rejected() bool {
+	return r.Mode == config.SchemaValidationStrict && len(r.Errors) > 0
+}
+
+// Flagged reports whether the event is accepted despite errors (lenient mode).
+func (r Result) Flagged() bool {
+	return r.Mode == config.SchemaValidationLenient && len(r.Errors) > 0
+}
+
+// tenantSchemas are the compiled schemas of one tenant, by event name.
+type tenantSchemas struct {
+	schemas map[string]*Schema
+	expires time.Time
+}
+
+// Registry checks event properties against the schemas of their tenant.
+//
+// Schemas are loaded per tenant and cached for CacheTTL. Changes made through
+// this process call Invalidate and apply at once; other replicas pick them up
+// when their cache expires.
+type Registry struct {
+	st   Store
+	opts Options
+
+	mu      sync.Mutex
+	tenants map[string]*tenantSchemas
+	now     func() time.Time
+}
+
+// NewRegistry creates a registry reading schemas from st.
+func NewRegistry(st Store, opts Options) *Registry {
+	return &Registry{
+		st:      st,
+		opts:    opts,
+		tenants: map[string]*tenantSchemas{},
+		now:     time.Now,
+	}
+}
+
+// Mode returns the validation mode of a tenant.
+func (r *Registry) Mode(tenantID string) string {
+	if m, ok := r.opts.TenantModes[tenantID]; ok {
+		return m
+	}
+	return r.opts.DefaultMode
+}
+
+// Invalidate drops the cached schemas of a tenant after one was changed.
+func (r *Registry) Invalidate(tenantID string) {
+	r.mu.Lock()
+	delete(r.tenants, tenantID)
+	r.mu.Unlock()
+}
+
+// Check validates the properties of an event. Errors are located under
+// "properties"; an event name without a schema is reported on "event_name" in
+// strict mode, and in lenient mode once the tenant has registered any schema.
+func (r *Registry) Check(ctx context.Context, tenantID, eventName string, properties map[string]interface{}) (Result, error) {
+	res := Result{Mode: r.Mode(tenantID)}
+	if res.Mode == config.SchemaValidationOff {
+		return res, nil
+	}
+
+	schemas, err := r.schemasOf(ctx, tenantID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	s, ok := schemas[eventName]
+	if !ok {
+		if res.Mode == config.SchemaValidationStrict || len(schemas) > 0 {
+			res.Errors = []FieldError{{Field: "event_name", Message: "is not registered"}}
+			telemetry.SchemaViolation(res.Mode, "unregistered")
+		}
+		return res, nil
+	}
+
+	var doc interface{} = properties
+	if properties == nil {
+		doc = map[string]interface{}{} // omitted properties are an empty object
+	}
+	if res.Errors = s.Validate(doc, "properties"); len(res.Errors) > 0 {
+		telemetry.SchemaViolation(res.Mode, "invalid")
+	}
+	return res, nil
+}
+
+// schemasOf returns the compiled schemas of a tenant, loading them when they
+// are not cached.
+func (r *Registry) schemasOf(ctx context.Context, tenantID string) (map[string]*Schema, error) {
+	now := r.now()
+	r.mu.Lock()
+	t, ok := r.tenants[tenantID]
+	r.mu.Unlock()
+	if ok && now.Before(t.expires) {
+		return t.schemas, nil
+	}
+
+	rows, err := r.st.ListEventSchemas(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	t = &tenantSchemas{schemas: make(map[string]*Schema, len(rows)), expires: now.Add(r.opts.CacheTTL)}
+	for _, row := range rows {
+		s, err := Compile(row.Schema)
+		if err != nil {
+			// Schemas are compiled before they are stored, so this only happens
+			// if the table was edited by hand; skip rather than block ingestion.
+			slog.Warn("skipping invalid event schema",
+				"tenant_id", tenantID, "event_name", row.EventName, "err", err)
+			continue
+		}
+		t.schemas[row.EventName] = s
+	}
+
+	r.mu.Lock()
+	r.tenants[tenantID] = t
+	r.mu.Unlock()
+	return t.schemas, nil
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/config"
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// fakeStore serves schemas from memory and counts loads.
+type fakeStore struct {
+	schemas map[string][]store.EventSchema
+	loads   int
+}
+
+func (f *fakeStore) ListEventSchemas(_ context.Context, tenantID string) ([]store.EventSchema, error) {
+	f.loads++
+	return f.schemas[tenantID], nil
+}
+
+func newTestRegistry() (*Registry, *fakeStore) {
+	st := &fakeStore{schemas: map[string][]store.EventSchema{
+		"tenant1": {{TenantID: "tenant1", EventName: "signup",
+			Schema: []byte(`{"required":["plan"],"properties":{"plan":{"type":"string"}}}`)}},
+	}}
+	r := NewRegistry(st, Options{
+		DefaultMode: config.SchemaValidationLenient,
+		TenantModes: map[string]string{"strict": config.SchemaValidationStrict, "off": config.SchemaValidationOff},
+		CacheTTL:    time.Minute,
+	})
+	return r, st
+}
+
+func TestRegistry_Check(t *testing.T) {
+	r, st := newTestRegistry()
+	st.schemas["strict"] = st.schemas["tenant1"]
+	ctx := context.Background()
+
+	tests := []struct {
+		tenant, event      string
+		props              map[string]interface{}
+		rejected, flagged  bool
+		wantField, wantMsg string
+	}{
+		{"tenant1", "signup", map[string]interface{}{"plan": "pro"}, false, false, "", ""},
+		{"tenant1", "signup", nil, false, true, "properties", "missing properties: 'plan'"},
+		{"tenant1", "sign_up", nil, false, true, "event_name", "is not registered"},
+		{"strict", "signup", map[string]interface{}{"plan": 1.0}, true, false, "properties.plan", "expected string, but got number"},
+		{"strict", "sign_up", nil, true, false, "event_name", "is not registered"},
+		// Lenient tenants without any schema are not flagged.
+		{"tenant2", "anything", nil, false, false, "", ""},
+		{"off", "signup", nil, false, false, "", ""},
+	}
+	for _, tt := range tests {
+		res, err := r.Check(ctx, tt.tenant, tt.event, tt.props)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Rejected() != tt.rejected || res.Flagged() != tt.flagged {
+			t.Errorf("%s/%s: rejected=%v flagged=%v, want %v %v",
+				tt.tenant, tt.event, res.Rejected(), res.Flagged(), tt.rejected, tt.flagged)
+		}
+		if tt.wantField == "" {
+			if len(res.Errors) != 0 {
+				t.Errorf("%s/%s: unexpected errors %v", tt.tenant, tt.event, res.Errors)
+			}
+			continue
+		}
+		if len(res.Errors) != 1 || res.Errors[0].Field != tt.wantField || res.Errors[0].Message != tt.wantMsg {
+			t.Errorf("%s/%s: errors = %v, want %s %s", tt.tenant, tt.event, res.Errors, tt.wantField, tt.wantMsg)
+		}
+	}
+}
+
+func TestRegistry_CachesUntilInvalidated(t *testing.T) {
+	r, st := newTestRegistry()
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.Check(ctx, "tenant1", "signup", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st.loads != 1 {
+		t.Fatalf("loads = %d, want 1", st.loads)
+	}
+
+	// A new schema is used once the cache is invalidated...
+	st.schemas["tenant1"] = append(st.schemas["tenant1"], store.EventSchema{EventName: "login", Schema: []byte(`true`)})
+	r.Invalidate("tenant1")
+	if res, _ := r.Check(ctx, "tenant1", "login", nil); res.Flagged() {
+		t.Fatalf("login still unregistered after Invalidate: %v", res.Errors)
+	}
+
+	// ...or once it expires.
+	st.schemas["tenant1"] = nil
+	now = now.Add(2 * time.Minute)
+	if res, _ := r.Check(ctx, "tenant1", "login", nil); res.Flagged() || st.loads != 3 {
+		t.Fatalf("cache not reloaded after expiry: loads = %d, errors %v", st.loads, res.Errors)
+	}
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrAPIKeyNotFound is returned when no api_keys row has the requested id.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// ErrAPIKeyInactive is returned when rotating a key that is revoked or expired.
+var ErrAPIKeyInactive = errors.New("api key is revoked or expired")
+
+// APIKey is a stored API key. The secret itself is never stored, only Hash,
+// a salted digest computed by the auth package.
+type APIKey struct {
+	ID         string
+	TenantID   string
+	Salt       []byte
+	Hash       []byte
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+// Active reports whether the key may be used at time now.
+func (k APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil && !k.RevokedAt.After(now) {
+		return false
+	}
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(now) {
+		return false
+	}
+	return true
+}
+
+const apiKeyColumns = `id, tenant_id, salt, key_hash, scopes, created_at, expires_at, revoked_at, last_used_at`
+
+func scanAPIKey(row pgx.Row) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.TenantID, &k.Salt, &k.Hash, &k.Scopes,
+		&k.CreatedAt, &k.ExpiresAt, &k.RevokedAt, &k.LastUsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return k, err
+}
+
+// CreateAPIKey stores a new key and returns it with its server-side timestamps.
+func (p *PostgresStore) CreateAPIKey(ctx context.Context, k APIKey) (APIKey, error) {
+	if k.ID == "" || k.TenantID == "" || len(k.Hash) == 0 {
+		return APIKey{}, errors.New("id/tenantID/hash required")
+	}
+	if k.Scopes == nil {
+		k.Scopes = []string{}
+	}
+
+	return scanAPIKey(p.pool.QueryRow(ctx, `
+		INSERT INTO api_keys(id, tenant_id, salt, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiKeyColumns,
+		k.ID, k.TenantID, k.Salt, k.Hash, k.Scopes, k.ExpiresAt))
+}
+
+// SyncEnvAPIKeys makes the keys imported from API_KEYS match keys, in one
+// transaction, and returns how many keys it revoked.
+//
+// Listed keys are inserted, or moved to the tenant they are now listed under.
+// A key the sync revoked earlier is reinstated once it is listed again; a
+// revocation made through the admin API is kept. Previously imported keys
+// that are no longer listed are revoked at now.
+func (p *PostgresStore) SyncEnvAPIKeys(ctx context.Context, keys []APIKey, now time.Time) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k.ID == "" || k.TenantID == "" || len(k.Hash) == 0 {
+			return 0, errors.New("id/tenantID/hash required")
+		}
+		if k.Scopes == nil {
+			k.Scopes = []string{}
+		}
+		ids = append(ids, k.ID)
+
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO api_keys(id, tenant_id, salt, key_hash, scopes, expires_at, from_env)
+			VALUES ($1, $2, $3, $4, $5, $6, true)
+			ON CONFLICT (id) DO UPDATE
+			SET tenant_id = EXCLUDED.tenant_id, from_env = true,
+			    revoked_at = CASE WHEN api_keys.revoked_by_sync THEN NULL ELSE api_keys.revoked_at END,
+			    revoked_by_sync = false
+			WHERE api_keys.tenant_id <> EXCLUDED.tenant_id OR NOT api_keys.from_env OR api_keys.revoked_by_sync
+		`, k.ID, k.TenantID, k.Salt, k.Hash, k.Scopes, k.ExpiresAt); err != nil {
+			return 0, err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE api_keys
+		SET revoked_at = $2, revoked_by_sync = true
+		WHERE from_env AND revoked_at IS NULL AND id <> ALL($1::text[])
+	`, ids, now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// GetAPIKey returns the key with the given id, or ErrAPIKeyNotFound.
+func (p *PostgresStore) GetAPIKey(ctx context.Context, id string) (APIKey, error) {
+	return scanAPIKey(p.pool.QueryRow(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+}
+
+// ListAPIKeys returns the keys of a tenant, or of every tenant when tenantID is
+// empty, oldest first.
+func (p *PostgresStore) ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE $1 = '' OR tenant_id = $1
+		ORDER BY tenant_id, created_at, id
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey marks a key revoked at time at and returns it. Revoking an already
+// revoked key keeps the original revocation time, but a key the API_KEYS sync
+// revoked is then no longer reinstated when it is listed again. A non-empty tenantID restricts
+// the operation to that tenant's keys; others are reported as not found.
+func (p *PostgresStore) RevokeAPIKey(ctx context.Context, id, tenantID string, at time.Time) (APIKey, error) {
+	return scanAPIKey(p.pool.QueryRow(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, $3), revoked_by_sync = false
+		WHERE id = $1 AND ($2 = '' OR tenant_id = $2)
+		RETURNING `+apiKeyColumns,
+		id, tenantID, at))
+}
+
+// RotateAPIKey replaces the key oldID with next in one transaction. next inherits
+// the tenant, scopes and expiry of the old key. The old key keeps working for
+// grace after now (zero retires it immediately) so clients can switch over.
+// A non-empty tenantID restricts the operation to that tenant's keys.
+func (p *PostgresStore) RotateAPIKey(
+	ctx context.Context,
+	oldID, tenantID string,
+	next APIKey,
+	now time.Time,
+	grace time.Duration,
+) (APIKey, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return APIKey{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	old, err := scanAPIKey(tx.QueryRow(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 AND ($2 = '' OR tenant_id = $2) FOR UPDATE`,
+		oldID, tenantID))
+	if err != nil {
+		return APIKey{}, err
+	}
+	if !old.Active(now) {
+		return APIKey{}, ErrAPIKeyInactive
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE api_keys
+		SET expires_at = LEAST(COALESCE(expires_at, $2), $2)
+		WHERE id = $1
+	`, oldID, now.Add(grace)); err != nil {
+		return APIKey{}, err
+	}
+
+	created, err := scanAPIKey(tx.QueryRow(ctx, `
+		INSERT INTO api_keys(id, tenant_id, salt, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiKeyColumns,
+		next.ID, old.TenantID, next.Salt, next.Hash, old.Scopes, old.ExpiresAt))
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	return created, tx.Commit(ctx)
+}
+
+// TouchAPIKey records that a key was used at time at. Callers throttle it; the
+// update is skipped when last_used_at is already within the past minute.
+func (p *PostgresStore) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1
+		  AND (last_used_at IS NULL OR last_used_at < $2 - interval '1 minute')
+	`, id, at)
+	return err
+}
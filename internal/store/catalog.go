@@ -0,0 +1,293 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// catalogLockID is the advisory lock key guarding event_catalog and
+// event_catalog_properties. The compactor and erasure hold it exclusively, so
+// erased samples cannot be folded back in from pending deltas.
+const catalogLockID = 0x6576_6361_7461
+
+// maxSamples is how many distinct scalar values are kept per property key and type.
+const maxSamples = 5
+
+// catalogSQL is a list of CTEs appending the catalog entries of the rows of a
+// preceding "written" CTE (returning tenant_id, event_name, ts, properties and
+// user_id of new events) to event_catalog_deltas and
+// event_catalog_property_deltas. Deltas commit with the events they describe,
+// and writers only append, so concurrent ingestion never locks catalog rows;
+// CompactCatalog folds the deltas in. Up to maxSamples distinct scalar samples
+// are kept per property key and type; strings are cut to 100 characters.
+//
+// It is embedded in statements with parameters of their own, so maxSamples is
+// spelled into the SQL rather than passed.
+var catalogSQL = `
+	catalog_values AS (
+		SELECT w.tenant_id, w.event_name, kv.key, jsonb_typeof(kv.value) AS json_type,
+		       min(w.ts) AS first_seen, max(w.ts) AS last_seen, count(*) AS events,
+		       CASE jsonb_typeof(kv.value)
+		         WHEN 'string' THEN jsonb_build_object('value', left(kv.value #>> '{}', 100), 'user_id', min(w.user_id))
+		         WHEN 'number' THEN jsonb_build_object('value', kv.value, 'user_id', min(w.user_id))
+		         WHEN 'boolean' THEN jsonb_build_object('value', kv.value, 'user_id', min(w.user_id))
+		       END AS sample
+		FROM written w, jsonb_each(w.properties) kv
+		GROUP BY w.tenant_id, w.event_name, kv.key, kv.value
+	), catalog_events AS (
+		INSERT INTO event_catalog_deltas(tenant_id, event_name, first_seen, last_seen, events)
+		SELECT tenant_id, event_name, min(ts), max(ts), count(*)
+		FROM written
+		GROUP BY 1, 2
+	), catalog_properties AS (
+		INSERT INTO event_catalog_property_deltas(tenant_id, event_name, key, json_type, first_seen, last_seen, events, samples)
+		SELECT tenant_id, event_name, key, json_type, min(first_seen), max(last_seen), sum(events),
+		       jsonb_path_query_array(COALESCE(jsonb_agg(sample) FILTER (WHERE sample IS NOT NULL), '[]'::jsonb),
+		                              '$[0 to ` + strconv.Itoa(maxSamples-1) + `]')
+		FROM catalog_values
+		GROUP BY 1, 2, 3, 4
+	)`
+
+// CompactCatalog folds up to limit pending rows of each catalog delta table
+// into event_catalog and event_catalog_properties and deletes them. It returns
+// how many catalog rows were written and whether no deltas were left behind.
+//
+// Only one pass runs at a time (catalogLockID) and it upserts in key order, so
+// passes never deadlock with each other.
+func (p *PostgresStore) CompactCatalog(ctx context.Context, limit int) (int64, bool, error) {
+	var eventDeltas, propertyDeltas, events, properties int64
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(catalogLockID)); err != nil {
+			return err
+		}
+		if err := tx.QueryRow(ctx, `
+			WITH drained AS (
+				DELETE FROM event_catalog_deltas
+				WHERE id IN (SELECT id FROM event_catalog_deltas ORDER BY id LIMIT $1)
+				RETURNING tenant_id, event_name, first_seen, last_seen, events
+			), folded AS (
+				INSERT INTO event_catalog AS c (tenant_id, event_name, first_seen, last_seen, events)
+				SELECT tenant_id, event_name, min(first_seen), max(last_seen), sum(events)
+				FROM drained
+				GROUP BY 1, 2
+				ORDER BY 1, 2
+				ON CONFLICT (tenant_id, event_name) DO UPDATE
+				SET first_seen = LEAST(c.first_seen, EXCLUDED.first_seen),
+				    last_seen = GREATEST(c.last_seen, EXCLUDED.last_seen),
+				    events = c.events + EXCLUDED.events
+				RETURNING 1
+			)
+			SELECT (SELECT count(*) FROM drained), (SELECT count(*) FROM folded)
+		`, limit).Scan(&eventDeltas, &events); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			WITH drained AS (
+				DELETE FROM event_catalog_property_deltas
+				WHERE id IN (SELECT id FROM event_catalog_property_deltas ORDER BY id LIMIT $1)
+				RETURNING id, tenant_id, event_name, key, json_type, first_seen, last_seen, events, samples
+			), sampled AS (
+				SELECT DISTINCT ON (d.tenant_id, d.event_name, d.key, d.json_type, t.s->'value')
+				       d.tenant_id, d.event_name, d.key, d.json_type, t.s, d.id, t.i
+				FROM drained d, jsonb_array_elements(d.samples) WITH ORDINALITY AS t(s, i)
+				ORDER BY d.tenant_id, d.event_name, d.key, d.json_type, t.s->'value', d.id, t.i
+			), samples AS (
+				SELECT tenant_id, event_name, key, json_type,
+				       jsonb_path_query_array(jsonb_agg(s ORDER BY id, i), '$[0 to $last]',
+				                              jsonb_build_object('last', $2::int - 1)) AS samples
+				FROM sampled
+				GROUP BY 1, 2, 3, 4
+			), props AS (
+				SELECT tenant_id, event_name, key, json_type, min(first_seen) AS first_seen,
+				       max(last_seen) AS last_seen, sum(events) AS events
+				FROM drained
+				GROUP BY 1, 2, 3, 4
+			), folded AS (
+				INSERT INTO event_catalog_properties AS c (tenant_id, event_name, key, json_type, first_seen, last_seen, events, samples)
+				SELECT p.tenant_id, p.event_name, p.key, p.json_type, p.first_seen, p.last_seen, p.events,
+				       COALESCE(s.samples, '[]'::jsonb)
+				FROM props p
+				LEFT JOIN samples s USING (tenant_id, event_name, key, json_type)
+				ORDER BY 1, 2, 3, 4
+				ON CONFLICT (tenant_id, event_name, key, json_type) DO UPDATE
+				SET first_seen = LEAST(c.first_seen, EXCLUDED.first_seen),
+				    last_seen = GREATEST(c.last_seen, EXCLUDED.last_seen),
+				    events = c.events + EXCLUDED.events,
+				    samples = CASE WHEN jsonb_array_length(c.samples) >= $2::int THEN c.samples ELSE (
+				      SELECT COALESCE(jsonb_agg(s ORDER BY i), '[]'::jsonb)
+				      FROM (
+				        SELECT s, i
+				        FROM (
+				          SELECT DISTINCT ON (s->'value') s, i
+				          FROM jsonb_array_elements(c.samples || EXCLUDED.samples) WITH ORDINALITY AS t(s, i)
+				          ORDER BY s->'value', i
+				        ) d
+				        ORDER BY i
+				        LIMIT $2::int
+				      ) kept
+				    ) END
+				RETURNING 1
+			)
+			SELECT (SELECT count(*) FROM drained), (SELECT count(*) FROM folded)
+		`, limit, maxSamples).Scan(&propertyDeltas, &properties)
+	})
+	return events + properties, eventDeltas < int64(limit) && propertyDeltas < int64(limit), err
+}
+
+// eraseCatalogSamples removes the samples a user contributed to the catalog of
+// a tenant, including those of pending deltas. It takes catalogLockID for the
+// rest of tx, so a compaction pass cannot fold erased samples back in.
+func eraseCatalogSamples(ctx context.Context, tx pgx.Tx, tenantID, userID string) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(catalogLockID)); err != nil {
+		return err
+	}
+	for _, table := range []string{"event_catalog_properties", "event_catalog_property_deltas"} {
+		if _, err := tx.Exec(ctx, `
+			UPDATE `+table+`
+			SET samples = (
+				SELECT COALESCE(jsonb_agg(s ORDER BY i), '[]'::jsonb)
+				FROM jsonb_array_elements(samples) WITH ORDINALITY AS t(s, i)
+				WHERE s->>'user_id' IS DISTINCT FROM $2
+			)
+			WHERE tenant_id = $1 AND samples @> jsonb_build_array(jsonb_build_object('user_id', $2::text))
+		`, tenantID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CatalogEvent is an event name a tenant has sent, with the property keys seen
+// with it.
+type CatalogEvent struct {
+	EventName  string
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	Events     int64
+	Properties []CatalogProperty
+}
+
+// CatalogProperty is a top-level property key seen with one JSON type (as
+// reported by jsonb_typeof: string, number, boolean, object, array or null).
+// A key sent with several types has one entry per type.
+type CatalogProperty struct {
+	Key       string
+	Type      string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Events    int64
+	Samples   []json.RawMessage // up to maxSamples distinct scalar values
+}
+
+// EventCatalog returns the event names tenantID has sent, by name, with their
+// property keys by key and type. A non-empty eventName restricts it to that
+// name. It reads the catalog and its pending deltas, never the events.
+func (p *PostgresStore) EventCatalog(ctx context.Context, tenantID, eventName string) ([]CatalogEvent, error) {
+	ctx, o := startOp(ctx, "event_catalog", tenantID, eventName)
+	defer o.end()
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT event_name, min(first_seen), max(last_seen), sum(events)::bigint
+		FROM (
+			SELECT event_name, first_seen, last_seen, events
+			FROM event_catalog
+			WHERE tenant_id = $1 AND ($2 = '' OR event_name = $2)
+			UNION ALL
+			SELECT event_name, first_seen, last_seen, events
+			FROM event_catalog_deltas
+			WHERE tenant_id = $1 AND ($2 = '' OR event_name = $2)
+		) c
+		GROUP BY event_name
+		ORDER BY event_name
+	`, tenantID, eventName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	catalog := []CatalogEvent{}
+	index := map[string]int{}
+	for rows.Next() {
+		var e CatalogEvent
+		if err := rows.Scan(&e.EventName, &e.FirstSeen, &e.LastSeen, &e.Events); err != nil {
+			return nil, err
+		}
+		e.Properties = []CatalogProperty{}
+		index[e.EventName] = len(catalog)
+		catalog = append(catalog, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(catalog) == 0 {
+		return catalog, nil
+	}
+
+	// The sample values of each entry, the folded row's first and then those of
+	// its deltas in write order, as the compactor will keep them.
+	rows, err = p.pool.Query(ctx, `
+		SELECT event_name, key, json_type, min(first_seen), max(last_seen), sum(events)::bigint,
+		       jsonb_agg(sample_values ORDER BY id)
+		FROM (
+			SELECT event_name, key, json_type, first_seen, last_seen, events, samples, 0::bigint AS id
+			FROM event_catalog_properties
+			WHERE tenant_id = $1 AND ($2 = '' OR event_name = $2)
+			UNION ALL
+			SELECT event_name, key, json_type, first_seen, last_seen, events, samples, id
+			FROM event_catalog_property_deltas
+			WHERE tenant_id = $1 AND ($2 = '' OR event_name = $2)
+		) c, LATERAL (
+			SELECT COALESCE(jsonb_agg(s->'value' ORDER BY i), '[]'::jsonb) AS sample_values
+			FROM jsonb_array_elements(c.samples) WITH ORDINALITY AS t(s, i)
+		) v
+		GROUP BY event_name, key, json_type
+		ORDER BY event_name, key, json_type
+	`, tenantID, eventName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name    string
+			prop    CatalogProperty
+			samples []byte
+		)
+		if err := rows.Scan(&name, &prop.Key, &prop.Type, &prop.FirstSeen, &prop.LastSeen, &prop.Events, &samples); err != nil {
+			return nil, err
+		}
+		var lists [][]json.RawMessage
+		if err := json.Unmarshal(samples, &lists); err != nil {
+			return nil, err
+		}
+		prop.Samples = mergeSamples(lists)
+		// Properties of a name first sent after the first query are skipped.
+		if i, ok := index[name]; ok {
+			catalog[i].Properties = append(catalog[i].Properties, prop)
+		}
+	}
+	return catalog, rows.Err()
+}
+
+// mergeSamples returns the first maxSamples distinct values of lists, in order.
+func mergeSamples(lists [][]json.RawMessage) []json.RawMessage {
+	merged := []json.RawMessage{}
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, v := range list {
+			if len(merged) == maxSamples {
+				return merged
+			}
+			if !seen[string(v)] {
+				seen[string(v)] = true
+				merged = append(merged, v)
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergeSamples(t *testing.T) {
+	raw := func(vs ...string) []json.RawMessage {
+		out := []json.RawMessage{}
+		for _, v := range vs {
+			out = append(out, json.RawMessage(v))
+		}
+		return out
+	}
+
+	cases := []struct {
+		lists [][]json.RawMessage
+		want  []json.RawMessage
+	}{
+		{nil, raw()},
+		{[][]json.RawMessage{raw(), raw()}, raw()},
+		// The folded row's samples come first, then new values of the deltas.
+		{[][]json.RawMessage{raw(`"pro"`), raw(`"free"`, `"pro"`), raw(`"pro"`, `1`)}, raw(`"pro"`, `"free"`, `1`)},
+		// At most 5 are kept.
+		{[][]json.RawMessage{raw(`1`, `2`, `3`, `4`), raw(`4`, `5`, `6`)}, raw(`1`, `2`, `3`, `4`, `5`)},
+	}
+	for _, tc := range cases {
+		if got := mergeSamples(tc.lists); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("mergeSamples(%s) = %s, want %s", tc.lists, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Change describes stored events that changed, for a ChangeHook.
+type Change struct {
+	TenantID  string    // empty: events of any tenant were deleted
+	EventName string    // empty: events of the tenant were deleted
+	OldestTS  time.Time // oldest timestamp of the written events
+	Written   int       // how many events were written; 0 for deletions
+}
+
+// ChangeHook is told about stored events that changed, after the change
+// committed, e.g. to invalidate cached metrics or feed live counters.
+type ChangeHook func(ctx context.Context, c Change)
+
+// OnChange registers hook; hooks run in registration order. Call it before the
+// store is shared with other goroutines.
+func (p *PostgresStore) OnChange(hook ChangeHook) {
+	p.onChange = append(p.onChange, hook)
+}
+
+// writtenEvent identifies a row written to events.
+type writtenEvent struct {
+	TenantID  string
+	EventName string
+	TS        time.Time
+}
+
+// notifyWritten calls the change hooks once per tenant and event name in events.
+func (p *PostgresStore) notifyWritten(ctx context.Context, events []writtenEvent) {
+	if len(p.onChange) == 0 || len(events) == 0 {
+		return
+	}
+
+	type scope struct{ tenantID, eventName string }
+	changes := map[scope]*Change{}
+	for _, e := range events {
+		k := scope{e.TenantID, e.EventName}
+		c := changes[k]
+		if c == nil {
+			c = &Change{TenantID: e.TenantID, EventName: e.EventName, OldestTS: e.TS}
+			changes[k] = c
+		}
+		if e.TS.Before(c.OldestTS) {
+			c.OldestTS = e.TS
+		}
+		c.Written++
+	}
+	for _, c := range changes {
+		p.notify(ctx, *c)
+	}
+}
+
+// notifyDeleted calls the change hooks for events of tenantID (any tenant when
+// empty) that were deleted.
+func (p *PostgresStore) notifyDeleted(ctx context.Context, tenantID string) {
+	p.notify(ctx, Change{TenantID: tenantID})
+}
+
+func (p *PostgresStore) notify(ctx context.Context, c Change) {
+	for _, hook := range p.onChange {
+		hook(ctx, c)
+	}
+}
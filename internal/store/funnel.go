@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FunnelQuery describes an ordered funnel for one tenant.
+//
+// Actors enter the funnel with their first Steps[0] event in [From,To). Every later
+// step must happen at or after the previous step and in [entry, entry+Window),
+// which may reach past To. The window is half-open like every other range here.
+type FunnelQuery struct {
+	TenantID string
+	Steps    []string
+	From     time.Time
+	To       time.Time
+	Window   time.Duration
+}
+
+// Funnel returns the number of actors that reached each step of q, in step order.
+//
+// Each step is one CTE holding, per actor, the entry time and the earliest time the
+// step was completed; it only joins actors that survived the previous step, so the
+// work shrinks as the funnel narrows. Candidate events are read once through
+// idx_events_tenant_name_ts (tenant, step names, window) and materialized.
+func (p *PostgresStore) Funnel(ctx context.Context, q FunnelQuery) ([]int64, error) {
+	ctx, o := startOp(ctx, "funnel", q.TenantID, "")
+	defer o.end()
+
+	if len(q.Steps) == 0 {
+		return nil, errors.New("at least one funnel step required")
+	}
+
+	var args argList
+	tenantArg := args.add(q.TenantID)
+	namesArg := args.add(q.Steps)
+	fromArg := args.add(q.From)
+	toArg := args.add(q.To)
+	windowArg := args.add(q.Window.Microseconds())
+	window := "(" + windowArg + "::bigint * interval '1 microsecond')"
+
+	var b strings.Builder
+	b.WriteString(`
+		WITH e AS MATERIALIZED (
+			SELECT ` + actorExpr + ` AS actor, event_name, ts
+			FROM events
+			WHERE tenant_id = ` + tenantArg + `
+			  AND event_name = ANY(` + namesArg + `::text[])
+			  AND ts >= ` + fromArg + `
+			  AND ts < ` + toArg + `::timestamptz + ` + window + `
+			  AND ` + actorExpr + ` IS NOT NULL
+		), s1 AS (
+			SELECT actor, MIN(ts) AS t0, MIN(ts) AS t
+			FROM e
+			WHERE event_name = ` + args.add(q.Steps[0]) + `
+			  AND ts < ` + toArg + `
+			GROUP BY actor
+		)`)
+
+	for i := 1; i < len(q.Steps); i++ {
+		// A step repeating the previous event name must be a later occurrence,
+		// not the same event matched twice.
+		after := ">="
+		if q.Steps[i] == q.Steps[i-1] {
+			after = ">"
+		}
+		fmt.Fprintf(&b, `, s%[1]d AS (
+			SELECT s.actor, s.t0, MIN(e.ts) AS t
+			FROM s%[2]d s
+			JOIN e ON e.actor = s.actor
+			      AND e.event_name = %[3]s
+			      AND e.ts %[4]s s.t
+			      AND e.ts < s.t0 + %[5]s
+			GROUP BY s.actor, s.t0
+		)`, i+1, i, args.add(q.Steps[i]), after, window)
+	}
+
+	counts := make([]string, len(q.Steps))
+	for i := range q.Steps {
+		counts[i] = fmt.Sprintf("(SELECT COUNT(*) FROM s%d)", i+1)
+	}
+	b.WriteString(`
+		SELECT ARRAY[` + strings.Join(counts, ", ") + `]::bigint[]`)
+
+	var reached []int64
+	if err := p.pool.QueryRow(ctx, b.String(), args...).Scan(&reached); err != nil {
+		return nil, err
+	}
+	return reached, nil
+}
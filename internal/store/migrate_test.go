@@ -0,0 +1,45 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	all, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || all[0].Version != 1 || all[0].Name != "create_events" {
+		t.Fatalf("unexpected first migration: %+v", all)
+	}
+}
+
+func TestLoadMigrations_RejectsBrokenHistory(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+	cases := map[string]fstest.MapFS{
+		"version 2 is missing": {
+			"migrations/0001_a.up.sql":   file("SELECT 1"),
+			"migrations/0001_a.down.sql": file("SELECT 1"),
+			"migrations/0003_c.up.sql":   file("SELECT 1"),
+			"migrations/0003_c.down.sql": file("SELECT 1"),
+		},
+		"needs both up and down": {
+			"migrations/0001_a.up.sql": file("SELECT 1"),
+		},
+		"must be named": {
+			"migrations/first.up.sql": file("SELECT 1"),
+		},
+		"two names": {
+			"migrations/0001_a.up.sql":   file("SELECT 1"),
+			"migrations/0001_b.down.sql": file("SELECT 1"),
+		},
+	}
+	for want, fsys := range cases {
+		if _, err := loadMigrations(fsys); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: got err %v", want, err)
+		}
+	}
+}
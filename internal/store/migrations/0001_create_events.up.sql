@@ -1,5 +1,6 @@
 -- Durable event storage.
 -- Idempotency is enforced via PRIMARY KEY (tenant_id, event_id).
+-- IF NOT EXISTS lets databases created before migrations adopt this version.
 
 CREATE TABLE IF NOT EXISTS events (
   tenant_id   TEXT        NOT NULL,
@@ -0,0 +1,2 @@
+ALTER TABLE events DROP COLUMN IF EXISTS anonymous_id;
+ALTER TABLE events DROP COLUMN IF EXISTS user_id;
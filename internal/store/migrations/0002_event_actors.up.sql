@@ -0,0 +1,3 @@
+-- Actor identity used for unique-user metrics.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS user_id      TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS anonymous_id TEXT;
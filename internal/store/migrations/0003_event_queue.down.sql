@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS event_dead_letters;
+DROP TABLE IF EXISTS event_queue;
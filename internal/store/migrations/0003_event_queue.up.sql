@@ -0,0 +1,38 @@
+-- Outbox for asynchronous ingestion (INGEST_MODE=async).
+-- POST /events appends here; the worker drains rows into events and deletes them.
+-- The unique constraint dedupes client retries while an event is still queued.
+CREATE TABLE IF NOT EXISTS event_queue (
+  id              BIGSERIAL   PRIMARY KEY,
+  tenant_id       TEXT        NOT NULL,
+  event_id        TEXT        NOT NULL,
+  event_name      TEXT        NOT NULL,
+  ts              TIMESTAMPTZ NOT NULL,
+  properties      JSONB       NOT NULL DEFAULT '{}'::jsonb,
+  user_id         TEXT,
+  anonymous_id    TEXT,
+  enqueued_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  attempts        INT         NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_error      TEXT,
+  UNIQUE (tenant_id, event_id)
+);
+
+-- Worker claim order: due rows first, oldest first.
+CREATE INDEX IF NOT EXISTS idx_event_queue_due
+  ON event_queue(next_attempt_at, id);
+
+-- Queue rows that exhausted their retries, kept for inspection and replay.
+CREATE TABLE IF NOT EXISTS event_dead_letters (
+  id           BIGINT      PRIMARY KEY,
+  tenant_id    TEXT        NOT NULL,
+  event_id     TEXT        NOT NULL,
+  event_name   TEXT        NOT NULL,
+  ts           TIMESTAMPTZ NOT NULL,
+  properties   JSONB       NOT NULL,
+  user_id      TEXT,
+  anonymous_id TEXT,
+  enqueued_at  TIMESTAMPTZ NOT NULL,
+  attempts     INT         NOT NULL,
+  last_error   TEXT,
+  failed_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
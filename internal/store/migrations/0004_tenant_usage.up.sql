@@ -0,0 +1,9 @@
+-- Per-tenant ingestion usage for daily/monthly quotas, shared by all replicas.
+-- period is 'day' or 'month'; period_start is the UTC start of that period.
+CREATE TABLE IF NOT EXISTS tenant_usage (
+  tenant_id    TEXT   NOT NULL,
+  period       TEXT   NOT NULL,
+  period_start DATE   NOT NULL,
+  events       BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (tenant_id, period, period_start)
+);
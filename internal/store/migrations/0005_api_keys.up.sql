@@ -0,0 +1,18 @@
+-- API keys. Only a salted SHA-256 of the secret part is stored; the plaintext
+-- key is shown once at creation. id is the public, non-secret part of the key
+-- and is used for lookup. An empty scopes array grants the default scopes
+-- (events:write, metrics:read).
+CREATE TABLE IF NOT EXISTS api_keys (
+  id           TEXT        PRIMARY KEY,
+  tenant_id    TEXT        NOT NULL,
+  salt         BYTEA       NOT NULL,
+  key_hash     BYTEA       NOT NULL,
+  scopes       TEXT[]      NOT NULL DEFAULT '{}',
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at   TIMESTAMPTZ,
+  revoked_at   TIMESTAMPTZ,
+  last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant
+  ON api_keys(tenant_id, created_at);
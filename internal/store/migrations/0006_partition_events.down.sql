@@ -0,0 +1,27 @@
+-- Back to a single events table deduplicated by its primary key. Detached
+-- partitions are standalone tables and are left alone.
+CREATE TABLE events_unpartitioned (
+  tenant_id    TEXT        NOT NULL,
+  event_id     TEXT        NOT NULL,
+  event_name   TEXT        NOT NULL,
+  ts           TIMESTAMPTZ NOT NULL,
+  properties   JSONB       NOT NULL DEFAULT '{}'::jsonb,
+  ingested_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  user_id      TEXT,
+  anonymous_id TEXT,
+  PRIMARY KEY (tenant_id, event_id)
+);
+
+INSERT INTO events_unpartitioned(tenant_id, event_id, event_name, ts, properties, ingested_at, user_id, anonymous_id)
+SELECT tenant_id, event_id, event_name, ts, properties, ingested_at, user_id, anonymous_id
+FROM events
+ON CONFLICT (tenant_id, event_id) DO NOTHING;
+
+DROP TABLE events;
+DROP TABLE event_partitions;
+DROP TABLE event_ids;
+
+ALTER TABLE events_unpartitioned RENAME TO events;
+ALTER TABLE events RENAME CONSTRAINT events_unpartitioned_pkey TO events_pkey;
+
+CREATE INDEX idx_events_tenant_name_ts ON events(tenant_id, event_name, ts);
@@ -0,0 +1,67 @@
+-- Range-partition events on ts.
+--
+-- A unique constraint on a partitioned table must include the partition key, so
+-- idempotency moves to event_ids: an event is written only if its
+-- (tenant_id, event_id) could be claimed there in the same statement. ts is kept
+-- so ids can be pruned together with expired partitions.
+CREATE TABLE event_ids (
+  tenant_id TEXT        NOT NULL,
+  event_id  TEXT        NOT NULL,
+  ts        TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (tenant_id, event_id)
+);
+
+CREATE INDEX idx_event_ids_ts ON event_ids(ts);
+
+INSERT INTO event_ids(tenant_id, event_id, ts)
+SELECT tenant_id, event_id, ts FROM events;
+
+-- The existing table becomes the first partition, without copying its rows.
+ALTER TABLE events RENAME TO events_legacy;
+ALTER TABLE events_legacy RENAME CONSTRAINT events_pkey TO events_legacy_pkey;
+ALTER INDEX idx_events_tenant_name_ts RENAME TO idx_events_legacy_tenant_name_ts;
+
+CREATE TABLE events (
+  tenant_id    TEXT        NOT NULL,
+  event_id     TEXT        NOT NULL,
+  event_name   TEXT        NOT NULL,
+  ts           TIMESTAMPTZ NOT NULL,
+  properties   JSONB       NOT NULL DEFAULT '{}'::jsonb,
+  ingested_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  user_id      TEXT,
+  anonymous_id TEXT
+) PARTITION BY RANGE (ts);
+
+-- Created on the parent so every partition gets it.
+CREATE INDEX idx_events_tenant_name_ts ON events(tenant_id, event_name, ts);
+
+-- Catches events outside every partition (far past or future timestamps). The
+-- maintainer moves rows out of it when it creates a partition for their range.
+CREATE TABLE events_default PARTITION OF events DEFAULT;
+
+-- Partitions managed by the maintainer. range_start NULL means unbounded below.
+CREATE TABLE event_partitions (
+  name        TEXT        PRIMARY KEY,
+  range_start TIMESTAMPTZ,
+  range_end   TIMESTAMPTZ NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- History up to the start of next (UTC) month stays in events_legacy; anything
+-- newer moves to the default partition so regular partitions can take over.
+DO $$
+DECLARE
+  bound TIMESTAMPTZ := (date_trunc('month', now() AT TIME ZONE 'UTC') + interval '1 month') AT TIME ZONE 'UTC';
+BEGIN
+  INSERT INTO events_default(tenant_id, event_id, event_name, ts, properties, ingested_at, user_id, anonymous_id)
+  SELECT tenant_id, event_id, event_name, ts, properties, ingested_at, user_id, anonymous_id
+  FROM events_legacy
+  WHERE ts >= bound;
+
+  DELETE FROM events_legacy WHERE ts >= bound;
+
+  EXECUTE format('ALTER TABLE events ATTACH PARTITION events_legacy FOR VALUES FROM (MINVALUE) TO (%L)', bound);
+
+  INSERT INTO event_partitions(name, range_start, range_end) VALUES ('events_legacy', NULL, bound);
+END
+$$;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_tenant_user;
+DROP TABLE IF EXISTS privacy_audit;
+DROP TABLE IF EXISTS privacy_exports;
+DROP TABLE IF EXISTS privacy_jobs;
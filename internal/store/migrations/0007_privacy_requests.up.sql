@@ -0,0 +1,53 @@
+-- Data subject requests (GDPR erasure and access), processed asynchronously.
+-- status: pending -> running -> succeeded | failed; export output is removed
+-- after expires_at and the job becomes expired. heartbeat_at lets another
+-- replica take over a running job whose runner died.
+CREATE TABLE privacy_jobs (
+  id           TEXT        PRIMARY KEY,
+  kind         TEXT        NOT NULL CHECK (kind IN ('erase', 'export')),
+  tenant_id    TEXT        NOT NULL,
+  user_id      TEXT        NOT NULL,
+  status       TEXT        NOT NULL DEFAULT 'pending',
+  requested_by TEXT        NOT NULL,
+  events       BIGINT      NOT NULL DEFAULT 0,
+  error        TEXT,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  started_at   TIMESTAMPTZ,
+  heartbeat_at TIMESTAMPTZ,
+  finished_at  TIMESTAMPTZ,
+  expires_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_privacy_jobs_open
+  ON privacy_jobs(created_at) WHERE status IN ('pending', 'running');
+
+CREATE INDEX idx_privacy_jobs_subject
+  ON privacy_jobs(tenant_id, user_id, created_at);
+
+-- JSONL output of export jobs, split into chunks.
+CREATE TABLE privacy_exports (
+  job_id TEXT  NOT NULL REFERENCES privacy_jobs(id) ON DELETE CASCADE,
+  seq    INT   NOT NULL,
+  data   BYTEA NOT NULL,
+  PRIMARY KEY (job_id, seq)
+);
+
+-- Append-only audit trail of data subject requests. It records what was done
+-- for a user, not their events, so it is kept after erasure.
+CREATE TABLE privacy_audit (
+  id        BIGSERIAL   PRIMARY KEY,
+  job_id    TEXT        NOT NULL,
+  tenant_id TEXT        NOT NULL,
+  user_id   TEXT        NOT NULL,
+  action    TEXT        NOT NULL,
+  actor     TEXT        NOT NULL,
+  detail    TEXT,
+  at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_privacy_audit_subject
+  ON privacy_audit(tenant_id, user_id, id);
+
+-- Finds a user's events without scanning the tenant.
+CREATE INDEX idx_events_tenant_user
+  ON events(tenant_id, user_id) WHERE user_id IS NOT NULL;
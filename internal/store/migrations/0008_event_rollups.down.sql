@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_events_ingested_at;
+DROP TABLE IF EXISTS rollup_state;
+DROP TABLE IF EXISTS event_rollups_hourly;
@@ -0,0 +1,22 @@
+-- Event counts per tenant, event name and UTC hour, maintained by the rollup
+-- compactor from events ingested before rollup_state.watermark. Deleting events
+-- subtracts them again, so rollups plus a scan of newer events stay exact.
+CREATE TABLE event_rollups_hourly (
+  tenant_id  TEXT        NOT NULL,
+  event_name TEXT        NOT NULL,
+  bucket     TIMESTAMPTZ NOT NULL,
+  events     BIGINT      NOT NULL,
+  PRIMARY KEY (tenant_id, event_name, bucket)
+);
+
+-- -infinity: nothing compacted yet; the compactor starts at the oldest event.
+CREATE TABLE rollup_state (
+  name      TEXT        PRIMARY KEY,
+  watermark TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO rollup_state(name, watermark) VALUES ('events_hourly', '-infinity');
+
+-- Lets the compactor, and queries for not yet compacted events, read recent
+-- ingestion without scanning by ts.
+CREATE INDEX idx_events_ingested_at ON events(ingested_at);
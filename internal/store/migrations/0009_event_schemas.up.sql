@@ -0,0 +1,11 @@
+-- JSON Schemas of event properties, registered per tenant and event name.
+-- version starts at 1 and grows with every replacement.
+CREATE TABLE event_schemas (
+  tenant_id  TEXT        NOT NULL,
+  event_name TEXT        NOT NULL,
+  schema     JSONB       NOT NULL,
+  version    INT         NOT NULL DEFAULT 1,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (tenant_id, event_name)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS event_catalog_properties;
+DROP TABLE IF EXISTS event_catalog;
@@ -0,0 +1,49 @@
+-- Event names and property keys each tenant has sent, maintained by the
+-- statements writing events (see catalogSQL), so GET /events/catalog never
+-- scans events. events counts stored events, duplicates excluded; it is not
+-- reduced when retention or erasure deletes them later.
+CREATE TABLE event_catalog (
+  tenant_id  TEXT        NOT NULL,
+  event_name TEXT        NOT NULL,
+  first_seen TIMESTAMPTZ NOT NULL,
+  last_seen  TIMESTAMPTZ NOT NULL,
+  events     BIGINT      NOT NULL,
+  PRIMARY KEY (tenant_id, event_name)
+);
+
+-- One row per top-level property key and JSON type (jsonb_typeof) seen with an
+-- event name. samples holds up to 5 distinct scalar values as
+-- {"value": ..., "user_id": ...}; user_id lets erasure remove a user's samples.
+CREATE TABLE event_catalog_properties (
+  tenant_id  TEXT        NOT NULL,
+  event_name TEXT        NOT NULL,
+  key        TEXT        NOT NULL,
+  json_type  TEXT        NOT NULL,
+  first_seen TIMESTAMPTZ NOT NULL,
+  last_seen  TIMESTAMPTZ NOT NULL,
+  events     BIGINT      NOT NULL,
+  samples    JSONB       NOT NULL DEFAULT '[]'::jsonb,
+  PRIMARY KEY (tenant_id, event_name, key, json_type)
+);
+
+-- Backfill from the events stored so far (a one-off scan at upgrade time).
+INSERT INTO event_catalog(tenant_id, event_name, first_seen, last_seen, events)
+SELECT tenant_id, event_name, min(ts), max(ts), count(*)
+FROM events
+GROUP BY 1, 2;
+
+INSERT INTO event_catalog_properties(tenant_id, event_name, key, json_type, first_seen, last_seen, events, samples)
+SELECT tenant_id, event_name, key, json_type, min(first_seen), max(last_seen), sum(events),
+       jsonb_path_query_array(COALESCE(jsonb_agg(sample) FILTER (WHERE sample IS NOT NULL), '[]'::jsonb), '$[0 to 4]')
+FROM (
+  SELECT e.tenant_id, e.event_name, kv.key, jsonb_typeof(kv.value) AS json_type,
+         min(e.ts) AS first_seen, max(e.ts) AS last_seen, count(*) AS events,
+         CASE jsonb_typeof(kv.value)
+           WHEN 'string' THEN jsonb_build_object('value', left(kv.value #>> '{}', 100), 'user_id', min(e.user_id))
+           WHEN 'number' THEN jsonb_build_object('value', kv.value, 'user_id', min(e.user_id))
+           WHEN 'boolean' THEN jsonb_build_object('value', kv.value, 'user_id', min(e.user_id))
+         END AS sample
+  FROM events e, jsonb_each(e.properties) kv
+  GROUP BY e.tenant_id, e.event_name, kv.key, kv.value
+) v
+GROUP BY 1, 2, 3, 4;
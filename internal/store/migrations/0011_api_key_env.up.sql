@@ -0,0 +1,6 @@
+-- Keys imported from the API_KEYS environment variable. They are synced on
+-- every boot: a key no longer listed is revoked, a changed tenant is applied.
+-- Until now such keys had an id derived from the key itself ("env_" prefix).
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS from_env BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE api_keys SET from_env = true WHERE id LIKE 'env\_%';
@@ -0,0 +1,21 @@
+-- Partitions detached from events by PARTITION_EXPIRE_MODE=detach. They are
+-- standalone tables afterwards, kept for archiving; erasure requests still
+-- delete the user's rows from them until they are dropped.
+CREATE TABLE IF NOT EXISTS event_partitions_detached (
+  name        TEXT        PRIMARY KEY,
+  range_start TIMESTAMPTZ,
+  range_end   TIMESTAMPTZ,
+  detached_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Partitions detached before they were recorded: events partitions that are
+-- standalone tables now.
+INSERT INTO event_partitions_detached(name)
+SELECT c.relname
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema()
+  AND c.relkind = 'r'
+  AND NOT c.relispartition
+  AND c.relname ~ '^events_(p[0-9]+|legacy)$'
+ON CONFLICT (name) DO NOTHING;
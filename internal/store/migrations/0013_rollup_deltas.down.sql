@@ -0,0 +1,18 @@
+CREATE INDEX IF NOT EXISTS idx_events_ingested_at ON events(ingested_at);
+
+CREATE TABLE rollup_state (
+  name      TEXT        PRIMARY KEY,
+  watermark TIMESTAMPTZ NOT NULL
+);
+
+-- Fold the pending deltas; events ingested from now on are compacted by ingested_at.
+INSERT INTO event_rollups_hourly AS r (tenant_id, event_name, bucket, events)
+SELECT tenant_id, event_name, bucket, SUM(events)
+FROM event_rollup_deltas
+GROUP BY 1, 2, 3
+ON CONFLICT (tenant_id, event_name, bucket)
+DO UPDATE SET events = r.events + EXCLUDED.events;
+
+INSERT INTO rollup_state(name, watermark) VALUES ('events_hourly', now());
+
+DROP TABLE event_rollup_deltas;
@@ -0,0 +1,25 @@
+-- Pending changes to event_rollups_hourly. Every statement writing or deleting
+-- events appends its counts here in the same transaction, and the compactor
+-- folds them into the rollups. Unlike a watermark on ingested_at, a delta is
+-- only visible once its transaction committed, so slow writers are never
+-- skipped; queries add the pending deltas to the rollups.
+CREATE TABLE event_rollup_deltas (
+  id         BIGSERIAL   PRIMARY KEY,
+  tenant_id  TEXT        NOT NULL,
+  event_name TEXT        NOT NULL,
+  bucket     TIMESTAMPTZ NOT NULL,
+  events     BIGINT      NOT NULL
+);
+
+CREATE INDEX idx_event_rollup_deltas_scope
+  ON event_rollup_deltas(tenant_id, event_name, bucket);
+
+-- Events the watermark compactor had not reached yet.
+INSERT INTO event_rollup_deltas(tenant_id, event_name, bucket, events)
+SELECT tenant_id, event_name, date_trunc('hour', ts, 'UTC'), COUNT(*)
+FROM events
+WHERE ingested_at >= (SELECT watermark FROM rollup_state WHERE name = 'events_hourly')
+GROUP BY 1, 2, 3;
+
+DROP TABLE rollup_state;
+DROP INDEX IF EXISTS idx_events_ingested_at;
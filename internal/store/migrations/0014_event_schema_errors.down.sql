@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_flagged;
+ALTER TABLE event_dead_letters DROP COLUMN IF EXISTS schema_errors;
+ALTER TABLE event_queue DROP COLUMN IF EXISTS schema_errors;
+ALTER TABLE events DROP COLUMN IF EXISTS schema_errors;
@@ -0,0 +1,9 @@
+-- Schema violations of events accepted by lenient validation, as a JSON array
+-- of {"field", "message"}; NULL for events that matched their schema (or were
+-- not validated). Queued and dead-lettered events carry them to events.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS schema_errors JSONB;
+ALTER TABLE event_queue ADD COLUMN IF NOT EXISTS schema_errors JSONB;
+ALTER TABLE event_dead_letters ADD COLUMN IF NOT EXISTS schema_errors JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_events_flagged
+  ON events(tenant_id, event_name, ts) WHERE schema_errors IS NOT NULL;
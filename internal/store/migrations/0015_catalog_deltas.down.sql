@@ -0,0 +1,23 @@
+-- Fold the pending deltas; events written from now on upsert the catalog again.
+INSERT INTO event_catalog AS c (tenant_id, event_name, first_seen, last_seen, events)
+SELECT tenant_id, event_name, min(first_seen), max(last_seen), sum(events)
+FROM event_catalog_deltas
+GROUP BY 1, 2
+ON CONFLICT (tenant_id, event_name) DO UPDATE
+SET first_seen = LEAST(c.first_seen, EXCLUDED.first_seen),
+    last_seen = GREATEST(c.last_seen, EXCLUDED.last_seen),
+    events = c.events + EXCLUDED.events;
+
+-- Samples of pending property deltas are dropped; rows already in the catalog
+-- keep theirs and new ones start without.
+INSERT INTO event_catalog_properties AS c (tenant_id, event_name, key, json_type, first_seen, last_seen, events)
+SELECT tenant_id, event_name, key, json_type, min(first_seen), max(last_seen), sum(events)
+FROM event_catalog_property_deltas
+GROUP BY 1, 2, 3, 4
+ON CONFLICT (tenant_id, event_name, key, json_type) DO UPDATE
+SET first_seen = LEAST(c.first_seen, EXCLUDED.first_seen),
+    last_seen = GREATEST(c.last_seen, EXCLUDED.last_seen),
+    events = c.events + EXCLUDED.events;
+
+DROP TABLE event_catalog_property_deltas;
+DROP TABLE event_catalog_deltas;
@@ -0,0 +1,31 @@
+-- Pending changes to event_catalog and event_catalog_properties. Statements
+-- writing events append the catalog entries of their events here (see
+-- catalogSQL) instead of upserting the catalog rows, so concurrent writers of
+-- the same event name never wait on each other's row locks; the catalog
+-- compactor folds them in and GET /events/catalog adds the pending ones.
+CREATE TABLE event_catalog_deltas (
+  id         BIGSERIAL   PRIMARY KEY,
+  tenant_id  TEXT        NOT NULL,
+  event_name TEXT        NOT NULL,
+  first_seen TIMESTAMPTZ NOT NULL,
+  last_seen  TIMESTAMPTZ NOT NULL,
+  events     BIGINT      NOT NULL
+);
+
+CREATE INDEX idx_event_catalog_deltas_scope
+  ON event_catalog_deltas(tenant_id, event_name);
+
+CREATE TABLE event_catalog_property_deltas (
+  id         BIGSERIAL   PRIMARY KEY,
+  tenant_id  TEXT        NOT NULL,
+  event_name TEXT        NOT NULL,
+  key        TEXT        NOT NULL,
+  json_type  TEXT        NOT NULL,
+  first_seen TIMESTAMPTZ NOT NULL,
+  last_seen  TIMESTAMPTZ NOT NULL,
+  events     BIGINT      NOT NULL,
+  samples    JSONB       NOT NULL DEFAULT '[]'::jsonb
+);
+
+CREATE INDEX idx_event_catalog_property_deltas_scope
+  ON event_catalog_property_deltas(tenant_id, event_name);
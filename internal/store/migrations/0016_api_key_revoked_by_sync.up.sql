@@ -0,0 +1,5 @@
+-- Whether revoked_at was set by the API_KEYS sync because the key was no longer
+-- listed, rather than through the admin API. Such a revocation is lifted when
+-- the key is listed again. Keys revoked before this column existed keep their
+-- revocation, since its origin is unknown.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS revoked_by_sync BOOLEAN NOT NULL DEFAULT false;
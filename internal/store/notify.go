@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Notify sends payload (at most ~8000 bytes) to the listeners of a Postgres
+// notification channel, on every replica.
+func (p *PostgresStore) Notify(ctx context.Context, channel, payload string) error {
+	_, err := p.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, payload)
+	return err
+}
+
+// Listen passes the payloads sent to channel to fn until ctx is cancelled or
+// the connection fails, and returns why it stopped. It takes a connection out
+// of the pool for as long as it runs.
+func (p *PostgresStore) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	pc, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A listening connection must not go back to the pool.
+	conn := pc.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, `LISTEN `+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
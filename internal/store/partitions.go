@@ -0,0 +1,284 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PartitionInterval is the time range covered by one events partition.
+// Partitions are aligned to UTC days or months.
+type PartitionInterval string
+
+// Supported partition intervals.
+const (
+	PartitionDaily   PartitionInterval = "day"
+	PartitionMonthly PartitionInterval = "month"
+)
+
+// partitionLockID is the pg_advisory_xact_lock key that serializes partition
+// maintenance across replicas.
+const partitionLockID = 0x6576_7061_7274
+
+// start returns the UTC start of the partition range containing t.
+func (pi PartitionInterval) start(t time.Time) time.Time {
+	t = t.UTC()
+	if pi == PartitionMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// next returns the start of the range following the one starting at start.
+func (pi PartitionInterval) next(start time.Time) time.Time {
+	if pi == PartitionMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// partitionName is e.g. events_p20261101 (daily) or events_p202611 (monthly).
+func (pi PartitionInterval) partitionName(start time.Time) string {
+	if pi == PartitionMonthly {
+		return "events_p" + start.Format("200601")
+	}
+	return "events_p" + start.Format("20060102")
+}
+
+// partitionRange is one row of event_partitions. A zero Start is unbounded below.
+type partitionRange struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+func (r partitionRange) overlaps(start, end time.Time) bool {
+	return (r.Start.IsZero() || r.Start.Before(end)) && start.Before(r.End)
+}
+
+// plannedPartitions returns the ranges for the current period and the next
+// ahead periods that do not overlap an existing partition. Ranges already
+// covered, e.g. by partitions of a previous interval setting, are skipped.
+func plannedPartitions(interval PartitionInterval, now time.Time, ahead int, existing []partitionRange) []partitionRange {
+	var out []partitionRange
+	start := interval.start(now)
+	for i := 0; i <= ahead; i++ {
+		end := interval.next(start)
+
+		free := true
+		for _, r := range existing {
+			if r.overlaps(start, end) {
+				free = false
+				break
+			}
+		}
+		if free {
+			out = append(out, partitionRange{Name: interval.partitionName(start), Start: start, End: end})
+		}
+		start = end
+	}
+	return out
+}
+
+// listPartitions reads event_partitions, oldest first.
+func listPartitions(ctx context.Context, tx pgx.Tx) ([]partitionRange, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT name, range_start, range_end
+		FROM event_partitions
+		ORDER BY range_end
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []partitionRange
+	for rows.Next() {
+		var (
+			r     partitionRange
+			start *time.Time
+		)
+		if err := rows.Scan(&r.Name, &start, &r.End); err != nil {
+			return nil, err
+		}
+		if start != nil {
+			r.Start = *start
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// EnsurePartitions creates the events partitions for the period containing now
+// and the following ahead periods, and returns the names created.
+//
+// Rows that landed in events_default for a new partition's range are moved into
+// it before it is attached, since Postgres refuses to attach a partition whose
+// range has rows in the default partition.
+func (p *PostgresStore) EnsurePartitions(
+	ctx context.Context,
+	interval PartitionInterval,
+	now time.Time,
+	ahead int,
+) ([]string, error) {
+
+	if interval != PartitionDaily && interval != PartitionMonthly {
+		return nil, errors.New("interval must be day or month")
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(partitionLockID)); err != nil {
+		return nil, err
+	}
+
+	existing, err := listPartitions(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for _, r := range plannedPartitions(interval, now, ahead, existing) {
+		name := pgx.Identifier{r.Name}.Sanitize()
+
+		if _, err := tx.Exec(ctx, `CREATE TABLE `+name+` (LIKE events INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+			WITH moved AS (
+				DELETE FROM events_default
+				WHERE ts >= $1 AND ts < $2
+				RETURNING tenant_id, event_id, event_name, ts, properties, ingested_at, user_id, anonymous_id, schema_errors
+			)
+			INSERT INTO `+name+`(tenant_id, event_id, event_name, ts, properties, ingested_at, user_id, anonymous_id, schema_errors)
+			SELECT * FROM moved
+		`, r.Start, r.End); err != nil {
+			return nil, err
+		}
+		// Bounds are formatted by us, not user input; DDL cannot take parameters.
+		if _, err := tx.Exec(ctx, fmt.Sprintf(
+			`ALTER TABLE events ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`,
+			name, r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339),
+		)); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO event_partitions(name, range_start, range_end) VALUES ($1, $2, $3)
+		`, r.Name, r.Start, r.End); err != nil {
+			return nil, err
+		}
+		created = append(created, r.Name)
+	}
+
+	return created, tx.Commit(ctx)
+}
+
+// ExpirePartitions removes the events partitions whose whole range ends at or
+// before cutoff and returns their names. With detach, partitions are detached
+// and kept as standalone tables (e.g. for archiving), recorded in
+// event_partitions_detached so erasures still reach them; otherwise they are
+// dropped.
+// Expired rows in events_default are deleted as well, and the rollups of all
+// removed events with them.
+//
+// Event ids are pruned separately (PruneEventIDs), in small batches.
+func (p *PostgresStore) ExpirePartitions(ctx context.Context, cutoff time.Time, detach bool) ([]string, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(partitionLockID)); err != nil {
+		return nil, err
+	}
+	if err := lockRollupsShared(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	existing, err := listPartitions(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []string
+	for _, r := range existing {
+		if r.End.After(cutoff) {
+			continue
+		}
+		name := pgx.Identifier{r.Name}.Sanitize()
+
+		stmt := `DROP TABLE ` + name
+		if detach {
+			stmt = `ALTER TABLE events DETACH PARTITION ` + name
+		}
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM event_partitions WHERE name = $1`, r.Name); err != nil {
+			return nil, err
+		}
+		if detach {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO event_partitions_detached(name, range_start, range_end)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (name) DO NOTHING
+			`, r.Name, nullTime(r.Start), r.End); err != nil {
+				return nil, err
+			}
+		}
+		if err := dropRollupRange(ctx, tx, r.Start, r.End, nil); err != nil {
+			return nil, err
+		}
+		expired = append(expired, r.Name)
+	}
+
+	var stray int64
+	if err := tx.QueryRow(ctx, `
+		WITH deleted AS (
+			DELETE FROM events_default WHERE ts < $1
+			RETURNING tenant_id, event_name, ts
+		), rolled AS (`+rollupDecrementSQL+`
+		)
+		SELECT count(*) FROM deleted`, cutoff).Scan(&stray); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	if len(expired) > 0 || stray > 0 {
+		p.notifyDeleted(ctx, "")
+	}
+	return expired, nil
+}
+
+// PruneEventIDs deletes idempotency records of events older than cutoff, batch
+// rows at a time, and returns how many were deleted. Once pruned, a replay of
+// such an event is accepted again, but it is older than retention and is
+// removed again by the next ExpirePartitions.
+func (p *PostgresStore) PruneEventIDs(ctx context.Context, cutoff time.Time, batch int) (int64, error) {
+	var total int64
+	for {
+		tag, err := p.pool.Exec(ctx, `
+			DELETE FROM event_ids
+			WHERE ctid = ANY(ARRAY(
+				SELECT ctid FROM event_ids WHERE ts < $1 LIMIT $2
+			))
+		`, cutoff, batch)
+		if err != nil {
+			return total, err
+		}
+		total += tag.RowsAffected()
+		if tag.RowsAffected() < int64(batch) {
+			return total, nil
+		}
+	}
+}
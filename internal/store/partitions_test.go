@@ -0,0 +1,38 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlannedPartitions(t *testing.T) {
+	now := time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC)
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 0, 0, 0, 0, time.UTC) }
+
+	names := func(rs []partitionRange) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	// The migrated history covers everything before November.
+	legacy := []partitionRange{{Name: "events_legacy", End: day(time.November, 1)}}
+
+	got := names(plannedPartitions(PartitionMonthly, now, 3, legacy))
+	want := []string{"events_p202611", "events_p202612", "events_p202701"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("monthly: got %v want %v", got, want)
+	}
+
+	// Switching to daily partitions skips days already covered by a monthly one.
+	existing := append(legacy, partitionRange{Name: "events_p202611", Start: day(time.November, 1), End: day(time.December, 1)})
+	now = time.Date(2026, 11, 29, 8, 0, 0, 0, time.UTC)
+	got = names(plannedPartitions(PartitionDaily, now, 4, existing))
+	want = []string{"events_p20261201", "events_p20261202", "events_p20261203"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("daily: got %v want %v", got, want)
+	}
+}
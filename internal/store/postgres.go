@@ -2,22 +2,23 @@ package store
 
 import (
 	"context"
-	_ "embed"
 	"encoding/json"
 	"errors"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-)
 
-// schemaSQL is embedded so the service can self-bootstrap its database schema.
-//
-//go:embed schema.sql
-var schemaSQL string
+	"github.com/PratikDhanave/event-analytics-service/internal/hll"
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+	"github.com/PratikDhanave/event-analytics-service/internal/tracing"
+)
 
 // PostgresStore is the durable persistence layer for events.
 type PostgresStore struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	onChange []ChangeHook
 }
 
 // NewPostgresStore creates a connection pool and fails fast if DB is unreachable.
@@ -25,7 +26,14 @@ func NewPostgresStore(dbURL string) (*PostgresStore, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	pool, err := pgxpool.New(ctx, dbURL)
+	cfg, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	// Queries run within a traced request or operation become child spans.
+	cfg.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -38,39 +46,50 @@ func NewPostgresStore(dbURL string) (*PostgresStore, error) {
 	return &PostgresStore{pool: pool}, nil
 }
 
-// EnsureSchema applies schema.sql. Safe to run multiple times.
-func (p *PostgresStore) EnsureSchema() error {
-	_, err := p.pool.Exec(context.Background(), schemaSQL)
-	return err
-}
-
 // Ping is used by readiness endpoint to validate DB connectivity.
 func (p *PostgresStore) Ping(ctx context.Context) error {
 	return p.pool.Ping(ctx)
 }
 
+// PoolStat returns a snapshot of the connection pool statistics.
+func (p *PostgresStore) PoolStat() *pgxpool.Stat {
+	return p.pool.Stat()
+}
+
 // Close shuts down the connection pool.
 func (p *PostgresStore) Close() {
 	p.pool.Close()
 }
 
+// Event is a validated event ready to be persisted for a tenant.
+// UserID and AnonymousID are optional actor identifiers; empty means unknown.
+type Event struct {
+	EventID     string
+	EventName   string
+	TS          time.Time
+	Properties  map[string]interface{}
+	UserID      string
+	AnonymousID string
+	// SchemaErrors are the schema violations of an event accepted by lenient
+	// validation, as a JSON array; nil when there are none.
+	SchemaErrors json.RawMessage
+}
+
 // InsertEvent persists an event and returns inserted=false when it is a duplicate.
 //
-// Duplicate detection is enforced by the database constraint on (tenant_id, event_id),
-// which is compatible with retries and at-least-once delivery.
-func (p *PostgresStore) InsertEvent(
-	ctx context.Context,
-	tenantID string,
-	eventID string,
-	eventName string,
-	ts time.Time,
-	properties map[string]interface{},
-) (bool, error) {
+// Duplicate detection is enforced by the primary key of event_ids on
+// (tenant_id, event_id): the event row is only written when its id could be
+// claimed there in the same statement, which is compatible with retries and
+// at-least-once delivery.
+func (p *PostgresStore) InsertEvent(ctx context.Context, tenantID string, e Event) (bool, error) {
+	ctx, o := startOp(ctx, "insert_event", tenantID, e.EventName)
+	defer o.end()
 
-	if tenantID == "" || eventID == "" || eventName == "" {
+	if tenantID == "" || e.EventID == "" || e.EventName == "" {
 		return false, errors.New("tenantID/eventID/eventName required")
 	}
 
+	properties := e.Properties
 	if properties == nil {
 		properties = map[string]interface{}{}
 	}
@@ -80,46 +99,424 @@ func (p *PostgresStore) InsertEvent(
 		return false, err
 	}
 
-	// RETURNING 1 only when inserted; duplicates return no rows.
+	// A row only when inserted; duplicates return no rows. The rollup and
+	// catalog deltas are appended in the same statement (see rollupDeltaSQL
+	// and catalogSQL).
 	var one int
 	err = p.pool.QueryRow(ctx, `
-		INSERT INTO events(tenant_id, event_id, event_name, ts, properties)
-		VALUES ($1,$2,$3,$4,$5)
-		ON CONFLICT (tenant_id, event_id) DO NOTHING
-		RETURNING 1
-	`, tenantID, eventID, eventName, ts, propsJSON).Scan(&one)
+		WITH claimed AS (
+			INSERT INTO event_ids(tenant_id, event_id, ts)
+			VALUES ($1::text, $2::text, $4::timestamptz)
+			ON CONFLICT (tenant_id, event_id) DO NOTHING
+			RETURNING 1
+		), written AS (
+			INSERT INTO events(tenant_id, event_id, event_name, ts, properties, user_id, anonymous_id, schema_errors)
+			SELECT $1::text, $2::text, $3::text, $4::timestamptz, $5::jsonb,
+			       NULLIF($6::text, ''), NULLIF($7::text, ''), NULLIF($8::text, '')::jsonb
+			FROM claimed
+			RETURNING tenant_id, event_name, ts, properties, user_id
+		), `+rollupDeltaSQL+`, `+catalogSQL+`
+		SELECT 1 FROM written
+	`, tenantID, e.EventID, e.EventName, e.TS, propsJSON, e.UserID, e.AnonymousID, string(e.SchemaErrors)).Scan(&one)
 
 	if err == nil {
+		telemetry.EventsWritten(1, 0)
+		p.notifyWritten(ctx, []writtenEvent{{TenantID: tenantID, EventName: e.EventName, TS: e.TS}})
 		return true, nil
 	}
 
 	// Conflict produces "no rows in result set" because RETURNING returns nothing.
 	if err.Error() == "no rows in result set" {
+		telemetry.EventsWritten(0, 1)
 		return false, nil
 	}
 
 	return false, err
 }
 
-// CountEvents returns the number of events for (tenantID, eventName) in the time window [from,to).
+// CountEvents returns the aggregate of the events matching q in the window [q.From,q.To):
+// the event count, or the (exact or estimated) number of distinct actors.
 // Using a half-open interval avoids double counting at window boundaries.
-func (p *PostgresStore) CountEvents(
-	ctx context.Context,
-	tenantID string,
-	eventName string,
-	from time.Time,
-	to time.Time,
-) (int64, error) {
+//
+// Plain event counts spanning at least one whole UTC hour are answered from the
+// hourly rollups, with raw scans for the edges (see rollupCountsSQL).
+func (p *PostgresStore) CountEvents(ctx context.Context, q MetricsQuery) (int64, error) {
+	ctx, o := startOp(ctx, "count_events", q.TenantID, q.EventName)
+	defer o.end()
+
+	var args argList
+	if start, end, ok := rollupSpan(q.From, q.To); ok && q.useRollups() {
+		var count int64
+		err := p.pool.QueryRow(ctx, `
+			SELECT COALESCE(SUM(cnt), 0)::bigint
+			FROM (`+rollupCountsSQL(q, start, end, func(string) string { return "0" }, &args)+`) c
+		`, args...).Scan(&count)
+		return count, err
+	}
+
+	where, err := q.where(&args)
+	if err != nil {
+		return 0, err
+	}
+
+	if q.Aggregate == AggregateUniqueUsersApprox {
+		var (
+			hsum float64
+			nz   int
+		)
+		err = p.pool.QueryRow(ctx, `
+			SELECT `+registerStatsSQL+`
+			FROM (`+registersSQL("", where)+`) r
+		`, args...).Scan(&hsum, &nz)
+		if err != nil {
+			return 0, err
+		}
+		return estimateActors(hsum, nz), nil
+	}
 
 	var count int64
-	err := p.pool.QueryRow(ctx, `
-		SELECT COUNT(*)
+	err = p.pool.QueryRow(ctx, `
+		SELECT `+q.valueExpr()+`
 		FROM events
-		WHERE tenant_id=$1
-		  AND event_name=$2
-		  AND ts >= $3
-		  AND ts <  $4
-	`, tenantID, eventName, from, to).Scan(&count)
+		WHERE `+where, args...).Scan(&count)
 
 	return count, err
 }
+
+// estimateActors turns register statistics into a rounded distinct-actor estimate.
+func estimateActors(hsum float64, nonZero int) int64 {
+	if nonZero == 0 {
+		return 0
+	}
+	return int64(math.Round(hll.EstimateFromRegisters(hll.Precision, hsum, nonZero)))
+}
+
+// eventColumns is a batch of events in column-oriented form, so a whole batch is
+// sent as one statement (unnest of arrays) with a fixed number of parameters.
+type eventColumns struct {
+	ids        []string
+	names      []string
+	tss        []time.Time
+	props      []string
+	userIDs    []string
+	anonIDs    []string
+	schemaErrs []string // "" when none
+}
+
+func newEventColumns(events []Event) (eventColumns, error) {
+	cols := eventColumns{
+		ids:     make([]string, len(events)),
+		names:   make([]string, len(events)),
+		tss:     make([]time.Time, len(events)),
+		props:   make([]string, len(events)),
+		userIDs: make([]string, len(events)),
+		anonIDs: make([]string, len(events)),
+
+		schemaErrs: make([]string, len(events)),
+	}
+
+	for i, e := range events {
+		if e.EventID == "" || e.EventName == "" {
+			return eventColumns{}, errors.New("eventID/eventName required")
+		}
+		properties := e.Properties
+		if properties == nil {
+			properties = map[string]interface{}{}
+		}
+		propsJSON, err := json.Marshal(properties)
+		if err != nil {
+			return eventColumns{}, err
+		}
+		cols.ids[i] = e.EventID
+		cols.names[i] = e.EventName
+		cols.tss[i] = e.TS
+		cols.props[i] = string(propsJSON)
+		cols.userIDs[i] = e.UserID
+		cols.anonIDs[i] = e.AnonymousID
+		cols.schemaErrs[i] = string(e.SchemaErrors)
+	}
+
+	return cols, nil
+}
+
+// InsertEvents persists a batch of events for one tenant in a single multi-row insert.
+//
+// It returns the set of event IDs that were newly inserted; any event ID in the batch
+// that is missing from the result already existed (duplicate). Callers should remove
+// repeated event IDs from the batch beforehand so each ID maps to exactly one row.
+func (p *PostgresStore) InsertEvents(
+	ctx context.Context,
+	tenantID string,
+	events []Event,
+) (map[string]bool, error) {
+
+	ctx, o := startOp(ctx, "insert_events", tenantID, batchEventName(events))
+	defer o.end()
+
+	if tenantID == "" {
+		return nil, errors.New("tenantID required")
+	}
+
+	inserted := make(map[string]bool, len(events))
+	if len(events) == 0 {
+		return inserted, nil
+	}
+
+	cols, err := newEventColumns(events)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same idempotency contract as InsertEvent: ids already claimed in event_ids are
+	// skipped and only the rows that were actually written are returned (and
+	// added to the rollup and catalog deltas).
+	rows, err := p.pool.Query(ctx, `
+		WITH e AS (
+			SELECT *
+			FROM unnest($2::text[], $3::text[], $4::timestamptz[], $5::text[], $6::text[], $7::text[], $8::text[])
+			  AS u(event_id, event_name, ts, properties, user_id, anonymous_id, schema_errors)
+		), claimed AS (
+			INSERT INTO event_ids(tenant_id, event_id, ts)
+			SELECT $1::text, event_id, ts FROM e
+			ON CONFLICT (tenant_id, event_id) DO NOTHING
+			RETURNING event_id
+		), written AS (
+			INSERT INTO events(tenant_id, event_id, event_name, ts, properties, user_id, anonymous_id, schema_errors)
+			SELECT $1::text, e.event_id, e.event_name, e.ts, e.properties::jsonb,
+			       NULLIF(e.user_id, ''), NULLIF(e.anonymous_id, ''), NULLIF(e.schema_errors, '')::jsonb
+			FROM e JOIN claimed USING (event_id)
+			RETURNING event_id, tenant_id, event_name, ts, properties, user_id
+		), `+rollupDeltaSQL+`, `+catalogSQL+`
+		SELECT event_id FROM written
+	`, tenantID, cols.ids, cols.names, cols.tss, cols.props, cols.userIDs, cols.anonIDs, cols.schemaErrs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		inserted[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	telemetry.EventsWritten(len(inserted), len(events)-len(inserted))
+
+	written := make([]writtenEvent, 0, len(inserted))
+	for _, e := range events {
+		if inserted[e.EventID] {
+			written = append(written, writtenEvent{TenantID: tenantID, EventName: e.EventName, TS: e.TS})
+		}
+	}
+	p.notifyWritten(ctx, written)
+
+	return inserted, nil
+}
+
+// SeriesBucket is one time bucket of a count series.
+type SeriesBucket struct {
+	Start time.Time
+	Count int64
+}
+
+// CountEventsSeries returns the per-bucket aggregate of the events matching q.
+//
+// unit is a date_trunc unit (minute, hour, day, week, month) and tz an IANA time
+// zone used for calendar alignment, so "day" buckets start at local midnight and
+// respect DST. Buckets are generated with generate_series, which zero-fills
+// buckets without events. The first and last buckets may start before q.From or
+// end after q.To; their counts are still clipped to [q.From,q.To).
+//
+// Plain event counts use the hourly rollups for whole UTC hours when every hour
+// falls into a single bucket, i.e. for hour or longer units in time zones that
+// are a whole number of hours from UTC.
+func (p *PostgresStore) CountEventsSeries(
+	ctx context.Context,
+	q MetricsQuery,
+	unit string,
+	tz string,
+) ([]SeriesBucket, error) {
+
+	ctx, o := startOp(ctx, "count_events_series", q.TenantID, q.EventName)
+	defer o.end()
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	var args argList
+	unitArg := args.add(unit)
+	tzArg := args.add(tz)
+	fromArg := args.add(q.From)
+	toArg := args.add(q.To)
+
+	localStart := func(ts string) string {
+		return "date_trunc(" + unitArg + ", " + ts + " AT TIME ZONE " + tzArg + ")"
+	}
+
+	// counts yields (local_start, cnt, hsum, nz); hsum/nz are only set for the
+	// approximate aggregate, which estimates cnt from HyperLogLog registers.
+	var counts string
+	start, end, ok := rollupSpan(q.From, q.To)
+	switch {
+	case ok && q.useRollups() && unit != "minute" && hourAligned(loc, q.From, q.To):
+		counts = `
+			SELECT key AS local_start, SUM(cnt)::bigint AS cnt, 0::float8 AS hsum, 0::bigint AS nz
+			FROM (` + rollupCountsSQL(q, start, end, localStart, &args) + `) c
+			GROUP BY 1`
+	default:
+		where, err := q.where(&args)
+		if err != nil {
+			return nil, err
+		}
+		counts = `
+			SELECT ` + localStart("ts") + ` AS local_start, ` + q.valueExpr() + ` AS cnt, 0::float8 AS hsum, 0::bigint AS nz
+			FROM events
+			WHERE ` + where + `
+			GROUP BY 1`
+		if q.Aggregate == AggregateUniqueUsersApprox {
+			counts = `
+			SELECT key AS local_start, 0::bigint AS cnt, ` + registerStatsSQL + `
+			FROM (` + registersSQL(localStart("ts"), where) + `) r
+			GROUP BY 1`
+		}
+	}
+
+	// Buckets are keyed by local wall-clock time (timestamp without time zone) and
+	// converted back at the end, so stepping by '1 day' or '1 month' follows the
+	// calendar of tz rather than the session time zone.
+	rows, err := p.pool.Query(ctx, `
+		WITH buckets AS (
+			SELECT g AS local_start
+			FROM generate_series(
+				date_trunc(`+unitArg+`, `+fromArg+`::timestamptz AT TIME ZONE `+tzArg+`),
+				(`+toArg+`::timestamptz - interval '1 microsecond') AT TIME ZONE `+tzArg+`,
+				('1 ' || `+unitArg+`)::interval
+			) AS g
+		), counts AS (`+counts+`
+		)
+		SELECT b.local_start AT TIME ZONE `+tzArg+`, COALESCE(c.cnt, 0), COALESCE(c.hsum, 0), COALESCE(c.nz, 0)
+		FROM buckets b
+		LEFT JOIN counts c USING (local_start)
+		ORDER BY b.local_start
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []SeriesBucket
+	for rows.Next() {
+		var (
+			b    SeriesBucket
+			hsum float64
+			nz   int
+		)
+		if err := rows.Scan(&b.Start, &b.Count, &hsum, &nz); err != nil {
+			return nil, err
+		}
+		if q.Aggregate == AggregateUniqueUsersApprox {
+			b.Count = estimateActors(hsum, nz)
+		}
+		series = append(series, b)
+	}
+
+	return series, rows.Err()
+}
+
+// GroupCount is the count for one distinct combination of grouped property values.
+// Values has one entry per group key, in key order; nil means the property was absent.
+type GroupCount struct {
+	Values []*string
+	Count  int64
+}
+
+// CountEventsGrouped returns the aggregate of the events matching q grouped by the
+// given top-level property keys.
+//
+// Only the limit largest groups are returned (ties broken by value); the summed values
+// of all remaining groups are returned as other. For unique users an actor seen in
+// several groups counts once per group, so other is an upper bound there. Property
+// values are compared as text (properties ->> key), so 1 and "1" fall into the same group.
+func (p *PostgresStore) CountEventsGrouped(
+	ctx context.Context,
+	q MetricsQuery,
+	keys []string,
+	limit int,
+) ([]GroupCount, int64, error) {
+
+	ctx, o := startOp(ctx, "count_events_grouped", q.TenantID, q.EventName)
+	defer o.end()
+
+	if len(keys) == 0 {
+		return nil, 0, errors.New("at least one group key required")
+	}
+	if q.Aggregate == AggregateUniqueUsersApprox {
+		return nil, 0, errors.New("approximate unique users cannot be grouped")
+	}
+
+	var args argList
+	where, err := q.where(&args)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	exprs := make([]string, len(keys))
+	for i, k := range keys {
+		exprs[i] = "properties ->> " + args.add(k)
+	}
+	limitArg := args.add(limit)
+
+	rows, err := p.pool.Query(ctx, `
+		WITH grouped AS (
+			SELECT ARRAY[`+strings.Join(exprs, ", ")+`]::text[] AS vals, `+q.valueExpr()+` AS cnt
+			FROM events
+			WHERE `+where+`
+			GROUP BY 1
+		), ranked AS (
+			SELECT vals, cnt, row_number() OVER (ORDER BY cnt DESC, vals) AS rn
+			FROM grouped
+		)
+		SELECT vals, cnt, FALSE AS other, rn
+		FROM ranked
+		WHERE rn <= `+limitArg+`
+		UNION ALL
+		SELECT NULL, COALESCE(SUM(cnt), 0), TRUE, NULL
+		FROM ranked
+		WHERE rn > `+limitArg+`
+		ORDER BY rn NULLS LAST
+	`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var (
+		groups []GroupCount
+		other  int64
+	)
+	for rows.Next() {
+		var (
+			vals    []*string
+			cnt     int64
+			isOther bool
+			rank    *int64
+		)
+		if err := rows.Scan(&vals, &cnt, &isOther, &rank); err != nil {
+			return nil, 0, err
+		}
+		if isOther {
+			other = cnt
+			continue
+		}
+		groups = append(groups, GroupCount{Values: vals, Count: cnt})
+	}
+
+	return groups, other, rows.Err()
+}
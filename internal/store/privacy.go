@@ -0,0 +1,562 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPrivacyJobNotFound is returned when no privacy_jobs row has the requested id.
+var ErrPrivacyJobNotFound = errors.New("privacy job not found")
+
+// Privacy job kinds and statuses.
+const (
+	PrivacyErase  = "erase"
+	PrivacyExport = "export"
+
+	PrivacyPending   = "pending"
+	PrivacyRunning   = "running"
+	PrivacySucceeded = "succeeded"
+	PrivacyFailed    = "failed"
+	PrivacyExpired   = "expired" // export output removed after ExpiresAt
+)
+
+// exportChunkSize is the approximate size of one privacy_exports row.
+const exportChunkSize = 1 << 20
+
+// PrivacyJob is an erasure or export request for one user of a tenant.
+type PrivacyJob struct {
+	ID          string
+	Kind        string
+	TenantID    string
+	UserID      string
+	Status      string
+	RequestedBy string
+	Events      int64 // events erased or exported
+	Error       string
+	CreatedAt   time.Time
+	StartedAt   *time.Time
+	FinishedAt  *time.Time
+	ExpiresAt   *time.Time
+}
+
+// PrivacyAudit is one entry of the privacy audit trail.
+type PrivacyAudit struct {
+	ID       int64
+	JobID    string
+	TenantID string
+	UserID   string
+	Action   string
+	Actor    string
+	Detail   string
+	At       time.Time
+}
+
+const privacyJobColumns = `id, kind, tenant_id, user_id, status, requested_by, events,
+	COALESCE(error, ''), created_at, started_at, finished_at, expires_at`
+
+func scanPrivacyJob(row pgx.Row) (PrivacyJob, error) {
+	var j PrivacyJob
+	err := row.Scan(&j.ID, &j.Kind, &j.TenantID, &j.UserID, &j.Status, &j.RequestedBy, &j.Events,
+		&j.Error, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return PrivacyJob{}, ErrPrivacyJobNotFound
+	}
+	return j, err
+}
+
+// appendPrivacyAudit records an audit entry for job inside tx.
+func appendPrivacyAudit(ctx context.Context, tx pgx.Tx, j PrivacyJob, action, actor, detail string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO privacy_audit(job_id, tenant_id, user_id, action, actor, detail)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`, j.ID, j.TenantID, j.UserID, action, actor, detail)
+	return err
+}
+
+// CreatePrivacyJob stores a pending job and its "requested" audit entry.
+func (p *PostgresStore) CreatePrivacyJob(ctx context.Context, j PrivacyJob) (PrivacyJob, error) {
+	if j.ID == "" || j.TenantID == "" || j.UserID == "" {
+		return PrivacyJob{}, errors.New("id/tenantID/userID required")
+	}
+	if j.Kind != PrivacyErase && j.Kind != PrivacyExport {
+		return PrivacyJob{}, errors.New("kind must be erase or export")
+	}
+
+	var created PrivacyJob
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var err error
+		created, err = scanPrivacyJob(tx.QueryRow(ctx, `
+			INSERT INTO privacy_jobs(id, kind, tenant_id, user_id, requested_by)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+privacyJobColumns,
+			j.ID, j.Kind, j.TenantID, j.UserID, j.RequestedBy))
+		if err != nil {
+			return err
+		}
+		return appendPrivacyAudit(ctx, tx, created, j.Kind+"_requested", j.RequestedBy, "")
+	})
+	return created, err
+}
+
+// GetPrivacyJob returns a job. A non-empty tenantID restricts the lookup to
+// that tenant's jobs.
+func (p *PostgresStore) GetPrivacyJob(ctx context.Context, id, tenantID string) (PrivacyJob, error) {
+	return scanPrivacyJob(p.pool.QueryRow(ctx, `
+		SELECT `+privacyJobColumns+`
+		FROM privacy_jobs
+		WHERE id = $1 AND ($2 = '' OR tenant_id = $2)
+	`, id, tenantID))
+}
+
+// ListPrivacyJobs returns the jobs of a tenant, newest first, optionally only
+// those of one user.
+func (p *PostgresStore) ListPrivacyJobs(ctx context.Context, tenantID, userID string) ([]PrivacyJob, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+privacyJobColumns+`
+		FROM privacy_jobs
+		WHERE tenant_id = $1 AND ($2 = '' OR user_id = $2)
+		ORDER BY created_at DESC
+		LIMIT 1000
+	`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PrivacyJob
+	for rows.Next() {
+		j, err := scanPrivacyJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
+
+// ClaimPrivacyJob marks the oldest pending job as running and returns it, or
+// ok=false when there is none. A running job whose heartbeat is older than stale
+// is claimed again, so work left behind by a crashed replica is resumed; both
+// job kinds are safe to repeat.
+func (p *PostgresStore) ClaimPrivacyJob(ctx context.Context, stale time.Duration, actor string) (PrivacyJob, bool, error) {
+	var j PrivacyJob
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var err error
+		j, err = scanPrivacyJob(tx.QueryRow(ctx, `
+			UPDATE privacy_jobs
+			SET status = 'running', started_at = COALESCE(started_at, now()), heartbeat_at = now()
+			WHERE id = (
+				SELECT id FROM privacy_jobs
+				WHERE status = 'pending'
+				   OR (status = 'running' AND heartbeat_at < now() - ($1::bigint * interval '1 microsecond'))
+				ORDER BY created_at
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING `+privacyJobColumns,
+			stale.Microseconds()))
+		if err != nil {
+			return err
+		}
+		return appendPrivacyAudit(ctx, tx, j, j.Kind+"_started", actor, "")
+	})
+	if errors.Is(err, ErrPrivacyJobNotFound) {
+		return PrivacyJob{}, false, nil
+	}
+	return j, err == nil, err
+}
+
+// FinishPrivacyJob records the outcome of a running job: succeeded with the
+// number of events handled, or failed with jobErr. expiresAt is set on exports.
+func (p *PostgresStore) FinishPrivacyJob(
+	ctx context.Context,
+	j PrivacyJob,
+	events int64,
+	jobErr error,
+	expiresAt *time.Time,
+	actor string,
+) error {
+
+	status, action, detail := PrivacySucceeded, j.Kind+"_succeeded", ""
+	if jobErr != nil {
+		status, action, detail = PrivacyFailed, j.Kind+"_failed", jobErr.Error()
+	}
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+			UPDATE privacy_jobs
+			SET status = $2, events = $3, error = NULLIF($4, ''), finished_at = now(), expires_at = $5
+			WHERE id = $1
+		`, j.ID, status, events, detail, expiresAt); err != nil {
+			return err
+		}
+		if jobErr == nil {
+			detail = "events=" + strconv.FormatInt(events, 10)
+		}
+		return appendPrivacyAudit(ctx, tx, j, action, actor, detail)
+	})
+}
+
+// AppendPrivacyAudit records an audit entry for job outside of a state change,
+// e.g. an export download.
+func (p *PostgresStore) AppendPrivacyAudit(ctx context.Context, j PrivacyJob, action, actor, detail string) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		return appendPrivacyAudit(ctx, tx, j, action, actor, detail)
+	})
+}
+
+// ListPrivacyAudit returns the audit trail of a tenant, oldest first,
+// optionally only that of one user.
+func (p *PostgresStore) ListPrivacyAudit(ctx context.Context, tenantID, userID string) ([]PrivacyAudit, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, job_id, tenant_id, user_id, action, actor, COALESCE(detail, ''), at
+		FROM privacy_audit
+		WHERE tenant_id = $1 AND ($2 = '' OR user_id = $2)
+		ORDER BY id
+		LIMIT 10000
+	`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PrivacyAudit
+	for rows.Next() {
+		var a PrivacyAudit
+		if err := rows.Scan(&a.ID, &a.JobID, &a.TenantID, &a.UserID, &a.Action, &a.Actor, &a.Detail, &a.At); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// heartbeat keeps a running job from being considered abandoned.
+func (p *PostgresStore) heartbeat(ctx context.Context, jobID string) error {
+	_, err := p.pool.Exec(ctx, `UPDATE privacy_jobs SET heartbeat_at = now() WHERE id = $1`, jobID)
+	return err
+}
+
+// EraseUserEvents deletes every stored event of a tenant's user, batch rows per
+// statement, and returns how many were deleted. Their idempotency records and
+// rollup counts, queued and dead-lettered events, the user's sample values in
+// the event catalog, and the output of earlier exports for the user are removed
+// as well, so metrics no longer count the user once it returns. Partitions
+// detached by PARTITION_EXPIRE_MODE=detach are erased too.
+func (p *PostgresStore) EraseUserEvents(ctx context.Context, jobID, tenantID, userID string, batch int) (int64, error) {
+	var total int64
+	for {
+		var args argList
+		where := "tenant_id = " + args.add(tenantID) + " AND user_id = " + args.add(userID)
+
+		n, err := p.deleteEventBatch(ctx, where, args, batch)
+		total += n
+		if n > 0 {
+			p.notifyDeleted(ctx, tenantID)
+		}
+		if err != nil {
+			return total, err
+		}
+		if n < int64(batch) {
+			break
+		}
+		if err := p.heartbeat(ctx, jobID); err != nil {
+			return total, err
+		}
+	}
+
+	n, err := p.eraseDetachedEvents(ctx, jobID, tenantID, userID, batch)
+	total += n
+	if err != nil {
+		return total, err
+	}
+
+	err = pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM event_queue WHERE tenant_id = $1 AND user_id = $2`, tenantID, userID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM event_dead_letters WHERE tenant_id = $1 AND user_id = $2`, tenantID, userID); err != nil {
+			return err
+		}
+		if err := eraseCatalogSamples(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM privacy_exports x
+			USING privacy_jobs j
+			WHERE x.job_id = j.id AND j.tenant_id = $1 AND j.user_id = $2
+		`, tenantID, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE privacy_jobs SET status = 'expired'
+			WHERE tenant_id = $1 AND user_id = $2 AND kind = 'export' AND status = 'succeeded'
+		`, tenantID, userID)
+		return err
+	})
+	return total, err
+}
+
+// eraseDetachedEvents deletes a tenant's user events from the detached
+// partitions, batch rows per statement, and returns how many were deleted.
+// Their rollups and idempotency records went when they were detached.
+// Detached partitions dropped since are forgotten.
+func (p *PostgresStore) eraseDetachedEvents(ctx context.Context, jobID, tenantID, userID string, batch int) (int64, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT name FROM event_partitions_detached
+		WHERE to_regclass(name) IS NOT NULL
+		ORDER BY name
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, name := range names {
+		table := pgx.Identifier{name}.Sanitize()
+		for {
+			tag, err := p.pool.Exec(ctx, `
+				DELETE FROM `+table+`
+				WHERE ctid IN (
+					SELECT ctid FROM `+table+`
+					WHERE tenant_id = $1 AND user_id = $2
+					LIMIT $3
+				)
+			`, tenantID, userID, batch)
+			if err != nil {
+				return total, err
+			}
+			total += tag.RowsAffected()
+			if tag.RowsAffected() < int64(batch) {
+				break
+			}
+			if err := p.heartbeat(ctx, jobID); err != nil {
+				return total, err
+			}
+		}
+	}
+
+	_, err = p.pool.Exec(ctx, `DELETE FROM event_partitions_detached WHERE to_regclass(name) IS NULL`)
+	return total, err
+}
+
+// exportedEvent is one line of an export: the ingestion payload plus where the
+// event is (stored, queued or dead_lettered) and, once stored, the server-side
+// ingestion time.
+type exportedEvent struct {
+	EventID     string          `json:"event_id"`
+	EventName   string          `json:"event_name"`
+	Timestamp   string          `json:"timestamp"`
+	Properties  json.RawMessage `json:"properties"`
+	UserID      string          `json:"user_id"`
+	AnonymousID string          `json:"anonymous_id,omitempty"`
+	Status      string          `json:"status"`
+	IngestedAt  string          `json:"ingested_at,omitempty"`
+}
+
+// exportSource is a table holding events of a user, with how its rows are
+// paged and exported.
+type exportSource struct {
+	table    string // sanitized table name
+	key      string // unique per ts, the keyset tiebreaker
+	ingested string // ingestion time, NULL when not stored yet
+	status   string
+}
+
+// exportSources lists the tables EraseUserEvents deletes a user's events
+// from: events, the detached partitions that still exist, the queue and the
+// dead letters.
+func (p *PostgresStore) exportSources(ctx context.Context) ([]exportSource, error) {
+	sources := []exportSource{{table: "events", key: "event_id", ingested: "ingested_at", status: "stored"}}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT name FROM event_partitions_detached
+		WHERE to_regclass(name) IS NOT NULL
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		sources = append(sources, exportSource{
+			table: pgx.Identifier{name}.Sanitize(), key: "event_id", ingested: "ingested_at", status: "stored",
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return append(sources,
+		exportSource{table: "event_queue", key: "id::text", ingested: "NULL::timestamptz", status: "queued"},
+		exportSource{table: "event_dead_letters", key: "id::text", ingested: "NULL::timestamptz", status: "dead_lettered"},
+	), nil
+}
+
+// ExportUserEvents writes every event of a tenant's user to the job's export as
+// JSONL and returns how many were written: stored events, those in detached
+// partitions, and queued and dead-lettered ones, i.e. everything EraseUserEvents
+// deletes. Each source is written oldest first. Output of a previous attempt of
+// the same job is replaced.
+func (p *PostgresStore) ExportUserEvents(ctx context.Context, jobID, tenantID, userID string, batch int) (int64, error) {
+	if _, err := p.pool.Exec(ctx, `DELETE FROM privacy_exports WHERE job_id = $1`, jobID); err != nil {
+		return 0, err
+	}
+
+	sources, err := p.exportSources(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		total int64
+		seq   int
+		buf   bytes.Buffer
+	)
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		if _, err := p.pool.Exec(ctx, `
+			INSERT INTO privacy_exports(job_id, seq, data) VALUES ($1, $2, $3)
+		`, jobID, seq, buf.Bytes()); err != nil {
+			return err
+		}
+		seq++
+		buf.Reset()
+		return p.heartbeat(ctx, jobID)
+	}
+
+	for _, src := range sources {
+		var (
+			afterTS  time.Time
+			afterKey string
+			first    = true
+		)
+		for {
+			// Keyset pagination on (ts, key) keeps each page cheap.
+			rows, err := p.pool.Query(ctx, `
+				SELECT event_id, event_name, ts, properties, COALESCE(anonymous_id, ''),
+				       `+src.ingested+`, `+src.key+`
+				FROM `+src.table+`
+				WHERE tenant_id = $1 AND user_id = $2
+				  AND ($3 OR (ts, `+src.key+`) > ($4, $5))
+				ORDER BY ts, `+src.key+`
+				LIMIT $6
+			`, tenantID, userID, first, afterTS, afterKey, batch)
+			if err != nil {
+				return total, err
+			}
+
+			n := 0
+			for rows.Next() {
+				var (
+					e      exportedEvent
+					ts     time.Time
+					ingest *time.Time
+				)
+				if err := rows.Scan(&e.EventID, &e.EventName, &ts, &e.Properties, &e.AnonymousID, &ingest, &afterKey); err != nil {
+					rows.Close()
+					return total, err
+				}
+				e.UserID = userID
+				e.Status = src.status
+				e.Timestamp = ts.UTC().Format(time.RFC3339Nano)
+				if ingest != nil {
+					e.IngestedAt = ingest.UTC().Format(time.RFC3339Nano)
+				}
+
+				line, err := json.Marshal(e)
+				if err != nil {
+					rows.Close()
+					return total, err
+				}
+				buf.Write(line)
+				buf.WriteByte('\n')
+
+				afterTS = ts
+				n++
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return total, err
+			}
+			total += int64(n)
+			first = false
+
+			if buf.Len() >= exportChunkSize {
+				if err := flush(); err != nil {
+					return total, err
+				}
+			}
+			if n < batch {
+				break
+			}
+		}
+	}
+	return total, flush()
+}
+
+// StreamPrivacyExport passes the export of a job to fn chunk by chunk, in order.
+func (p *PostgresStore) StreamPrivacyExport(ctx context.Context, jobID string, fn func([]byte) error) error {
+	for seq := 0; ; seq++ {
+		var data []byte
+		err := p.pool.QueryRow(ctx, `
+			SELECT data FROM privacy_exports WHERE job_id = $1 AND seq = $2
+		`, jobID, seq).Scan(&data)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+}
+
+// ExpirePrivacyExports removes export output past its expires_at, including
+// partial output of failed exports, and returns how many succeeded jobs expired.
+func (p *PostgresStore) ExpirePrivacyExports(ctx context.Context, now time.Time) (int64, error) {
+	var n int64
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM privacy_exports x
+			USING privacy_jobs j
+			WHERE x.job_id = j.id AND j.status IN ('succeeded', 'failed') AND j.expires_at <= $1
+		`, now); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE privacy_jobs SET status = 'expired'
+			WHERE status = 'succeeded' AND expires_at <= $1
+		`, now)
+		n = tag.RowsAffected()
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ListEventTenants returns every tenant that has stored events.
+//
+// It walks the (tenant_id, event_name, ts) index with a recursive skip scan, so
+// the cost grows with the number of tenants rather than the number of events.
+func (p *PostgresStore) ListEventTenants(ctx context.Context) ([]string, error) {
+	rows, err := p.pool.Query(ctx, `
+		WITH RECURSIVE t AS (
+			(SELECT tenant_id FROM events ORDER BY tenant_id LIMIT 1)
+			UNION ALL
+			SELECT (SELECT e.tenant_id FROM events e WHERE e.tenant_id > t.tenant_id ORDER BY e.tenant_id LIMIT 1)
+			FROM t
+			WHERE t.tenant_id IS NOT NULL
+		)
+		SELECT tenant_id FROM t WHERE tenant_id IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// ExpiredPartition is an events partition whose rows are all past retention.
+type ExpiredPartition struct {
+	Name    string
+	Start   time.Time // zero: unbounded below
+	End     time.Time
+	Events  int64
+	Tenants []string // tenants whose events are expired across the whole range
+}
+
+// ExpiredPartitions returns the partitions that only hold events of tenants
+// whose cutoff is at or after the partition's end, i.e. partitions that can be
+// dropped without touching data that must be kept. cutoffs maps tenantID to the
+// time before which that tenant's events expire; tenants missing from it keep
+// their events, so any of their rows pins the partition.
+func (p *PostgresStore) ExpiredPartitions(ctx context.Context, cutoffs map[string]time.Time) ([]ExpiredPartition, error) {
+	var latest time.Time
+	for _, c := range cutoffs {
+		if c.After(latest) {
+			latest = c
+		}
+	}
+	if latest.IsZero() {
+		return nil, nil
+	}
+
+	tx, err := p.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	parts, err := listPartitions(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []ExpiredPartition
+	for _, r := range parts {
+		if r.End.After(latest) {
+			continue
+		}
+
+		expired := []string{}
+		for tenantID, c := range cutoffs {
+			if !c.Before(r.End) {
+				expired = append(expired, tenantID)
+			}
+		}
+
+		pinned, err := partitionPinned(ctx, tx, r.Name, expired)
+		if err != nil {
+			return nil, err
+		}
+		if pinned {
+			continue
+		}
+
+		var n int64
+		if err := tx.QueryRow(ctx, `SELECT count(*) FROM `+pgx.Identifier{r.Name}.Sanitize()).Scan(&n); err != nil {
+			return nil, err
+		}
+		out = append(out, ExpiredPartition{Name: r.Name, Start: r.Start, End: r.End, Events: n, Tenants: expired})
+	}
+	return out, nil
+}
+
+// partitionPinned reports whether the partition holds a row of a tenant outside expired.
+func partitionPinned(ctx context.Context, tx pgx.Tx, name string, expired []string) (bool, error) {
+	var pinned bool
+	err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM `+pgx.Identifier{name}.Sanitize()+` WHERE tenant_id <> ALL($1::text[]))
+	`, expired).Scan(&pinned)
+	return pinned, err
+}
+
+// DropEventPartition drops a partition found by ExpiredPartitions together with
+// the idempotency records and rollup counts of its events, and reports whether
+// it did.
+//
+// The partition is locked and checked again first, so it is kept when a late
+// event of a tenant outside part.Tenants arrived in the meantime, or when the
+// partition maintainer already removed it.
+func (p *PostgresStore) DropEventPartition(ctx context.Context, part ExpiredPartition) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(partitionLockID)); err != nil {
+		return false, err
+	}
+	if err := lockRollupsShared(ctx, tx); err != nil {
+		return false, err
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM event_partitions WHERE name = $1`, part.Name)
+	if err != nil || tag.RowsAffected() == 0 {
+		return false, err
+	}
+
+	ident := pgx.Identifier{part.Name}.Sanitize()
+	if _, err := tx.Exec(ctx, `LOCK TABLE `+ident+` IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return false, err
+	}
+	pinned, err := partitionPinned(ctx, tx, part.Name, part.Tenants)
+	if err != nil || pinned {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM event_ids i
+		USING `+ident+` e
+		WHERE i.tenant_id = e.tenant_id AND i.event_id = e.event_id
+	`); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `DROP TABLE `+ident); err != nil {
+		return false, err
+	}
+	if err := dropRollupRange(ctx, tx, part.Start, part.End, part.Tenants); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	for _, t := range part.Tenants {
+		p.notifyDeleted(ctx, t)
+	}
+	return true, nil
+}
+
+// CountExpiredEvents returns how many events of a tenant are older than cutoff,
+// leaving out the partitions named in skip (e.g. those about to be dropped).
+func (p *PostgresStore) CountExpiredEvents(ctx context.Context, tenantID string, cutoff time.Time, skip []string) (int64, error) {
+	if skip == nil {
+		skip = []string{}
+	}
+	var n int64
+	err := p.pool.QueryRow(ctx, `
+		SELECT count(*)
+		FROM events
+		WHERE tenant_id = $1 AND ts < $2 AND tableoid::regclass::text <> ALL($3::text[])
+	`, tenantID, cutoff, skip).Scan(&n)
+	return n, err
+}
+
+// PurgeExpiredEvents deletes a tenant's events older than cutoff, batch rows per
+// statement so no transaction holds many row locks for long, and returns how
+// many were deleted. Their idempotency records, rollup counts and dead letters
+// go with them.
+func (p *PostgresStore) PurgeExpiredEvents(ctx context.Context, tenantID string, cutoff time.Time, batch int) (int64, error) {
+	var total int64
+	for {
+		var args argList
+		where := "tenant_id = " + args.add(tenantID) + " AND ts < " + args.add(cutoff)
+
+		n, err := p.deleteEventBatch(ctx, where, args, batch)
+		total += n
+		if n > 0 {
+			p.notifyDeleted(ctx, tenantID)
+		}
+		if err != nil {
+			return total, err
+		}
+		if n < int64(batch) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		DELETE FROM event_dead_letters WHERE tenant_id = $1 AND ts < $2
+	`, tenantID, cutoff)
+	return total, err
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/filter"
+	"github.com/PratikDhanave/event-analytics-service/internal/hll"
+)
+
+// argList accumulates positional query arguments for dynamically shaped queries.
+//
+// Only placeholders produced by add are ever spliced into SQL text; every
+// caller-supplied value (including JSONB property keys) travels as an argument.
+type argList []any
+
+// add appends v and returns its placeholder ("$1", "$2", ...).
+func (a *argList) add(v any) string {
+	*a = append(*a, v)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// Aggregate selects what a metrics query computes per result row.
+type Aggregate string
+
+// Supported aggregates. The zero value counts events.
+const (
+	AggregateCount             Aggregate = ""
+	AggregateUniqueUsers       Aggregate = "unique_users"
+	AggregateUniqueUsersApprox Aggregate = "unique_users_approx"
+)
+
+// actorExpr identifies who performed an event: the user when known, otherwise
+// the anonymous device/session. Events with neither are not counted as actors.
+const actorExpr = "COALESCE(user_id, anonymous_id)"
+
+// MetricsQuery selects the events a metrics query aggregates over:
+// one tenant, one event name, the window [From,To) and an optional property filter.
+// Aggregate chooses between counting events and counting distinct actors.
+type MetricsQuery struct {
+	TenantID  string
+	EventName string
+	From      time.Time
+	To        time.Time
+	Filter    filter.Expr
+	Aggregate Aggregate
+}
+
+// where renders the WHERE clause body for q against the events table.
+func (q MetricsQuery) where(args *argList) (string, error) {
+	clauses := []string{
+		"tenant_id = " + args.add(q.TenantID),
+		"event_name = " + args.add(q.EventName),
+		"ts >= " + args.add(q.From),
+		"ts < " + args.add(q.To),
+	}
+
+	if q.Aggregate != AggregateCount {
+		clauses = append(clauses, actorExpr+" IS NOT NULL")
+	}
+
+	if q.Filter != nil {
+		f, err := compileFilter(q.Filter, args)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, f)
+	}
+
+	return strings.Join(clauses, " AND "), nil
+}
+
+// valueExpr is the SQL aggregate for q over matching event rows.
+// It is not defined for AggregateUniqueUsersApprox, which goes through registersSQL.
+func (q MetricsQuery) valueExpr() string {
+	if q.Aggregate == AggregateUniqueUsers {
+		return "COUNT(DISTINCT " + actorExpr + ")"
+	}
+	return "COUNT(*)"
+}
+
+// registersSQL builds a query computing HyperLogLog registers of the actors matching
+// where, optionally per key (keyExpr over events columns, exposed as "key").
+//
+// Each row is one non-empty register (reg) with its rank (rho), using the layout
+// documented on hll.Precision: the low hll.Precision bits of a 64-bit hash pick the
+// register and rho is one more than the leading zeros of the remaining bits.
+// Casting the shifted hash to bit(n) keeps exactly those n bits.
+func registersSQL(keyExpr, where string) string {
+	p := hll.Precision
+	q := 64 - p
+
+	key, groupBy := "", "1"
+	if keyExpr != "" {
+		key, groupBy = keyExpr+" AS key, ", "1, 2"
+	}
+
+	return fmt.Sprintf(`
+		SELECT %[1]sh & %[2]d AS reg,
+		       MAX(%[3]d - length(ltrim((h >> %[4]d)::bit(%[5]d)::text, '0'))) AS rho
+		FROM (
+			SELECT *, hashtextextended(%[6]s, 0) AS h
+			FROM events
+			WHERE %[7]s
+		) e
+		GROUP BY %[8]s`,
+		key, 1<<p-1, q+1, p, q, actorExpr, where, groupBy,
+	)
+}
+
+// registerStatsSQL summarizes register rows into the inputs of hll.EstimateFromRegisters.
+const registerStatsSQL = "COALESCE(SUM(power(2::float8, -rho)), 0) AS hsum, COUNT(*) AS nz"
+
+// compileFilter translates a parsed filter into a SQL predicate over properties.
+//
+// Semantics:
+//   - = compares JSON values, so 'web' never matches the number 1 or a missing key
+//   - != also matches events where the property is missing
+//   - in is = against any listed value
+//   - exists matches any present key, including JSON null
+//   - >, >=, <, <= only match numeric properties
+//   - contains is a substring match on strings and an element match on arrays
+func compileFilter(e filter.Expr, args *argList) (string, error) {
+	switch n := e.(type) {
+	case filter.And:
+		return compileTerms(n.Terms, " AND ", args)
+	case filter.Or:
+		return compileTerms(n.Terms, " OR ", args)
+	case filter.Not:
+		x, err := compileFilter(n.X, args)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + x, nil
+	case filter.Predicate:
+		return compilePredicate(n, args)
+	default:
+		return "", fmt.Errorf("unsupported filter node %T", e)
+	}
+}
+
+func compileTerms(terms []filter.Expr, sep string, args *argList) (string, error) {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		s, err := compileFilter(t, args)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = s
+	}
+	return "(" + strings.Join(parts, sep) + ")", nil
+}
+
+func compilePredicate(p filter.Predicate, args *argList) (string, error) {
+	path := args.add(p.Path)
+	value := "(properties #> " + path + "::text[])"
+
+	switch p.Op {
+	case filter.OpExists:
+		return "(" + value + " IS NOT NULL)", nil
+
+	case filter.OpEq, filter.OpNeq:
+		v, err := jsonArg(p.Values[0], args)
+		if err != nil {
+			return "", err
+		}
+		if p.Op == filter.OpEq {
+			return "(" + value + " = " + v + ")", nil
+		}
+		return "(" + value + " IS DISTINCT FROM " + v + ")", nil
+
+	case filter.OpIn:
+		list := make([]string, len(p.Values))
+		for i, raw := range p.Values {
+			b, err := json.Marshal(raw)
+			if err != nil {
+				return "", err
+			}
+			list[i] = string(b)
+		}
+		return "(" + value + " = ANY(" + args.add(list) + "::text[]::jsonb[]))", nil
+
+	case filter.OpGt, filter.OpGte, filter.OpLt, filter.OpLte:
+		n, ok := p.Values[0].(json.Number)
+		if !ok {
+			return "", fmt.Errorf("operator %s requires a number", p.Op)
+		}
+		// CASE guards the cast: non-numeric values yield NULL instead of a cast error.
+		return fmt.Sprintf(
+			"(CASE WHEN jsonb_typeof(%s) = 'number' THEN (properties #>> %s::text[])::numeric END %s %s::numeric)",
+			value, path, p.Op, args.add(n.String()),
+		), nil
+
+	case filter.OpContains:
+		elem, err := jsonArg(p.Values[0], args)
+		if err != nil {
+			return "", err
+		}
+		substr := "FALSE"
+		if s, ok := p.Values[0].(string); ok {
+			substr = "strpos(properties #>> " + path + "::text[], " + args.add(s) + ") > 0"
+		}
+		return fmt.Sprintf(
+			"(CASE jsonb_typeof(%s) WHEN 'string' THEN %s WHEN 'array' THEN %s @> jsonb_build_array(%s) ELSE FALSE END)",
+			value, substr, value, elem,
+		), nil
+	}
+
+	return "", fmt.Errorf("unsupported filter operator %q", p.Op)
+}
+
+// jsonArg binds a filter literal as a jsonb argument.
+func jsonArg(v any, args *argList) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return args.add(string(b)) + "::jsonb", nil
+}
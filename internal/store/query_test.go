@@ -0,0 +1,92 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/filter"
+	"github.com/PratikDhanave/event-analytics-service/internal/hll"
+)
+
+// Every filter literal and property key must be bound as an argument, never spliced into SQL.
+func TestMetricsQueryWhere_BindsFilterValues(t *testing.T) {
+	expr, err := filter.Parse(`properties."x'; DROP TABLE events; --" = 'web''s' or properties.country in ('DE', 'FR') or properties.n > 42.5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := MetricsQuery{
+		TenantID:  "t1",
+		EventName: "login",
+		From:      time.Unix(0, 0),
+		To:        time.Unix(3600, 0),
+		Filter:    expr,
+	}
+
+	var args argList
+	where, err := q.where(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []string{"DROP", "web", "DE", "country", "42.5"} {
+		if strings.Contains(where, bad) {
+			t.Fatalf("literal %q leaked into SQL: %s", bad, where)
+		}
+	}
+
+	want := []any{
+		"t1", "login", q.From, q.To,
+		[]string{"x'; DROP TABLE events; --"}, `"web's"`,
+		[]string{"country"}, []string{`"DE"`, `"FR"`},
+		[]string{"n"}, "42.5",
+	}
+	if !reflect.DeepEqual([]any(args), want) {
+		t.Fatalf("args = %#v, want %#v", args, want)
+	}
+}
+
+// registersSQL must compute the register layout hll.EstimateFromRegisters
+// assumes. The SQL expressions are evaluated here step by step as Postgres
+// does (arithmetic shift of a signed bigint, cast to its low bits, ltrim).
+func TestRegistersSQL_MatchesHLLLayout(t *testing.T) {
+	p := hll.Precision
+	q := 64 - p
+
+	sql := registersSQL("", "true")
+	for _, want := range []string{
+		fmt.Sprintf("h & %d AS reg", 1<<p-1),
+		fmt.Sprintf("MAX(%d - length(ltrim((h >> %d)::bit(%d)::text, '0'))) AS rho", q+1, p, q),
+		"hashtextextended(" + actorExpr + ", 0) AS h",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("registersSQL lacks %q:\n%s", want, sql)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	hashes := []int64{0, -1, 1 << p, math.MinInt64, math.MaxInt64}
+	for i := 0; i < 1000; i++ {
+		hashes = append(hashes, int64(rng.Uint64()))
+	}
+	for _, h := range hashes {
+		// SQL: h & mask; (h >> p)::bit(q) keeps the low q bits of the shifted value.
+		reg := h & (1<<p - 1)
+		bitText := fmt.Sprintf("%064b", uint64(h>>p))[64-q:]
+		rho := q + 1 - len(strings.TrimLeft(bitText, "0"))
+
+		// Layout: low p bits pick the register, rank = first set bit of the rest.
+		u := uint64(h)
+		wantReg := int64(u & (1<<p - 1))
+		wantRho := bits.LeadingZeros64(u>>p) - p + 1
+		if reg != wantReg || rho != wantRho {
+			t.Fatalf("hash %#x: SQL gives reg %d rho %d, layout %d %d", u, reg, rho, wantReg, wantRho)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+)
+
+// EnqueueEvents appends a batch of events for one tenant to the ingestion outbox.
+//
+// It returns the set of event IDs that were newly queued; an ID missing from the
+// result is already waiting in the queue (a client retry). Events that were
+// already persisted are queued again and deduplicated by the worker's insert, so
+// the (tenant_id, event_id) idempotency contract holds end to end.
+func (p *PostgresStore) EnqueueEvents(
+	ctx context.Context,
+	tenantID string,
+	events []Event,
+) (map[string]bool, error) {
+
+	ctx, o := startOp(ctx, "enqueue_events", tenantID, batchEventName(events))
+	defer o.end()
+
+	if tenantID == "" {
+		return nil, errors.New("tenantID required")
+	}
+
+	queued := make(map[string]bool, len(events))
+	if len(events) == 0 {
+		return queued, nil
+	}
+
+	cols, err := newEventColumns(events)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		INSERT INTO event_queue(tenant_id, event_id, event_name, ts, properties, user_id, anonymous_id, schema_errors)
+		SELECT $1, e.event_id, e.event_name, e.ts, e.properties::jsonb,
+		       NULLIF(e.user_id, ''), NULLIF(e.anonymous_id, ''), NULLIF(e.schema_errors, '')::jsonb
+		FROM unnest($2::text[], $3::text[], $4::timestamptz[], $5::text[], $6::text[], $7::text[], $8::text[])
+		  AS e(event_id, event_name, ts, properties, user_id, anonymous_id, schema_errors)
+		ON CONFLICT (tenant_id, event_id) DO NOTHING
+		RETURNING event_id
+	`, tenantID, cols.ids, cols.names, cols.tss, cols.props, cols.userIDs, cols.anonIDs, cols.schemaErrs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		queued[id] = true
+	}
+
+	return queued, rows.Err()
+}
+
+// DrainOptions controls one DrainQueue pass.
+type DrainOptions struct {
+	// Limit is the maximum number of queue rows claimed in one pass.
+	Limit int
+	// MaxAttempts moves a row to event_dead_letters once it has failed this many times.
+	MaxAttempts int
+	// RetryDelay returns how long to wait before retrying a row that has now failed
+	// attempts times.
+	RetryDelay func(attempts int) time.Duration
+}
+
+// DrainStats reports what one DrainQueue pass did.
+type DrainStats struct {
+	Claimed      int
+	Inserted     int
+	Duplicates   int
+	Retried      int
+	DeadLettered int
+}
+
+// queueInsertSQL copies claimed event_queue rows into events, skipping duplicates
+// (ids already claimed in event_ids, as in InsertEvents) and adding the new
+// ones to the rollup and catalog deltas.
+var queueInsertSQL = `
+	WITH q AS (
+		SELECT tenant_id, event_id, event_name, ts, properties, user_id, anonymous_id, schema_errors
+		FROM event_queue
+		WHERE id = ANY($1::bigint[])
+	), claimed AS (
+		INSERT INTO event_ids(tenant_id, event_id, ts)
+		SELECT tenant_id, event_id, ts FROM q
+		ON CONFLICT (tenant_id, event_id) DO NOTHING
+		RETURNING tenant_id, event_id
+	), written AS (
+		INSERT INTO events(tenant_id, event_id, event_name, ts, properties, user_id, anonymous_id, schema_errors)
+		SELECT q.tenant_id, q.event_id, q.event_name, q.ts, q.properties, q.user_id, q.anonymous_id, q.schema_errors
+		FROM q JOIN claimed USING (tenant_id, event_id)
+		RETURNING tenant_id, event_name, ts, properties, user_id
+	), ` + rollupDeltaSQL + `, ` + catalogSQL + `
+	SELECT tenant_id, event_name, ts FROM written
+`
+
+// DrainQueue moves due rows from event_queue into events.
+//
+// Rows are claimed with FOR UPDATE SKIP LOCKED, so any number of workers (in-process
+// or cmd/worker replicas) can drain concurrently without double work. The claimed
+// batch is first inserted with a single statement; if that fails, each row is retried
+// on its own savepoint so one poison row cannot block the rest. Failed rows are
+// rescheduled with opts.RetryDelay, or dead-lettered after opts.MaxAttempts.
+//
+// An error is only returned when the pass itself could not run (e.g. the database
+// is unreachable); per-row failures are reflected in the stats.
+func (p *PostgresStore) DrainQueue(ctx context.Context, opts DrainOptions) (DrainStats, error) {
+	ctx, o := startOp(ctx, "drain_queue", "", "")
+	defer o.end()
+
+	var stats DrainStats
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, attempts
+		FROM event_queue
+		WHERE next_attempt_at <= now()
+		ORDER BY next_attempt_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, opts.Limit)
+	if err != nil {
+		return stats, err
+	}
+
+	var (
+		ids      []int64
+		attempts = map[int64]int{}
+	)
+	for rows.Next() {
+		var (
+			id int64
+			n  int
+		)
+		if err := rows.Scan(&id, &n); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		ids = append(ids, id)
+		attempts[id] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	stats.Claimed = len(ids)
+	if len(ids) == 0 {
+		return stats, nil
+	}
+
+	// Fast path: the whole batch in one statement.
+	written, err := insertQueued(ctx, tx, ids)
+	if err == nil {
+		stats.Inserted = len(written)
+		stats.Duplicates = len(ids) - len(written)
+		if _, err := tx.Exec(ctx, `DELETE FROM event_queue WHERE id = ANY($1::bigint[])`, ids); err != nil {
+			return stats, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return stats, err
+		}
+		telemetry.EventsWritten(stats.Inserted, stats.Duplicates)
+		p.notifyWritten(ctx, written)
+		return stats, nil
+	}
+
+	// Slow path: isolate failures row by row.
+	written = nil
+	for _, id := range ids {
+		w, rowErr := insertQueued(ctx, tx, []int64{id})
+		if rowErr == nil {
+			if len(w) == 1 {
+				stats.Inserted++
+				written = append(written, w...)
+			} else {
+				stats.Duplicates++
+			}
+			if _, err := tx.Exec(ctx, `DELETE FROM event_queue WHERE id = $1`, id); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		failed := attempts[id] + 1
+		if failed >= opts.MaxAttempts {
+			stats.DeadLettered++
+			if _, err := tx.Exec(ctx, `
+				WITH moved AS (
+					DELETE FROM event_queue WHERE id = $1
+					RETURNING id, tenant_id, event_id, event_name, ts, properties,
+					          user_id, anonymous_id, schema_errors, enqueued_at
+				)
+				INSERT INTO event_dead_letters(id, tenant_id, event_id, event_name, ts, properties,
+				                               user_id, anonymous_id, schema_errors, enqueued_at, attempts, last_error)
+				SELECT id, tenant_id, event_id, event_name, ts, properties,
+				       user_id, anonymous_id, schema_errors, enqueued_at, $2, $3
+				FROM moved
+				ON CONFLICT (id) DO NOTHING
+			`, id, failed, rowErr.Error()); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		stats.Retried++
+		if _, err := tx.Exec(ctx, `
+			UPDATE event_queue
+			SET attempts = $2,
+			    next_attempt_at = now() + ($3::bigint * interval '1 microsecond'),
+			    last_error = $4
+			WHERE id = $1
+		`, id, failed, opts.RetryDelay(failed).Microseconds(), rowErr.Error()); err != nil {
+			return stats, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return stats, err
+	}
+	telemetry.EventsWritten(stats.Inserted, stats.Duplicates)
+	p.notifyWritten(ctx, written)
+	return stats, nil
+}
+
+// QueueLag returns how many events wait in event_queue and how long the
+// oldest has been waiting: the ingestion lag of async mode.
+func (p *PostgresStore) QueueLag(ctx context.Context) (int64, time.Duration, error) {
+	var (
+		depth  int64
+		oldest float64
+	)
+	err := p.pool.QueryRow(ctx, `
+		SELECT count(*), COALESCE(EXTRACT(EPOCH FROM now() - min(enqueued_at)), 0)::float8
+		FROM event_queue
+	`).Scan(&depth, &oldest)
+	return depth, time.Duration(oldest * float64(time.Second)), err
+}
+
+// insertQueued copies the given queue rows into events inside a savepoint and
+// returns the new ones. On error the savepoint is rolled back, leaving tx usable.
+func insertQueued(ctx context.Context, tx pgx.Tx, ids []int64) ([]writtenEvent, error) {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := func() ([]writtenEvent, error) {
+		rows, err := sp.Query(ctx, queueInsertSQL, ids)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var out []writtenEvent
+		for rows.Next() {
+			var w writtenEvent
+			if err := rows.Scan(&w.TenantID, &w.EventName, &w.TS); err != nil {
+				return nil, err
+			}
+			out = append(out, w)
+		}
+		return out, rows.Err()
+	}()
+	if err != nil {
+		_ = sp.Rollback(ctx)
+		return nil, err
+	}
+	if err := sp.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return written, nil
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QuotaPeriod is a quota accounting period. Periods are calendar-aligned in UTC.
+type QuotaPeriod string
+
+// Supported quota periods.
+const (
+	QuotaDaily   QuotaPeriod = "day"
+	QuotaMonthly QuotaPeriod = "month"
+)
+
+// QuotaLimit caps the events a tenant may ingest per period.
+type QuotaLimit struct {
+	Period QuotaPeriod
+	Limit  int64
+}
+
+// QuotaResult reports the outcome of ConsumeQuota. When Allowed is false,
+// Exceeded is the first limit that would have been exceeded, Used its current
+// usage and ResetAt the start of its next period.
+type QuotaResult struct {
+	Allowed  bool
+	Exceeded QuotaLimit
+	Used     int64
+	ResetAt  time.Time
+}
+
+// periodStart returns the UTC start of the period containing t.
+func (qp QuotaPeriod) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	if qp == QuotaMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// next returns the start of the period following the one starting at start.
+func (qp QuotaPeriod) next(start time.Time) time.Time {
+	if qp == QuotaMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// ConsumeQuota atomically charges n events against every limit of a tenant.
+//
+// Either all counters are incremented or none are: each counter is bumped with a
+// conditional upsert that only applies while it stays within its limit, inside one
+// transaction. Counters live in tenant_usage, so they are shared across replicas
+// and survive restarts.
+func (p *PostgresStore) ConsumeQuota(
+	ctx context.Context,
+	tenantID string,
+	n int64,
+	now time.Time,
+	limits []QuotaLimit,
+) (QuotaResult, error) {
+
+	if tenantID == "" {
+		return QuotaResult{}, errors.New("tenantID required")
+	}
+	if len(limits) == 0 || n <= 0 {
+		return QuotaResult{Allowed: true}, nil
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return QuotaResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, l := range limits {
+		start := l.Period.periodStart(now)
+
+		var used int64
+		err := tx.QueryRow(ctx, `
+			INSERT INTO tenant_usage AS u (tenant_id, period, period_start, events)
+			SELECT $1::text, $2::text, $3::date, $4::bigint
+			WHERE $4::bigint <= $5::bigint
+			ON CONFLICT (tenant_id, period, period_start)
+			DO UPDATE SET events = u.events + EXCLUDED.events
+			WHERE u.events + EXCLUDED.events <= $5::bigint
+			RETURNING events
+		`, tenantID, string(l.Period), start, n, l.Limit).Scan(&used)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Over the limit: report current usage for the response headers.
+			if err := tx.QueryRow(ctx, `
+				SELECT COALESCE((
+					SELECT events FROM tenant_usage
+					WHERE tenant_id = $1 AND period = $2 AND period_start = $3::date
+				), 0)
+			`, tenantID, string(l.Period), start).Scan(&used); err != nil {
+				return QuotaResult{}, err
+			}
+			return QuotaResult{
+				Exceeded: l,
+				Used:     used,
+				ResetAt:  l.Period.next(start),
+			}, nil
+		}
+		if err != nil {
+			return QuotaResult{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return QuotaResult{}, err
+	}
+	return QuotaResult{Allowed: true}, nil
+}
+
+// RefundQuota gives back n events charged by ConsumeQuota at the same now, for
+// events that were charged up front but not written. Counters never go below 0.
+func (p *PostgresStore) RefundQuota(
+	ctx context.Context,
+	tenantID string,
+	n int64,
+	now time.Time,
+	limits []QuotaLimit,
+) error {
+
+	if len(limits) == 0 || n <= 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, l := range limits {
+		batch.Queue(`
+			UPDATE tenant_usage
+			SET events = GREATEST(events - $4, 0)
+			WHERE tenant_id = $1 AND period = $2 AND period_start = $3::date
+		`, tenantID, string(l.Period), l.Period.periodStart(now), n)
+	}
+	return p.pool.SendBatch(ctx, batch).Close()
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RetentionQuery describes an N-period retention analysis for one tenant.
+//
+// Actors join the cohort of the period (Unit in TZ) containing their first
+// StartEvent in [From,To). An actor is retained at offset k when they performed
+// ReturnEvent during the k-th period after their cohort period (k=0 is the
+// cohort period itself), for k = 0..Periods.
+type RetentionQuery struct {
+	TenantID    string
+	StartEvent  string
+	ReturnEvent string
+	From        time.Time
+	To          time.Time
+	Unit        string
+	TZ          string
+	Periods     int
+}
+
+// RetentionCohort is one row of the retention matrix.
+// Retained[k] is the number of cohort actors retained at offset k.
+type RetentionCohort struct {
+	Start    time.Time
+	Size     int64
+	Retained []int64
+}
+
+// retentionOffsets maps a retention unit to the SQL computing the period offset
+// between two local period starts r and c.
+var retentionOffsets = map[string]string{
+	"day":   "(%[1]s::date - %[2]s::date)",
+	"week":  "((%[1]s::date - %[2]s::date) / 7)",
+	"month": "((EXTRACT(YEAR FROM %[1]s) * 12 + EXTRACT(MONTH FROM %[1]s)) - (EXTRACT(YEAR FROM %[2]s) * 12 + EXTRACT(MONTH FROM %[2]s)))::int",
+}
+
+// Retention returns the retention matrix for q: one zero-filled row per cohort
+// period in [q.From,q.To), ordered by period.
+func (p *PostgresStore) Retention(ctx context.Context, q RetentionQuery) ([]RetentionCohort, error) {
+	ctx, o := startOp(ctx, "retention", q.TenantID, q.StartEvent)
+	defer o.end()
+
+	offsetFmt, ok := retentionOffsets[q.Unit]
+	if !ok {
+		return nil, fmt.Errorf("unsupported retention unit %q", q.Unit)
+	}
+	offset := fmt.Sprintf(offsetFmt, "r.period_start", "c.cohort_start")
+
+	var args argList
+	tenantArg := args.add(q.TenantID)
+	startArg := args.add(q.StartEvent)
+	returnArg := args.add(q.ReturnEvent)
+	fromArg := args.add(q.From)
+	toArg := args.add(q.To)
+	unitArg := args.add(q.Unit)
+	tzArg := args.add(q.TZ)
+	periodsArg := args.add(q.Periods)
+	step := "('1 ' || " + unitArg + ")::interval"
+
+	rows, err := p.pool.Query(ctx, `
+		WITH cohorts AS (
+			SELECT g AS cohort_start
+			FROM generate_series(
+				date_trunc(`+unitArg+`, `+fromArg+`::timestamptz AT TIME ZONE `+tzArg+`),
+				(`+toArg+`::timestamptz - interval '1 microsecond') AT TIME ZONE `+tzArg+`,
+				`+step+`
+			) AS g
+		), cohort AS (
+			SELECT `+actorExpr+` AS actor,
+			       date_trunc(`+unitArg+`, MIN(ts) AT TIME ZONE `+tzArg+`) AS cohort_start
+			FROM events
+			WHERE tenant_id = `+tenantArg+`
+			  AND event_name = `+startArg+`
+			  AND ts >= `+fromArg+`
+			  AND ts < `+toArg+`
+			  AND `+actorExpr+` IS NOT NULL
+			GROUP BY 1
+		), returns AS (
+			SELECT DISTINCT `+actorExpr+` AS actor,
+			       date_trunc(`+unitArg+`, ts AT TIME ZONE `+tzArg+`) AS period_start
+			FROM events
+			WHERE tenant_id = `+tenantArg+`
+			  AND event_name = `+returnArg+`
+			  AND ts >= `+fromArg+`
+			  AND ts < `+toArg+`::timestamptz + (`+periodsArg+`::int + 1) * `+step+`
+			  AND `+actorExpr+` IS NOT NULL
+		), sizes AS (
+			SELECT cohort_start, COUNT(*) AS size
+			FROM cohort
+			GROUP BY 1
+		), retained AS (
+			SELECT c.cohort_start, `+offset+` AS k, COUNT(*) AS n
+			FROM cohort c
+			JOIN returns r ON r.actor = c.actor AND r.period_start >= c.cohort_start
+			WHERE `+offset+` <= `+periodsArg+`::int
+			GROUP BY 1, 2
+		)
+		SELECT g.cohort_start AT TIME ZONE `+tzArg+`, COALESCE(s.size, 0), r.k, COALESCE(r.n, 0)
+		FROM cohorts g
+		LEFT JOIN sizes s USING (cohort_start)
+		LEFT JOIN retained r USING (cohort_start)
+		ORDER BY 1, 3
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cohorts []RetentionCohort
+	for rows.Next() {
+		var (
+			start time.Time
+			size  int64
+			k     *int
+			n     int64
+		)
+		if err := rows.Scan(&start, &size, &k, &n); err != nil {
+			return nil, err
+		}
+
+		if len(cohorts) == 0 || !cohorts[len(cohorts)-1].Start.Equal(start) {
+			cohorts = append(cohorts, RetentionCohort{
+				Start:    start,
+				Size:     size,
+				Retained: make([]int64, q.Periods+1),
+			})
+		}
+		if k != nil && *k >= 0 && *k <= q.Periods {
+			cohorts[len(cohorts)-1].Retained[*k] = n
+		}
+	}
+
+	return cohorts, rows.Err()
+}
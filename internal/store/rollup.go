@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// rollupLockID is the advisory lock key guarding event_rollups_hourly. The
+// compactor holds it exclusively; statements removing whole ranges of rollups
+// hold it shared, so a compaction pass cannot fold deltas of such a range back
+// in while it is removed.
+const rollupLockID = 0x6576_726f_6c6c
+
+// rollupDeltaSQL is a CTE appending the rows of a preceding "written" CTE
+// (returning tenant_id, event_name and ts of new events) to
+// event_rollup_deltas. Deltas commit with the events they count, so rollups
+// plus pending deltas always count exactly the committed events.
+const rollupDeltaSQL = `
+	rollup_deltas AS (
+		INSERT INTO event_rollup_deltas(tenant_id, event_name, bucket, events)
+		SELECT tenant_id, event_name, date_trunc('hour', ts, 'UTC'), COUNT(*)
+		FROM written
+		GROUP BY 1, 2, 3
+	)`
+
+// rollupDecrementSQL is a CTE body that appends the rows of a "deleted" CTE
+// (returning tenant_id, event_name and ts) to event_rollup_deltas as negative
+// counts.
+const rollupDecrementSQL = `
+	INSERT INTO event_rollup_deltas(tenant_id, event_name, bucket, events)
+	SELECT tenant_id, event_name, date_trunc('hour', ts, 'UTC'), -COUNT(*)
+	FROM deleted
+	GROUP BY 1, 2, 3`
+
+// lockRollupsShared takes rollupLockID shared for the rest of tx.
+func lockRollupsShared(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock_shared($1)`, int64(rollupLockID))
+	return err
+}
+
+// deleteEventBatch deletes up to batch events matching where, together with
+// their idempotency records and their share of the rollups, and returns how
+// many were deleted. where is a condition over events using args.
+func (p *PostgresStore) deleteEventBatch(ctx context.Context, where string, args argList, batch int) (int64, error) {
+	limitArg := args.add(batch)
+
+	tag, err := p.pool.Exec(ctx, `
+		WITH doomed AS (
+			SELECT tenant_id, event_id, ts
+			FROM events
+			WHERE `+where+`
+			LIMIT `+limitArg+`
+		), deleted AS (
+			DELETE FROM events e
+			USING doomed d
+			WHERE e.tenant_id = d.tenant_id AND e.event_id = d.event_id AND e.ts = d.ts
+			RETURNING e.tenant_id, e.event_id, e.event_name, e.ts
+		), ids AS (
+			DELETE FROM event_ids i
+			USING deleted d
+			WHERE i.tenant_id = d.tenant_id AND i.event_id = d.event_id
+		), rolled AS (`+rollupDecrementSQL+`
+		)
+		SELECT 1 FROM deleted
+	`, args...)
+	return tag.RowsAffected(), err
+}
+
+// dropRollupRange deletes the rollups and pending deltas of [start,end) (a zero
+// start is unbounded), optionally only those of the given tenants, after the
+// events of that range were removed as a whole. Partition bounds are whole UTC
+// days, so rollup hours never straddle them. Callers hold rollupLockID shared.
+func dropRollupRange(ctx context.Context, tx pgx.Tx, start, end time.Time, tenants []string) error {
+	for _, table := range []string{"event_rollups_hourly", "event_rollup_deltas"} {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM `+table+`
+			WHERE bucket < $2
+			  AND ($1::timestamptz IS NULL OR bucket >= $1)
+			  AND ($3::text[] IS NULL OR tenant_id = ANY($3))
+		`, nullTime(start), end, tenants); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// CompactRollups folds up to limit pending rows of event_rollup_deltas into
+// event_rollups_hourly and deletes them. It returns how many rollup rows were
+// written and whether no deltas were left behind.
+//
+// Deltas are only visible once the statement that wrote them committed, so a
+// slow ingestion transaction is folded by a later pass instead of being skipped.
+func (p *PostgresStore) CompactRollups(ctx context.Context, limit int) (int64, bool, error) {
+	var drained, rows int64
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(rollupLockID)); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			WITH drained AS (
+				DELETE FROM event_rollup_deltas
+				WHERE id IN (SELECT id FROM event_rollup_deltas ORDER BY id LIMIT $1)
+				RETURNING tenant_id, event_name, bucket, events
+			), folded AS (
+				INSERT INTO event_rollups_hourly AS r (tenant_id, event_name, bucket, events)
+				SELECT tenant_id, event_name, bucket, SUM(events)
+				FROM drained
+				GROUP BY 1, 2, 3
+				ORDER BY 1, 2, 3
+				ON CONFLICT (tenant_id, event_name, bucket)
+				DO UPDATE SET events = r.events + EXCLUDED.events
+				RETURNING 1
+			)
+			SELECT (SELECT count(*) FROM drained), (SELECT count(*) FROM folded)
+		`, limit).Scan(&drained, &rows)
+	})
+	return rows, drained < int64(limit), err
+}
+
+// useRollups reports whether q is a plain event count, the only aggregate the
+// rollups can answer.
+func (q MetricsQuery) useRollups() bool {
+	return q.Aggregate == AggregateCount && q.Filter == nil
+}
+
+// rollupSpan returns the whole UTC hours [start,end) inside [from,to), and
+// false when there are none.
+func rollupSpan(from, to time.Time) (time.Time, time.Time, bool) {
+	start := from.UTC().Truncate(time.Hour)
+	if start.Before(from) {
+		start = start.Add(time.Hour)
+	}
+	end := to.UTC().Truncate(time.Hour)
+	return start, end, start.Before(end)
+}
+
+// hourAligned reports whether loc is a whole number of hours from UTC during
+// all of [from,to), so every UTC hour falls into a single local bucket.
+func hourAligned(loc *time.Location, from, to time.Time) bool {
+	for t := from; t.Before(to); {
+		if _, offset := t.In(loc).Zone(); offset%3600 != 0 {
+			return false
+		}
+		_, next := t.In(loc).ZoneBounds()
+		if next.IsZero() {
+			return true
+		}
+		t = next
+	}
+	return true
+}
+
+// rollupCountsSQL renders a query yielding (key, cnt) rows whose per-key sums
+// are the event counts of q, with key(ts) the bucket key of a timestamptz
+// expression. Whole hours of [start,end) come from the rollups plus the deltas
+// the compactor has not folded yet; the ragged edges of [q.From,q.To) are
+// scanned from events.
+func rollupCountsSQL(q MetricsQuery, start, end time.Time, key func(ts string) string, args *argList) string {
+	tenantArg := args.add(q.TenantID)
+	nameArg := args.add(q.EventName)
+	fromArg, toArg := args.add(q.From), args.add(q.To)
+	startArg, endArg := args.add(start), args.add(end)
+
+	scope := "tenant_id = " + tenantArg + " AND event_name = " + nameArg
+	return `
+		SELECT ` + key("bucket") + ` AS key, SUM(events)::bigint AS cnt
+		FROM event_rollups_hourly
+		WHERE ` + scope + ` AND bucket >= ` + startArg + ` AND bucket < ` + endArg + `
+		GROUP BY 1
+		UNION ALL
+		SELECT ` + key("ts") + `, COUNT(*)
+		FROM events
+		WHERE ` + scope + `
+		  AND ((ts >= ` + fromArg + ` AND ts < ` + startArg + `) OR (ts >= ` + endArg + ` AND ts < ` + toArg + `))
+		GROUP BY 1
+		UNION ALL
+		SELECT ` + key("bucket") + `, SUM(events)::bigint
+		FROM event_rollup_deltas
+		WHERE ` + scope + ` AND bucket >= ` + startArg + ` AND bucket < ` + endArg + `
+		GROUP BY 1`
+}
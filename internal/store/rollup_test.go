@@ -0,0 +1,55 @@
+package store
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestRollupSpan(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 10, 16, h, m, 0, 0, time.UTC) }
+
+	cases := []struct {
+		from, to   time.Time
+		start, end time.Time
+		ok         bool
+	}{
+		{at(9, 15), at(13, 40), at(10, 0), at(13, 0), true},
+		{at(9, 0), at(13, 0), at(9, 0), at(13, 0), true},
+		{at(9, 15), at(10, 40), at(10, 0), at(10, 0), false},
+		{at(9, 15), at(9, 40), at(10, 0), at(9, 0), false},
+	}
+	for _, tc := range cases {
+		start, end, ok := rollupSpan(tc.from, tc.to)
+		if ok != tc.ok || (ok && (!start.Equal(tc.start) || !end.Equal(tc.end))) {
+			t.Fatalf("rollupSpan(%s, %s) = %s, %s, %v", tc.from, tc.to, start, end, ok)
+		}
+	}
+
+	// Offsets are irrelevant: spans are whole UTC hours.
+	kolkata := time.FixedZone("IST", 5*3600+1800)
+	start, _, _ := rollupSpan(time.Date(2026, 10, 16, 10, 0, 0, 0, kolkata), at(12, 0))
+	if !start.Equal(at(5, 0)) {
+		t.Fatalf("got start %s", start)
+	}
+}
+
+func TestHourAligned(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	for tz, want := range map[string]bool{
+		"UTC":                 true,
+		"America/New_York":    true,  // DST shifts by a whole hour
+		"Asia/Kolkata":        false, // +05:30
+		"Australia/Lord_Howe": false, // +10:30, +11 in summer
+	} {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hourAligned(loc, from, to); got != want {
+			t.Fatalf("%s: got %v want %v", tz, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrEventSchemaNotFound is returned when a tenant has no schema for an event name.
+var ErrEventSchemaNotFound = errors.New("event schema not found")
+
+// EventSchema is the JSON Schema registered for the properties of one event
+// name of a tenant.
+type EventSchema struct {
+	TenantID  string
+	EventName string
+	Schema    json.RawMessage
+	Version   int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+const eventSchemaColumns = `tenant_id, event_name, schema, version, created_at, updated_at`
+
+func scanEventSchema(row pgx.Row) (EventSchema, error) {
+	var s EventSchema
+	err := row.Scan(&s.TenantID, &s.EventName, &s.Schema, &s.Version, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return EventSchema{}, ErrEventSchemaNotFound
+	}
+	return s, err
+}
+
+// PutEventSchema registers s, replacing (and bumping the version of) an
+// existing schema for the same event name.
+func (p *PostgresStore) PutEventSchema(ctx context.Context, s EventSchema) (EventSchema, error) {
+	if s.TenantID == "" || s.EventName == "" || len(s.Schema) == 0 {
+		return EventSchema{}, errors.New("tenantID/eventName/schema required")
+	}
+
+	return scanEventSchema(p.pool.QueryRow(ctx, `
+		INSERT INTO event_schemas(tenant_id, event_name, schema)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id, event_name) DO UPDATE
+		SET schema = EXCLUDED.schema,
+		    version = event_schemas.version + 1,
+		    updated_at = now()
+		RETURNING `+eventSchemaColumns,
+		s.TenantID, s.EventName, s.Schema))
+}
+
+// GetEventSchema returns the schema of eventName for tenantID.
+func (p *PostgresStore) GetEventSchema(ctx context.Context, tenantID, eventName string) (EventSchema, error) {
+	return scanEventSchema(p.pool.QueryRow(ctx, `
+		SELECT `+eventSchemaColumns+`
+		FROM event_schemas
+		WHERE tenant_id = $1 AND event_name = $2
+	`, tenantID, eventName))
+}
+
+// ListEventSchemas returns every schema of tenantID, by event name.
+func (p *PostgresStore) ListEventSchemas(ctx context.Context, tenantID string) ([]EventSchema, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+eventSchemaColumns+`
+		FROM event_schemas
+		WHERE tenant_id = $1
+		ORDER BY event_name
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemas := []EventSchema{}
+	for rows.Next() {
+		s, err := scanEventSchema(rows)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, rows.Err()
+}
+
+// DeleteEventSchema removes the schema of eventName for tenantID.
+func (p *PostgresStore) DeleteEventSchema(ctx context.Context, tenantID, eventName string) error {
+	tag, err := p.pool.Exec(ctx, `
+		DELETE FROM event_schemas WHERE tenant_id = $1 AND event_name = $2
+	`, tenantID, eventName)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEventSchemaNotFound
+	}
+	return nil
+}
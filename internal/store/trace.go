@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/telemetry"
+	"github.com/PratikDhanave/event-analytics-service/internal/tracing"
+)
+
+// op is a store operation being timed (store_operation_duration_seconds) and
+// traced. The queries it runs are recorded as child spans by the pool's
+// tracing.QueryTracer.
+type op struct {
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+// startOp begins operation name for a tenant and, when known, an event name:
+//
+//	ctx, o := startOp(ctx, "count_events", q.TenantID, q.EventName)
+//	defer o.end()
+func startOp(ctx context.Context, name, tenantID, eventName string) (context.Context, *op) {
+	var attrs []attribute.KeyValue
+	if tenantID != "" {
+		attrs = append(attrs, attribute.String("tenant_id", tenantID))
+	}
+	if eventName != "" {
+		attrs = append(attrs, attribute.String("event_name", eventName))
+	}
+	ctx, span := tracing.Start(ctx, "store."+name, trace.SpanKindInternal, attrs...)
+	return ctx, &op{name: name, start: time.Now(), span: span}
+}
+
+func (o *op) end() {
+	telemetry.ObserveStore(o.name, o.start)
+	o.span.End()
+}
+
+// batchEventName returns the event name shared by all events, or "" when
+// they differ.
+func batchEventName(events []Event) string {
+	if len(events) == 0 {
+		return ""
+	}
+	name := events[0].EventName
+	for _, e := range events[1:] {
+		if e.EventName != name {
+			return ""
+		}
+	}
+	return name
+}
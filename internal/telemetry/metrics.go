@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.With(Default).NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+	httpDuration = promauto.With(Default).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and status.",
+		Buckets: DefaultBuckets,
+	}, []string{"route", "method", "status"})
+	storeDuration = promauto.With(Default).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "store_operation_duration_seconds",
+		Help:    "Duration of store operations (insert_event, count_events, ...).",
+		Buckets: DefaultBuckets,
+	}, []string{"op"})
+	eventsWritten = promauto.With(Default).NewCounterVec(prometheus.CounterOpts{
+		Name: "events_written_total",
+		Help: "Events offered to the events table, by result (inserted or duplicate).",
+	}, []string{"result"})
+	authFailures = promauto.With(Default).NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_failures_total",
+		Help: "Rejected requests by reason (missing_key, invalid_key, invalid_token, missing_scope, ...).",
+	}, []string{"reason"})
+	schemaViolations = promauto.With(Default).NewCounterVec(prometheus.CounterOpts{
+		Name: "schema_violations_total",
+		Help: "Events failing their schema, by validation mode and reason (invalid or unregistered).",
+	}, []string{"mode", "reason"})
+)
+
+// Middleware records the count and latency of every request. Requests that
+// match no route are recorded as route "unmatched", so probing for random
+// paths cannot grow the label set.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		httpDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveStore records the duration of store operation op started at start:
+//
+//	defer telemetry.ObserveStore("count_events", time.Now())
+func ObserveStore(op string, start time.Time) {
+	storeDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// EventsWritten records the outcome of writing events; duplicates were
+// already stored under the same (tenant_id, event_id).
+func EventsWritten(inserted, duplicates int) {
+	if inserted > 0 {
+		eventsWritten.WithLabelValues("inserted").Add(float64(inserted))
+	}
+	if duplicates > 0 {
+		eventsWritten.WithLabelValues("duplicate").Add(float64(duplicates))
+	}
+}
+
+// AuthFailure records a rejected credential.
+func AuthFailure(reason string) {
+	authFailures.WithLabelValues(reason).Inc()
+}
+
+// SchemaViolation records an event that failed schema validation; strict
+// mode rejected it, lenient mode accepted it flagged.
+func SchemaViolation(mode, reason string) {
+	schemaViolations.WithLabelValues(mode, reason).Inc()
+}
+
+// RegisterPool reports the statistics of a connection pool.
+func RegisterPool(stat func() *pgxpool.Stat) {
+	NewCollector(func() []Sample {
+		s := stat()
+		return []Sample{
+			{"pgxpool_acquired_conns", "Connections currently in use.", prometheus.GaugeValue, float64(s.AcquiredConns())},
+			{"pgxpool_idle_conns", "Idle connections.", prometheus.GaugeValue, float64(s.IdleConns())},
+			{"pgxpool_total_conns", "Open connections.", prometheus.GaugeValue, float64(s.TotalConns())},
+			{"pgxpool_max_conns", "Maximum pool size.", prometheus.GaugeValue, float64(s.MaxConns())},
+			{"pgxpool_acquire_total", "Connections acquired from the pool.", prometheus.CounterValue, float64(s.AcquireCount())},
+			{"pgxpool_empty_acquire_total", "Acquires that had to wait for a connection.", prometheus.CounterValue, float64(s.EmptyAcquireCount())},
+			{"pgxpool_canceled_acquire_total", "Acquires cancelled by their context.", prometheus.CounterValue, float64(s.CanceledAcquireCount())},
+			{"pgxpool_acquire_wait_seconds_total", "Time spent acquiring connections.", prometheus.CounterValue, s.AcquireDuration().Seconds()},
+		}
+	})
+}
+
+// RegisterQueueLag reports the async ingestion backlog as read by lag (see
+// store.QueueLag). A failed read is reported as NaN.
+func RegisterQueueLag(lag func(ctx context.Context) (int64, time.Duration, error)) {
+	NewCollector(func() []Sample {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		depth, oldest := math.NaN(), math.NaN()
+		if n, age, err := lag(ctx); err == nil {
+			depth, oldest = float64(n), age.Seconds()
+		}
+		return []Sample{
+			{"ingest_queue_depth", "Events waiting in event_queue.", prometheus.GaugeValue, depth},
+			{"ingest_queue_lag_seconds", "Age of the oldest event waiting in event_queue.", prometheus.GaugeValue, oldest},
+		}
+	})
+}
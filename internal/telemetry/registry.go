@@ -0,0 +1,63 @@
+// Package telemetry exposes operational metrics in the Prometheus exposition
+// format (GET /internal/metrics on TELEMETRY_ADDR), separate from the
+// tenant-facing GET /metrics analytics API.
+package telemetry
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Default is the registry served on TELEMETRY_ADDR. Besides the metrics of
+// this package it reports the Go runtime (go_*) and the process (process_*).
+var Default = newRegistry()
+
+func newRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// DefaultBuckets suit request and query latencies in seconds.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample is one value reported by a collector.
+type Sample struct {
+	Name  string
+	Help  string
+	Type  prometheus.ValueType // prometheus.GaugeValue or prometheus.CounterValue
+	Value float64
+}
+
+// collector reports samples read together, e.g. from one query or one stats
+// call. It describes nothing up front (an unchecked collector), as its
+// samples are only known once read.
+type collector func() []Sample
+
+func (c collector) Describe(chan<- *prometheus.Desc) {}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c() {
+		ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(s.Name, s.Help, nil, nil), s.Type, s.Value)
+	}
+}
+
+// NewCollector registers fn with Default; it is called on every scrape.
+func NewCollector(fn func() []Sample) {
+	Default.MustRegister(collector(fn))
+}
+
+// NewServer returns a server for the Default registry at /internal/metrics on
+// addr. The caller runs and shuts it down with the rest of the process.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/internal/metrics", promhttp.HandlerFor(Default, promhttp.HandlerOpts{}))
+	return &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+}
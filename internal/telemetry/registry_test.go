@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestServer_ExposesMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/events/:id", func(c *gin.Context) { c.Status(http.StatusAccepted) })
+	for _, path := range []string{"/events/1", "/events/2", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	EventsWritten(3, 1)
+	NewCollector(func() []Sample {
+		return []Sample{{Name: "test_pool_idle", Help: "Idle.", Type: prometheus.GaugeValue, Value: 3}}
+	})
+
+	srv := httptest.NewServer(NewServer("").Handler)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/internal/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	body := string(b)
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/events/:id",status="202"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/events/:id",status="202"} 2`,
+		`events_written_total{result="inserted"} 3`,
+		`events_written_total{result="duplicate"} 1`,
+		"# TYPE test_pool_idle gauge\ntest_pool_idle 3\n",
+		// Runtime and process collectors.
+		"go_goroutines ",
+		"process_cpu_seconds_total ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Middleware starts a server span per request with otelgin, continuing the
+// trace of incoming traceparent and baggage headers, and stores it in the
+// request context for the rest of the chain, handlers and the store. It must
+// run first: otelgin restores the original request context once it returns.
+func Middleware(service string) gin.HandlerFunc {
+	return otelgin.Middleware(service)
+}
+
+// Tenant records the authenticated tenant as the tenant_id attribute of the
+// request's span once the request is handled (auth runs later in the chain).
+func Tenant(tenant func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if t := tenant(c); t != "" {
+			SetAttr(c.Request.Context(), "tenant_id", t)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatement bounds the db.statement attribute; some rollup and funnel
+// queries are several kilobytes long.
+const maxStatement = 2048
+
+// QueryTracer records a client span for every Postgres query run under an
+// existing span (set as pgx ConnConfig.Tracer). Queries outside a traced
+// request or store operation, e.g. background polling, are not recorded.
+type QueryTracer struct{}
+
+// querySpanKey holds the span of the query in flight, so TraceQueryEnd never
+// ends the caller's span when TraceQueryStart recorded none.
+type querySpanKey struct{}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx
+	}
+	ctx, span := Start(ctx, "db.query", trace.SpanKindClient,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", statement(data.SQL)),
+	)
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+// TraceQueryEnd implements pgx.QueryTracer.
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(querySpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+}
+
+// statement collapses whitespace of sql and truncates it to maxStatement bytes.
+func statement(sql string) string {
+	s := strings.Join(strings.Fields(sql), " ")
+	if len(s) > maxStatement {
+		s = s[:maxStatement] + "..."
+	}
+	return s
+}
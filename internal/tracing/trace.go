@@ -0,0 +1,138 @@
+// Package tracing sets up OpenTelemetry tracing: the SDK tracer provider, its
+// exporter (OTLP over HTTP or gRPC, or JSON spans to stdout or a file) and the
+// W3C Trace Context and Baggage propagators, so spans join the caller's trace.
+//
+// HTTP requests are traced by otelgin (see Middleware), store operations by
+// Start and Postgres queries by QueryTracer. Until Init installs a provider
+// (or with TRACING_EXPORTER=none) the global no-op provider records nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scope names the instrumentation of this service's own spans.
+const scope = "github.com/PratikDhanave/event-analytics-service"
+
+// Options configures Init.
+type Options struct {
+	Exporter    string  // "none", "otlp", "otlp-grpc", "stdout" or "file"
+	Endpoint    string  // OTLP base URL; empty uses the exporter's default
+	File        string  // output path of the file exporter
+	ServiceName string  // service.name resource attribute
+	SampleRatio float64 // share of new traces recorded; callers' decisions are kept
+}
+
+// exportTimeout bounds how long finished spans wait before they are exported
+// in a batch. When the exporter falls behind, spans are dropped rather than
+// slowing requests down.
+const exportTimeout = 2 * time.Second
+
+// Init installs the process-wide tracer provider described by opts and
+// returns a function flushing pending spans on shutdown. With Exporter "none"
+// nothing is recorded.
+func Init(opts Options) (func(context.Context), error) {
+	if opts.Exporter == "" || opts.Exporter == "none" {
+		return func(context.Context) {}, nil
+	}
+
+	exp, closer, err := newExporter(opts)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp, sdktrace.WithBatchTimeout(exportTimeout)),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	install(tp)
+
+	return func(ctx context.Context) {
+		if err := tp.Shutdown(ctx); err != nil {
+			slog.Warn("tracing: flushing spans failed", "err", err)
+		}
+		if closer != nil {
+			_ = closer.Close()
+		}
+	}, nil
+}
+
+// install makes tp the global tracer provider, along with the W3C propagators.
+func install(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// newExporter creates the exporter selected by opts, and the file it writes
+// to when there is one.
+func newExporter(opts Options) (sdktrace.SpanExporter, io.Closer, error) {
+	ctx := context.Background()
+	switch opts.Exporter {
+	case "otlp":
+		var o []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			o = append(o, otlptracehttp.WithEndpointURL(strings.TrimRight(opts.Endpoint, "/")+"/v1/traces"))
+		}
+		exp, err := otlptracehttp.New(ctx, o...)
+		return exp, nil, err
+	case "otlp-grpc":
+		var o []otlptracegrpc.Option
+		if opts.Endpoint != "" {
+			o = append(o, otlptracegrpc.WithEndpointURL(opts.Endpoint))
+		}
+		exp, err := otlptracegrpc.New(ctx, o...)
+		return exp, nil, err
+	case "stdout":
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exp, nil, err
+	case "file":
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exp, f, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+}
+
+// Start begins a span named name as a child of the span in ctx (or of the
+// remote parent extracted into it), and returns ctx carrying it.
+func Start(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(scope).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// SetAttr sets a string attribute on the span in ctx, if it is recording.
+func SetAttr(ctx context.Context, key, value string) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String(key, value))
+}
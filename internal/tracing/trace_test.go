@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// record installs a provider keeping every finished span in memory.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	install(tp)
+	t.Cleanup(func() { install(noop.NewTracerProvider()) })
+	return sr
+}
+
+func attr(s sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, a := range s.Attributes() {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	sr := record(t)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware("test-service"), Tenant(func(*gin.Context) string { return "tenant1" }))
+	r.GET("/metrics", func(c *gin.Context) {
+		SetAttr(c.Request.Context(), "event_name", "signup")
+		_, child := Start(c.Request.Context(), "store.count_events", trace.SpanKindInternal)
+		child.End()
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics?event_name=signup", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	child, server := spans[0], spans[1]
+
+	if server.Name() != "GET /metrics" || server.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("server span = %q kind %s", server.Name(), server.SpanKind())
+	}
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		server.Parent().SpanID().String() != "00f067aa0ba902b7" || !server.Parent().IsRemote() {
+		t.Fatalf("server span not continuing the incoming trace: parent %v", server.Parent())
+	}
+	if child.SpanContext().TraceID() != server.SpanContext().TraceID() || child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatal("child span not parented to the server span")
+	}
+	if v, ok := attr(server, "tenant_id"); !ok || v.AsString() != "tenant1" {
+		t.Fatal("tenant_id attribute missing")
+	}
+	if v, ok := attr(server, "event_name"); !ok || v.AsString() != "signup" {
+		t.Fatal("event_name attribute missing")
+	}
+	if v, ok := attr(server, "http.response.status_code"); !ok || v.AsInt64() != 500 {
+		t.Fatal("status code attribute missing")
+	}
+	if server.Status().Code != codes.Error {
+		t.Fatalf("status = %+v, want error", server.Status())
+	}
+}
+
+func TestQueryTracer(t *testing.T) {
+	sr := record(t)
+	var qt QueryTracer
+
+	// Queries outside a span, e.g. background polling, are not recorded.
+	ctx := qt.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	qt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	if n := len(sr.Ended()); n != 0 {
+		t.Fatalf("recorded %d spans outside a trace", n)
+	}
+
+	ctx, op := Start(context.Background(), "store.count_events", trace.SpanKindInternal)
+	qctx := qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "\n\t\tSELECT count(*)\n\t\tFROM events\n\t"})
+	qt.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1"), Err: errors.New("boom")})
+	if n := len(sr.Ended()); n != 1 {
+		t.Fatalf("query ended %d spans, want only its own", n)
+	}
+	op.End()
+
+	q := sr.Ended()[0]
+	if q.Name() != "db.query" || q.SpanKind() != trace.SpanKindClient || q.Parent().SpanID() != op.SpanContext().SpanID() {
+		t.Fatalf("query span = %q kind %s parent %s", q.Name(), q.SpanKind(), q.Parent().SpanID())
+	}
+	if v, _ := attr(q, "db.statement"); v.AsString() != "SELECT count(*) FROM events" {
+		t.Fatalf("db.statement = %q", v.AsString())
+	}
+	if v, _ := attr(q, "db.rows_affected"); v.AsInt64() != 1 {
+		t.Fatalf("db.rows_affected = %d", v.AsInt64())
+	}
+	if q.Status().Code != codes.Error {
+		t.Fatalf("status = %+v, want error", q.Status())
+	}
+}
+
+func TestStatement_Truncates(t *testing.T) {
+	got := statement(strings.Repeat("x", maxStatement+10))
+	if len(got) != maxStatement+3 || !strings.HasSuffix(got, "...") {
+		t.Fatalf("statement length %d", len(got))
+	}
+}
+
+func TestInit(t *testing.T) {
+	if _, err := Init(Options{Exporter: "zipkin"}); err == nil {
+		t.Fatal("unknown exporter accepted")
+	}
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Init(Options{Exporter: "file", File: path, ServiceName: "test-service", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer install(noop.NewTracerProvider())
+
+	_, span := Start(context.Background(), "store.count_events", trace.SpanKindInternal)
+	span.End()
+	shutdown(context.Background())
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"Name":"store.count_events"`) || !strings.Contains(string(b), "test-service") {
+		t.Fatalf("file exporter wrote %s", b)
+	}
+}
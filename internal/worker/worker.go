@@ -0,0 +1,110 @@
+// Package worker drains the asynchronous ingestion queue into the events table.
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/PratikDhanave/event-analytics-service/internal/store"
+)
+
+// Options tunes the drain loop.
+type Options struct {
+	BatchSize    int           // queue rows claimed per pass
+	PollInterval time.Duration // idle wait when the queue is empty
+	MaxAttempts  int           // failures before a row is dead-lettered
+}
+
+// Retry backoff bounds for rows that failed to persist.
+const (
+	baseRetryDelay = time.Second
+	maxRetryDelay  = 5 * time.Minute
+)
+
+// Worker moves queued events into durable storage.
+type Worker struct {
+	st   *store.PostgresStore
+	opts Options
+}
+
+// New creates a worker over the given store.
+func New(st *store.PostgresStore, opts Options) *Worker {
+	return &Worker{st: st, opts: opts}
+}
+
+// Run drains the queue until ctx is cancelled.
+//
+// Full batches are drained back to back; once a pass comes back short the worker
+// waits PollInterval. If the database is unavailable it backs off exponentially
+// (up to maxRetryDelay) instead of spinning.
+func (w *Worker) Run(ctx context.Context) {
+	idle := w.opts.PollInterval
+	failures := 0
+
+	for {
+		stats, err := w.st.DrainQueue(ctx, w.drainOptions())
+
+		wait := time.Duration(0)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			failures++
+			wait = retryDelay(failures)
+			slog.Error("worker: drain failed", "attempt", failures, "retry_in", wait.String(), "err", err)
+		default:
+			failures = 0
+			if stats.Retried > 0 || stats.DeadLettered > 0 {
+				slog.Warn("worker: events failed to persist",
+					"rescheduled", stats.Retried, "dead_lettered", stats.DeadLettered)
+			}
+			if stats.Claimed < w.opts.BatchSize {
+				wait = idle
+			}
+		}
+
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// retryDelay is an exponential backoff: 1s, 2s, 4s, ... capped at maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	d := baseRetryDelay
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
+
+// Flush drains full batches until a pass comes back short or ctx is done. On
+// shutdown it persists the events queued by the last requests; call it once
+// Run has returned, so the two never drain side by side.
+func (w *Worker) Flush(ctx context.Context) error {
+	for {
+		stats, err := w.st.DrainQueue(ctx, w.drainOptions())
+		if err != nil {
+			return err
+		}
+		if stats.Claimed < w.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (w *Worker) drainOptions() store.DrainOptions {
+	return store.DrainOptions{
+		Limit:       w.opts.BatchSize,
+		MaxAttempts: w.opts.MaxAttempts,
+		RetryDelay:  retryDelay,
+	}
+}